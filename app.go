@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"server/config"
+	"server/db"
+	"server/db/repository"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// app is what every command shares: the configuration, the database connection and the repositories
+type app struct {
+	cfg      *config.Config
+	client   *mongo.Client
+	database *mongo.Database
+
+	breakdownRepo    *repository.BreakdownRepository
+	userRepo         *repository.UserRepository
+	transferRepo     *repository.TransferRepository
+	activityRepo     *repository.ActivityRepository
+	idempotencyRepo  *repository.IdempotencyRepository
+	accessTokenRepo  *repository.AccessTokenRepository
+	oidcStateRepo    *repository.OIDCStateRepository
+	sessionRepo      *repository.SessionRepository
+	auditRepo        *repository.AuditRepository
+	workspaceRepo    *repository.WorkspaceRepository
+	invitationRepo   *repository.InvitationRepository
+	commentRepo      *repository.CommentRepository
+	notificationRepo *repository.NotificationRepository
+	attachmentRepo   *repository.AttachmentRepository
+}
+
+// newApp connects to MongoDB and initializes the repositories
+func newApp(ctx context.Context, cfg *config.Config) (*app, error) {
+	client, err := db.Connect(ctx, cfg.Mongo)
+	if err != nil {
+		return nil, err
+	}
+	database := client.Database(cfg.Mongo.Database)
+
+	return &app{
+		cfg:      cfg,
+		client:   client,
+		database: database,
+
+		breakdownRepo:    repository.NewBreakdownRepository(database),
+		userRepo:         repository.NewUserRepository(database),
+		transferRepo:     repository.NewTransferRepository(database),
+		activityRepo:     repository.NewActivityRepository(database),
+		idempotencyRepo:  repository.NewIdempotencyRepository(database),
+		accessTokenRepo:  repository.NewAccessTokenRepository(database),
+		oidcStateRepo:    repository.NewOIDCStateRepository(database),
+		sessionRepo:      repository.NewSessionRepository(database),
+		auditRepo:        repository.NewAuditRepository(database),
+		workspaceRepo:    repository.NewWorkspaceRepository(database),
+		invitationRepo:   repository.NewInvitationRepository(database),
+		commentRepo:      repository.NewCommentRepository(database),
+		notificationRepo: repository.NewNotificationRepository(database),
+		attachmentRepo:   repository.NewAttachmentRepository(database),
+	}, nil
+}
+
+// close disconnects from MongoDB
+func (a *app) close(ctx context.Context) error {
+	return a.client.Disconnect(ctx)
+}
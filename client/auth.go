@@ -0,0 +1,147 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// User identifies the account a token was issued to
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// AuthResponse is returned when signing in or registering
+type AuthResponse struct {
+	Token string `json:"token"`
+	User  User   `json:"user"`
+}
+
+// RegisterRequest is the data needed to create an account
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Profile is the signed-in user's account
+type Profile struct {
+	ID                  string     `json:"id"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	DisplayName         string     `json:"displayName"`
+	Timezone            string     `json:"timezone"`
+	TwoFactorEnabled    bool       `json:"twoFactorEnabled"`
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+}
+
+// UpdateProfileRequest changes the fields that are set and leaves the rest unchanged
+type UpdateProfileRequest struct {
+	Username    *string `json:"username,omitempty"`
+	DisplayName *string `json:"display_name,omitempty"`
+	Timezone    *string `json:"timezone,omitempty"`
+}
+
+// Login signs in with an email and password and authenticates later requests with the new
+// token. The credentials are kept so the client can sign in again when the token expires.
+// Accounts with two-factor authentication return a *TwoFactorRequiredError.
+func (c *Client) Login(ctx context.Context, email, password string) (*AuthResponse, error) {
+	var response struct {
+		AuthResponse
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/auth/login",
+		body:   map[string]string{"email": email, "password": password},
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if response.TwoFactorRequired {
+		return nil, &TwoFactorRequiredError{ChallengeToken: response.ChallengeToken}
+	}
+
+	c.mu.Lock()
+	c.credentials = &credentials{email: email, password: password}
+	c.mu.Unlock()
+	c.setToken(response.Token)
+	return &response.AuthResponse, nil
+}
+
+// LoginTwoFactor completes a login with the challenge token from a *TwoFactorRequiredError and
+// a TOTP or recovery code. The client can't sign in again on its own afterwards, so once the
+// token expires requests fail with ErrUnauthorized.
+func (c *Client) LoginTwoFactor(ctx context.Context, challengeToken, code string) (*AuthResponse, error) {
+	var response AuthResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/auth/login/2fa",
+		body:   map[string]string{"challenge_token": challengeToken, "code": code},
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.credentials = nil
+	c.mu.Unlock()
+	c.setToken(response.Token)
+	return &response, nil
+}
+
+// Register creates an account and authenticates later requests as the new user. It is not
+// retried, since the response carries a token the server doesn't store for replay.
+func (c *Client) Register(ctx context.Context, registration RegisterRequest) (*AuthResponse, error) {
+	var response AuthResponse
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/auth/register",
+		body:   registration,
+	}, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.credentials = &credentials{email: registration.Email, password: registration.Password}
+	c.mu.Unlock()
+	c.setToken(response.Token)
+	return &response, nil
+}
+
+// Profile returns the signed-in user's profile
+func (c *Client) Profile(ctx context.Context) (*Profile, error) {
+	var profile Profile
+	err := c.do(ctx, request{
+		method:        http.MethodGet,
+		path:          "/profile",
+		authenticated: true,
+		idempotent:    true,
+	}, &profile)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// UpdateProfile changes the signed-in user's username, display name or time zone
+func (c *Client) UpdateProfile(ctx context.Context, update UpdateProfileRequest) (*Profile, error) {
+	var profile Profile
+	err := c.do(ctx, request{
+		method:        http.MethodPatch,
+		path:          "/profile",
+		body:          update,
+		authenticated: true,
+		idempotent:    true,
+	}, &profile)
+	if err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Breakdown is a goal broken down into steps
+type Breakdown struct {
+	ID           string     `json:"id"`
+	WorkspaceID  string     `json:"workspace_id"`
+	UserID       string     `json:"user_id"`
+	Name         string     `json:"name"`
+	Description  string     `json:"description"`
+	Completed    bool       `json:"completed"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	CommentCount int64      `json:"comment_count"`
+}
+
+// BreakdownRequest is the data for creating a breakdown or replacing one's fields
+type BreakdownRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
+}
+
+// ListBreakdowns returns the breakdowns in the signed-in user's personal workspace
+func (c *Client) ListBreakdowns(ctx context.Context) ([]Breakdown, error) {
+	var breakdowns []Breakdown
+	err := c.do(ctx, request{
+		method:        http.MethodGet,
+		path:          "/breakdowns",
+		authenticated: true,
+		idempotent:    true,
+	}, &breakdowns)
+	return breakdowns, err
+}
+
+// GetBreakdown returns a breakdown by ID
+func (c *Client) GetBreakdown(ctx context.Context, id string) (*Breakdown, error) {
+	var breakdown Breakdown
+	err := c.do(ctx, request{
+		method:        http.MethodGet,
+		path:          "/breakdowns/" + url.PathEscape(id),
+		authenticated: true,
+		idempotent:    true,
+	}, &breakdown)
+	if err != nil {
+		return nil, err
+	}
+	return &breakdown, nil
+}
+
+// CreateBreakdown creates a breakdown in the signed-in user's personal workspace. It is retried
+// with an Idempotency-Key, so a retry never creates a second breakdown.
+func (c *Client) CreateBreakdown(ctx context.Context, breakdown BreakdownRequest) (*Breakdown, error) {
+	var created Breakdown
+	err := c.do(ctx, request{
+		method:             http.MethodPost,
+		path:               "/breakdowns",
+		body:               breakdown,
+		authenticated:      true,
+		withIdempotencyKey: true,
+	}, &created)
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// UpdateBreakdown replaces a breakdown's name, description and completion
+func (c *Client) UpdateBreakdown(ctx context.Context, id string, breakdown BreakdownRequest) (*Breakdown, error) {
+	var updated Breakdown
+	err := c.do(ctx, request{
+		method:        http.MethodPut,
+		path:          "/breakdowns/" + url.PathEscape(id),
+		body:          breakdown,
+		authenticated: true,
+		idempotent:    true,
+	}, &updated)
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteBreakdown deletes a breakdown and its comments
+func (c *Client) DeleteBreakdown(ctx context.Context, id string) error {
+	return c.do(ctx, request{
+		method:        http.MethodDelete,
+		path:          "/breakdowns/" + url.PathEscape(id),
+		authenticated: true,
+		idempotent:    true,
+	}, nil)
+}
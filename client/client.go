@@ -0,0 +1,353 @@
+// Package client is a Go client for the flow API.
+//
+// A Client signs in with an email and password (or uses a personal access token), keeps the
+// token fresh by signing in again before it expires or when the server rejects it, and retries
+// idempotent requests that fail with a network error, 429 or a 5xx gateway error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is how many times an idempotent request is retried by default
+	DefaultMaxRetries = 3
+	// DefaultBackoff is the delay before the first retry; it doubles with each attempt
+	DefaultBackoff = 200 * time.Millisecond
+	// maxBackoff caps the delay between retries, including delays requested with Retry-After
+	maxBackoff = 30 * time.Second
+	// refreshBefore is how long before a token expires the client signs in again
+	refreshBefore = 30 * time.Second
+
+	idempotencyKeyHeader = "Idempotency-Key"
+	requestIDHeader      = "X-Request-ID"
+)
+
+// Client calls the flow API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	maxRetries int
+	backoff    time.Duration
+
+	signInMu    sync.Mutex
+	mu          sync.Mutex
+	token       string
+	expiresAt   time.Time
+	credentials *credentials
+}
+
+type credentials struct {
+	email    string
+	password string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. The default is http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithToken authenticates requests with a token, such as a personal access token
+func WithToken(token string) Option {
+	return func(c *Client) { c.setToken(token) }
+}
+
+// WithCredentials signs in with an email and password on the first authenticated request, and
+// again whenever the token expires
+func WithCredentials(email, password string) Option {
+	return func(c *Client) { c.credentials = &credentials{email: email, password: password} }
+}
+
+// WithRetries sets how many times idempotent requests are retried and the delay before the
+// first retry. Zero retries turns retrying off.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+// New returns a client for the API at baseURL, e.g. https://api.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		userAgent:  "flow-go-client",
+		maxRetries: DefaultMaxRetries,
+		backoff:    DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Token returns the token requests are currently authenticated with
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+func (c *Client) setToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.expiresAt = tokenExpiry(token)
+}
+
+// request describes one API call
+type request struct {
+	method        string
+	path          string
+	body          interface{}
+	authenticated bool
+	// idempotent requests (GET, PUT, DELETE) are safe to retry
+	idempotent bool
+	// withIdempotencyKey makes a POST safe to retry by sending an Idempotency-Key the server deduplicates on
+	withIdempotencyKey bool
+}
+
+// do sends a request, retrying and re-authenticating as needed, and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return err
+		}
+	}
+	// The same key is sent with every attempt so the server runs the request at most once
+	retryable := req.idempotent
+	var idempotencyKey string
+	if req.withIdempotencyKey {
+		idempotencyKey = newIdempotencyKey()
+		retryable = idempotencyKey != ""
+	}
+
+	reauthenticated := false
+	for attempt := 0; ; attempt++ {
+		var token string
+		if req.authenticated {
+			var err error
+			if token, err = c.validToken(ctx); err != nil {
+				return err
+			}
+		}
+
+		resp, err := c.send(ctx, req, body, token, idempotencyKey)
+		if err != nil {
+			if ctx.Err() != nil || !retryable || attempt >= c.maxRetries {
+				return err
+			}
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return err
+			}
+			continue
+		}
+
+		apiErr := responseError(resp)
+		if apiErr == nil {
+			err := decode(resp, out)
+			resp.Body.Close()
+			return err
+		}
+		resp.Body.Close()
+
+		// An expired or revoked token: sign in again once and repeat the request
+		if apiErr.StatusCode == http.StatusUnauthorized && req.authenticated && !reauthenticated && c.canSignIn() {
+			reauthenticated = true
+			if err := c.signIn(ctx, token); err != nil {
+				return err
+			}
+			attempt--
+			continue
+		}
+
+		if !retryable || !apiErr.Temporary() || attempt >= c.maxRetries {
+			return apiErr
+		}
+		if err := c.wait(ctx, attempt, apiErr.RetryAfter); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte, token, idempotencyKey string) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, reader)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	if idempotencyKey != "" {
+		httpReq.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+	return c.httpClient.Do(httpReq)
+}
+
+// wait sleeps before retry number attempt+1: the server's Retry-After if given, otherwise
+// exponential backoff with jitter
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	delay := retryAfter
+	if delay <= 0 {
+		delay = time.Duration(float64(c.backoff) * math.Pow(2, float64(attempt)))
+		// Jitter keeps clients that failed together from retrying in lockstep
+		delay = delay/2 + time.Duration(mathrand.Int63n(int64(delay/2)+1))
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// validToken returns the current token, signing in first if there is none or it is about to expire
+func (c *Client) validToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	token, expiresAt := c.token, c.expiresAt
+	c.mu.Unlock()
+
+	stale := token == "" || (!expiresAt.IsZero() && time.Until(expiresAt) < refreshBefore)
+	if stale && c.canSignIn() {
+		if err := c.signIn(ctx, token); err != nil {
+			return "", err
+		}
+		return c.Token(), nil
+	}
+	if token == "" {
+		return "", ErrNotAuthenticated
+	}
+	return token, nil
+}
+
+func (c *Client) canSignIn() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.credentials != nil
+}
+
+// signIn logs in again with the stored credentials to replace the stale token. Concurrent
+// callers with the same stale token wait for a single login.
+func (c *Client) signIn(ctx context.Context, stale string) error {
+	c.signInMu.Lock()
+	defer c.signInMu.Unlock()
+
+	c.mu.Lock()
+	creds, current := c.credentials, c.token
+	c.mu.Unlock()
+	if current != stale {
+		// Another request signed in while this one waited
+		return nil
+	}
+
+	_, err := c.Login(ctx, creds.email, creds.password)
+	return err
+}
+
+// decode reads a JSON response body into out, if out is not nil
+func decode(resp *http.Response, out interface{}) error {
+	if out == nil {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s response: %w", resp.Request.URL.Path, err)
+	}
+	return nil
+}
+
+// responseError returns the API error for an unsuccessful response, or nil
+func responseError(resp *http.Response) *Error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+
+	apiErr := &Error{
+		StatusCode: resp.StatusCode,
+		RequestID:  resp.Header.Get(requestIDHeader),
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err == nil {
+		apiErr.Message = body.Error
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it, so the client can sign in again
+// before the token is rejected. Tokens that aren't JWTs, such as access tokens, never expire here.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.ExpiresAt, 0)
+}
+
+// newIdempotencyKey returns a random key, or "" if none could be generated, in which case
+// the request is made once without retries
+func newIdempotencyKey() string {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(key)
+}
@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"server/client"
+	"server/config"
+	"server/db"
+	"server/db/migrations"
+	"server/health"
+	"server/mail"
+	"server/router"
+	"server/storage"
+	"server/utils"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newServer serves the real router backed by a throwaway database on the MongoDB server at
+// TEST_MONGO_URI. Tests using it are skipped when the variable isn't set.
+func newServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+
+	cfg := config.Default()
+	cfg.Mongo.URI = uri
+	cfg.Mongo.Database = "flow_client_test_" + primitive.NewObjectID().Hex()
+	cfg.Auth.JWTAlgorithm = "HS256"
+	cfg.Auth.JWTSecret = "client-test-secret-client-test-secret"
+	utils.ConfigureTokens(nil, cfg.Auth.JWTSecret, cfg.Auth.Issuer, cfg.Auth.Audience)
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	mongoClient, err := db.Connect(ctx, cfg.Mongo)
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	database := mongoClient.Database(cfg.Mongo.Database)
+	t.Cleanup(func() {
+		database.Drop(ctx)
+		mongoClient.Disconnect(ctx)
+	})
+	if _, err := migrations.New(database).Up(ctx); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	blobStore, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(router.New(router.Dependencies{
+		Config:    cfg,
+		Database:  database,
+		Mailer:    mail.LogMailer{},
+		BlobStore: blobStore,
+		Health:    health.NewRegistry(),
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// register creates an account with a unique email through a new client
+func register(t *testing.T, server *httptest.Server) (*client.Client, client.RegisterRequest) {
+	t.Helper()
+	id := primitive.NewObjectID().Hex()
+	registration := client.RegisterRequest{
+		Username: "user" + id,
+		Email:    id + "@example.com",
+		Password: "correct horse battery staple",
+	}
+	c := client.New(server.URL)
+	if _, err := c.Register(context.Background(), registration); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return c, registration
+}
+
+func TestRegisterLoginAndProfile(t *testing.T) {
+	server := newServer(t)
+	ctx := context.Background()
+	c, registration := register(t, server)
+
+	profile, err := c.Profile(ctx)
+	if err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+	if profile.Email != registration.Email || profile.Username != registration.Username {
+		t.Errorf("Profile = %+v, want the registered user", profile)
+	}
+
+	displayName := "Ada Lovelace"
+	profile, err = c.UpdateProfile(ctx, client.UpdateProfileRequest{DisplayName: &displayName})
+	if err != nil {
+		t.Fatalf("UpdateProfile: %v", err)
+	}
+	if profile.DisplayName != displayName {
+		t.Errorf("DisplayName = %q, want %q", profile.DisplayName, displayName)
+	}
+
+	other := client.New(server.URL)
+	auth, err := other.Login(ctx, registration.Email, registration.Password)
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if auth.Token == "" || auth.User.ID != profile.ID {
+		t.Errorf("Login = %+v, want a token for user %s", auth, profile.ID)
+	}
+}
+
+func TestBreakdownCRUD(t *testing.T) {
+	server := newServer(t)
+	ctx := context.Background()
+	c, _ := register(t, server)
+
+	created, err := c.CreateBreakdown(ctx, client.BreakdownRequest{Name: "Plan a trip", Description: "- Book a hotel"})
+	if err != nil {
+		t.Fatalf("CreateBreakdown: %v", err)
+	}
+	if created.ID == "" || created.Name != "Plan a trip" || created.Completed {
+		t.Errorf("CreateBreakdown = %+v", created)
+	}
+
+	fetched, err := c.GetBreakdown(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetBreakdown: %v", err)
+	}
+	if fetched.Description != created.Description {
+		t.Errorf("GetBreakdown description = %q, want %q", fetched.Description, created.Description)
+	}
+
+	updated, err := c.UpdateBreakdown(ctx, created.ID, client.BreakdownRequest{Name: "Plan a trip", Completed: true})
+	if err != nil {
+		t.Fatalf("UpdateBreakdown: %v", err)
+	}
+	if !updated.Completed || updated.CompletedAt == nil {
+		t.Errorf("UpdateBreakdown = %+v, want completed", updated)
+	}
+
+	list, err := c.ListBreakdowns(ctx)
+	if err != nil {
+		t.Fatalf("ListBreakdowns: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != created.ID {
+		t.Errorf("ListBreakdowns = %+v, want only %s", list, created.ID)
+	}
+
+	if err := c.DeleteBreakdown(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteBreakdown: %v", err)
+	}
+	if _, err := c.GetBreakdown(ctx, created.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("GetBreakdown after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSignsInAgainWhenTokenIsRejected(t *testing.T) {
+	server := newServer(t)
+	_, registration := register(t, server)
+
+	c := client.New(server.URL, client.WithToken("not-a-valid-token"), client.WithCredentials(registration.Email, registration.Password))
+	if _, err := c.Profile(context.Background()); err != nil {
+		t.Fatalf("Profile: %v", err)
+	}
+	if c.Token() == "not-a-valid-token" {
+		t.Error("token was not replaced after signing in again")
+	}
+}
+
+func TestServerErrorsAreTyped(t *testing.T) {
+	server := newServer(t)
+	ctx := context.Background()
+	c, registration := register(t, server)
+
+	_, err := client.New(server.URL).Login(ctx, registration.Email, "wrong password")
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("Login with a wrong password = %v, want ErrUnauthorized", err)
+	}
+
+	_, err = client.New(server.URL).Register(ctx, registration)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrBadRequest) || apiErr.Message == "" || apiErr.RequestID == "" {
+		t.Errorf("Register with a taken email = %#v, want a 400 *Error with a message and request ID", err)
+	}
+
+	if _, err := c.CreateBreakdown(ctx, client.BreakdownRequest{}); !errors.Is(err, client.ErrBadRequest) {
+		t.Errorf("CreateBreakdown without a name = %v, want ErrBadRequest", err)
+	}
+
+	if _, err := client.New(server.URL).Profile(ctx); !errors.Is(err, client.ErrNotAuthenticated) {
+		t.Errorf("Profile without a token = %v, want ErrNotAuthenticated", err)
+	}
+}
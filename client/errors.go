@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Sentinel errors matched by errors.Is against an *Error with the corresponding status
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrServer       = errors.New("server error")
+)
+
+// ErrNotAuthenticated is returned when an authenticated call is made without a token or credentials
+var ErrNotAuthenticated = errors.New("client has no token or credentials; call Login or use WithToken or WithCredentials")
+
+// Error is an error response from the API
+type Error struct {
+	StatusCode int           // HTTP status code
+	Message    string        // The server's error message
+	RequestID  string        // ID of the request, for finding it in the server's logs
+	RetryAfter time.Duration // How long the server asked the client to wait, if it did
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("flow API: %d %s (request %s)", e.StatusCode, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("flow API: %d %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether the error's status corresponds to target, so callers can use
+// errors.Is(err, client.ErrNotFound)
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
+
+// Temporary reports whether the request may succeed if retried: rate limiting, an unavailable
+// or overloaded server, or a conflict the server says will clear (such as a request with the
+// same Idempotency-Key still in progress)
+func (e *Error) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		return e.RetryAfter > 0
+	}
+	return false
+}
+
+// TwoFactorRequiredError is returned by Login for accounts with two-factor authentication.
+// Complete the login with LoginTwoFactor.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication required"
+}
@@ -0,0 +1,184 @@
+package client_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"server/client"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// The tests in this file use stub servers to produce failures the real router can't be made to return on demand
+
+// fakeJWT returns an unsigned token expiring at exp; the client only reads the claim
+func fakeJWT(exp time.Time) string {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	return encode(map[string]string{"alg": "none"}) + "." + encode(map[string]int64{"exp": exp.Unix()}) + ".signature"
+}
+
+func fastRetries() client.Option {
+	return client.WithRetries(3, time.Millisecond)
+}
+
+func TestRetriesTemporaryFailures(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[{"id":"1","name":"Plan a trip"}]`)
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithToken("token"), fastRetries())
+	breakdowns, err := c.ListBreakdowns(context.Background())
+	if err != nil {
+		t.Fatalf("ListBreakdowns: %v", err)
+	}
+	if len(breakdowns) != 1 || attempts.Load() != 3 {
+		t.Errorf("got %d breakdowns after %d attempts, want 1 after 3", len(breakdowns), attempts.Load())
+	}
+}
+
+func TestGivesUpAfterMaxRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithToken("token"), fastRetries())
+	_, err := c.GetBreakdown(context.Background(), "1")
+	if !errors.Is(err, client.ErrServer) {
+		t.Errorf("GetBreakdown = %v, want ErrServer", err)
+	}
+	if attempts.Load() != 4 {
+		t.Errorf("attempts = %d, want 4", attempts.Load())
+	}
+}
+
+func TestRetriesCreateWithTheSameIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		first := len(keys) == 1
+		mu.Unlock()
+		if first {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":"1","name":"Plan a trip"}`)
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, client.WithToken("token"), fastRetries())
+	if _, err := c.CreateBreakdown(context.Background(), client.BreakdownRequest{Name: "Plan a trip"}); err != nil {
+		t.Fatalf("CreateBreakdown: %v", err)
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Idempotency-Key per attempt = %q, want the same non-empty key twice", keys)
+	}
+}
+
+func TestDoesNotRetryLogin(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := client.New(server.URL, fastRetries())
+	if _, err := c.Login(context.Background(), "ada@example.com", "password"); err == nil {
+		t.Fatal("Login succeeded, want an error")
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("attempts = %d, want 1", attempts.Load())
+	}
+}
+
+func TestStopsRetryingWhenContextIsDone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c := client.New(server.URL, client.WithToken("token"))
+	start := time.Now()
+	if _, err := c.Profile(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Profile = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Profile returned after %v, want it to stop at the deadline", elapsed)
+	}
+}
+
+func TestSignsInAgainBeforeTokenExpires(t *testing.T) {
+	fresh := fakeJWT(time.Now().Add(time.Hour))
+	var logins atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/auth/login":
+			logins.Add(1)
+			json.NewEncoder(w).Encode(map[string]interface{}{"token": fresh, "user": map[string]string{"id": "1"}})
+		case "/profile":
+			if r.Header.Get("Authorization") != "Bearer "+fresh {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"error":"Invalid or expired token"}`)
+				return
+			}
+			fmt.Fprint(w, `{"id":"1"}`)
+		}
+	}))
+	defer server.Close()
+
+	expiring := fakeJWT(time.Now().Add(5 * time.Second))
+	c := client.New(server.URL, client.WithToken(expiring), client.WithCredentials("ada@example.com", "password"))
+	for i := 0; i < 2; i++ {
+		if _, err := c.Profile(context.Background()); err != nil {
+			t.Fatalf("Profile: %v", err)
+		}
+	}
+	if logins.Load() != 1 || c.Token() != fresh {
+		t.Errorf("logins = %d, want 1 and the fresh token in use", logins.Load())
+	}
+}
+
+func TestErrorResponsesAreTyped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "req-123")
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"mongo: no documents in result"}`)
+	}))
+	defer server.Close()
+
+	_, err := client.New(server.URL, client.WithToken("token")).GetBreakdown(context.Background(), "1")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("GetBreakdown = %v, want *client.Error", err)
+	}
+	if !errors.Is(err, client.ErrNotFound) || errors.Is(err, client.ErrServer) {
+		t.Errorf("errors.Is mapping wrong for %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "mongo: no documents in result" || apiErr.RequestID != "req-123" {
+		t.Errorf("Error = %+v", apiErr)
+	}
+}
@@ -0,0 +1,292 @@
+// Package config loads the server configuration from, in increasing order of precedence,
+// built-in defaults, an optional YAML or TOML file, a .env file and environment variables.
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the complete server configuration
+type Config struct {
+	Server      Server                  `yaml:"server" toml:"server"`
+	Mongo       Mongo                   `yaml:"mongo" toml:"mongo"`
+	Auth        Auth                    `yaml:"auth" toml:"auth"`
+	RateLimit   RateLimit               `yaml:"rate_limit" toml:"rate_limit"`
+	Mail        Mail                    `yaml:"mail" toml:"mail"`
+	Storage     Storage                 `yaml:"storage" toml:"storage"`
+	Attachments Attachments             `yaml:"attachments" toml:"attachments"`
+	Metrics     Metrics                 `yaml:"metrics" toml:"metrics"`
+	Tracing     Tracing                 `yaml:"tracing" toml:"tracing"`
+	Logging     Logging                 `yaml:"logging" toml:"logging"`
+	OIDC        map[string]OIDCProvider `yaml:"oidc" toml:"oidc"` // Keyed by provider name
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Environment     string   `yaml:"environment" toml:"environment" env:"ENVIRONMENT"` // development or production; development allows the seed command
+	Port            int      `yaml:"port" toml:"port" env:"PORT"`
+	AppURL          string   `yaml:"app_url" toml:"app_url" env:"APP_URL"` // Base URL used in links sent by email
+	ReadTimeout     Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	WriteTimeout    Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout     Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"` // How long shutdown may take in all: draining requests, stopping workers, closing connections
+	ShutdownDelay   Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SHUTDOWN_DELAY"`       // How long to keep serving while failing readiness, so load balancers can stop routing here
+
+	// IPs or CIDR ranges of reverse proxies whose X-Forwarded-For and X-Real-IP headers are believed
+	// (comma separated in TRUSTED_PROXIES). With none, the client IP is always the connecting address.
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+}
+
+// Mongo configures the database connection
+type Mongo struct {
+	URI              string   `yaml:"uri" toml:"uri" env:"MONGO_URI" secret:"url"`
+	Database         string   `yaml:"database" toml:"database" env:"MONGO_DATABASE"`
+	ConnectTimeout   Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"MONGO_CONNECT_TIMEOUT"`
+	MigrateOnStartup bool     `yaml:"migrate_on_startup" toml:"migrate_on_startup" env:"MIGRATE_ON_STARTUP"` // Apply pending migrations before serving
+}
+
+// Auth configures token signing
+type Auth struct {
+	JWTAlgorithm string   `yaml:"jwt_algorithm" toml:"jwt_algorithm" env:"JWT_ALGORITHM"`      // HS256, RS256 or EdDSA
+	JWTSecret    string   `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true"` // Only used with HS256
+	Issuer       string   `yaml:"issuer" toml:"issuer" env:"JWT_ISSUER"`
+	Audience     string   `yaml:"audience" toml:"audience" env:"JWT_AUDIENCE"`
+	KeyRotation  Duration `yaml:"key_rotation" toml:"key_rotation" env:"JWT_KEY_ROTATION"` // How often asymmetric signing keys are replaced
+	KeyOverlap   Duration `yaml:"key_overlap" toml:"key_overlap" env:"JWT_KEY_OVERLAP"`    // How long retired keys still verify tokens
+
+	// Base64-encoded 32-byte key that encrypts asymmetric private keys stored in the database.
+	// Without it they are stored unencrypted, readable by anyone with database access.
+	KeyEncryptionKey string `yaml:"key_encryption_key" toml:"key_encryption_key" env:"JWT_KEY_ENCRYPTION_KEY" secret:"true"`
+}
+
+// DecodeKeyEncryptionKey returns the key encryption key, or nil if none is configured
+func (a Auth) DecodeKeyEncryptionKey() ([]byte, error) {
+	if a.KeyEncryptionKey == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(a.KeyEncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("auth.key_encryption_key (JWT_KEY_ENCRYPTION_KEY) must be 32 bytes, base64-encoded")
+	}
+	return key, nil
+}
+
+// RateLimit configures where rate limit counters are kept and how many requests each route group
+// allows per window. Authentication routes are limited per client IP and per account, the rest of
+// the API per client IP.
+type RateLimit struct {
+	Store            string   `yaml:"store" toml:"store" env:"RATE_LIMIT_STORE"` // memory, or mongo to share limits between instances
+	AuthIPLimit      int      `yaml:"auth_ip_limit" toml:"auth_ip_limit" env:"RATE_LIMIT_AUTH_IP"`
+	AuthAccountLimit int      `yaml:"auth_account_limit" toml:"auth_account_limit" env:"RATE_LIMIT_AUTH_ACCOUNT"`
+	AuthWindow       Duration `yaml:"auth_window" toml:"auth_window" env:"RATE_LIMIT_AUTH_WINDOW"`
+	APIIPLimit       int      `yaml:"api_ip_limit" toml:"api_ip_limit" env:"RATE_LIMIT_API_IP"`
+	APIWindow        Duration `yaml:"api_window" toml:"api_window" env:"RATE_LIMIT_API_WINDOW"`
+}
+
+// Mail configures outgoing email; without an SMTP address messages are only logged
+type Mail struct {
+	SMTPAddr string `yaml:"smtp_addr" toml:"smtp_addr" env:"SMTP_ADDR"` // host:port
+	From     string `yaml:"from" toml:"from" env:"SMTP_FROM"`
+	Username string `yaml:"username" toml:"username" env:"SMTP_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"SMTP_PASSWORD" secret:"true"`
+}
+
+// Storage configures where attachment contents are kept
+type Storage struct {
+	Backend string `yaml:"backend" toml:"backend" env:"BLOB_STORE"` // local or s3
+	Dir     string `yaml:"dir" toml:"dir" env:"BLOB_DIR"`           // Directory for the local backend
+	S3      S3     `yaml:"s3" toml:"s3"`
+}
+
+// S3 configures an S3-compatible blob store
+type S3 struct {
+	Endpoint        string `yaml:"endpoint" toml:"endpoint" env:"S3_ENDPOINT"`
+	Region          string `yaml:"region" toml:"region" env:"S3_REGION"`
+	Bucket          string `yaml:"bucket" toml:"bucket" env:"S3_BUCKET"`
+	AccessKeyID     string `yaml:"access_key_id" toml:"access_key_id" env:"S3_ACCESS_KEY_ID"`
+	SecretAccessKey string `yaml:"secret_access_key" toml:"secret_access_key" env:"S3_SECRET_ACCESS_KEY" secret:"true"`
+}
+
+// Attachments configures file uploads
+type Attachments struct {
+	MaxBytes int64 `yaml:"max_bytes" toml:"max_bytes" env:"ATTACHMENT_MAX_BYTES"`
+}
+
+// OIDCProvider configures login with an external OpenID Connect provider
+type OIDCProvider struct {
+	Issuer       string   `yaml:"issuer" toml:"issuer"`
+	ClientID     string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret" secret:"true"`
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+}
+
+// Metrics configures the Prometheus endpoint
+type Metrics struct {
+	Addr string `yaml:"addr" toml:"addr" env:"METRICS_ADDR"` // Serve /metrics on this separate listener instead of the API port, e.g. "127.0.0.1:9090"
+}
+
+// Tracing configures OpenTelemetry tracing
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER"` // none, stdout or otlp
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"OTLP_ENDPOINT"`    // OTLP/HTTP collector URL, e.g. http://localhost:4318
+	ServiceName string  `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"` // Fraction of new traces recorded; callers' sampling decisions are kept
+}
+
+// Logging configures the application log
+type Logging struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL"`    // debug, info, warn or error
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT"` // json or text
+}
+
+// Default returns the configuration used where nothing else is set
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Environment:     "production",
+			Port:            8080,
+			AppURL:          "http://localhost:8080",
+			ReadTimeout:     Duration{30 * time.Second},
+			WriteTimeout:    Duration{60 * time.Second},
+			IdleTimeout:     Duration{2 * time.Minute},
+			ShutdownTimeout: Duration{20 * time.Second},
+		},
+		Mongo: Mongo{
+			Database:         "flow",
+			ConnectTimeout:   Duration{10 * time.Second},
+			MigrateOnStartup: true,
+		},
+		Auth: Auth{
+			JWTAlgorithm: "HS256",
+			Issuer:       "flow",
+			Audience:     "flow-api",
+			KeyRotation:  Duration{30 * 24 * time.Hour},
+			KeyOverlap:   Duration{24 * time.Hour},
+		},
+		RateLimit: RateLimit{
+			Store:            "memory",
+			AuthIPLimit:      20,
+			AuthAccountLimit: 5,
+			AuthWindow:       Duration{time.Minute},
+			APIIPLimit:       300,
+			APIWindow:        Duration{time.Minute},
+		},
+		Storage: Storage{
+			Backend: "local",
+			Dir:     "data/blobs",
+			S3: S3{
+				Region: "us-east-1",
+			},
+		},
+		Attachments: Attachments{
+			MaxBytes: 10 << 20,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "flow",
+			SampleRatio: 1,
+		},
+		Logging: Logging{
+			Level:  "info",
+			Format: "json",
+		},
+		OIDC: map[string]OIDCProvider{},
+	}
+}
+
+// Load builds the configuration. The file at path (or CONFIG_FILE when path is empty) is optional;
+// a missing .env file is ignored. All problems are reported together.
+func Load(path string) (*Config, error) {
+	dotenv, err := godotenv.Read()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("reading .env: %w", err)
+	}
+	return load(path, func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := dotenv[key]
+		return value, ok
+	})
+}
+
+// load builds the configuration from defaults, the file at path (or CONFIG_FILE) and the variables
+// found by lookup
+func load(path string, lookup func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	if path == "" {
+		path, _ = lookup("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	errs := applyEnv(cfg, lookup)
+	errs = append(errs, cfg.Validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// loadFile overlays a YAML or TOML file, chosen by extension, onto the configuration.
+// Unknown keys are rejected so typos don't go unnoticed.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(c)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(c)
+	default:
+		return fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Duration is a time.Duration written as a string such as "15m" in config files
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
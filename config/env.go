@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// applyEnv overrides fields tagged with env from the environment, then configures OIDC providers.
+// OIDC_PROVIDERS lists provider names (comma separated) and each provider NAME is configured with
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL
+// and OIDC_<NAME>_SCOPES (space separated).
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) []error {
+	errs := applyEnvFields(reflect.ValueOf(cfg).Elem(), lookup)
+
+	// An empty oidc section in the config file leaves the map nil
+	if cfg.OIDC == nil {
+		cfg.OIDC = map[string]OIDCProvider{}
+	}
+	names, _ := lookup("OIDC_PROVIDERS")
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		provider := cfg.OIDC[name]
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		for suffix, target := range map[string]*string{
+			"ISSUER":        &provider.Issuer,
+			"CLIENT_ID":     &provider.ClientID,
+			"CLIENT_SECRET": &provider.ClientSecret,
+			"REDIRECT_URL":  &provider.RedirectURL,
+		} {
+			if value, ok := lookup(prefix + suffix); ok {
+				*target = value
+			}
+		}
+		if value, ok := lookup(prefix + "SCOPES"); ok {
+			provider.Scopes = strings.Fields(value)
+		}
+		cfg.OIDC[name] = provider
+	}
+
+	return errs
+}
+
+// applyEnvFields walks a struct, setting tagged fields from the environment and recursing into nested sections
+func applyEnvFields(section reflect.Value, lookup func(string) (string, bool)) []error {
+	var errs []error
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Field(i)
+		key := section.Type().Field(i).Tag.Get("env")
+
+		if key == "" {
+			if field.Kind() == reflect.Struct {
+				errs = append(errs, applyEnvFields(field, lookup)...)
+			}
+			continue
+		}
+
+		value, ok := lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errs
+}
+
+// setField parses value into a config field according to its type
+func setField(field reflect.Value, value string) error {
+	if unmarshaler, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", value)
+		}
+		field.SetInt(parsed)
+	case reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		field.SetFloat(parsed)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", value)
+		}
+		field.SetBool(parsed)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported config field type %s", field.Type())
+		}
+		// Comma separated, ignoring blanks
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config field type %s", field.Type())
+	}
+	return nil
+}
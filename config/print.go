@@ -0,0 +1,67 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secret values when the configuration is printed
+const redacted = "REDACTED"
+
+// Redacted returns a copy of the configuration with secrets replaced. Fields tagged secret:"url"
+// keep everything but the password.
+func (c *Config) Redacted() *Config {
+	copied := *c
+	copied.OIDC = make(map[string]OIDCProvider, len(c.OIDC))
+	for name, provider := range c.OIDC {
+		redactFields(reflect.ValueOf(&provider).Elem())
+		copied.OIDC[name] = provider
+	}
+	redactFields(reflect.ValueOf(&copied).Elem())
+	return &copied
+}
+
+// Print writes the configuration as YAML with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func redactFields(section reflect.Value) {
+	for i := 0; i < section.NumField(); i++ {
+		field := section.Field(i)
+		if field.Kind() == reflect.Struct {
+			redactFields(field)
+			continue
+		}
+		if field.Kind() != reflect.String || field.String() == "" {
+			continue
+		}
+
+		switch section.Type().Field(i).Tag.Get("secret") {
+		case "true":
+			field.SetString(redacted)
+		case "url":
+			field.SetString(redactURL(field.String()))
+		}
+	}
+}
+
+// redactURL hides the password in a connection string, or the whole value if it can't be parsed
+func redactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return redacted
+	}
+	if _, hasPassword := parsed.User.Password(); hasPassword {
+		parsed.User = url.UserPassword(parsed.User.Username(), redacted)
+	}
+	return parsed.String()
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Validate checks the configuration, returning every problem found
+func (c *Config) Validate() []error {
+	var errs []error
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Environment != "development" && c.Server.Environment != "production" {
+		fail("server.environment (ENVIRONMENT) must be development or production")
+	}
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		fail("server.port (PORT) must be between 1 and 65535")
+	}
+	if !isAbsoluteURL(c.Server.AppURL) {
+		fail("server.app_url (APP_URL) must be an absolute http(s) URL")
+	}
+	for _, timeout := range []struct {
+		name  string
+		value Duration
+	}{
+		{"server.read_timeout (HTTP_READ_TIMEOUT)", c.Server.ReadTimeout},
+		{"server.write_timeout (HTTP_WRITE_TIMEOUT)", c.Server.WriteTimeout},
+		{"server.idle_timeout (HTTP_IDLE_TIMEOUT)", c.Server.IdleTimeout},
+		{"server.shutdown_timeout (SHUTDOWN_TIMEOUT)", c.Server.ShutdownTimeout},
+	} {
+		if timeout.value.Duration <= 0 {
+			fail("%s must be positive", timeout.name)
+		}
+	}
+	if c.Server.ShutdownDelay.Duration < 0 {
+		fail("server.shutdown_delay (SHUTDOWN_DELAY) must not be negative")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			fail("server.trusted_proxies (TRUSTED_PROXIES) entry %q must be an IP address or CIDR range", proxy)
+		}
+	}
+
+	if c.Mongo.URI == "" {
+		fail("mongo.uri (MONGO_URI) is required")
+	}
+	if c.Mongo.Database == "" || strings.ContainsAny(c.Mongo.Database, `/\. "$`) {
+		fail("mongo.database (MONGO_DATABASE) must be a valid database name")
+	}
+	if c.Mongo.ConnectTimeout.Duration <= 0 {
+		fail("mongo.connect_timeout (MONGO_CONNECT_TIMEOUT) must be positive")
+	}
+
+	switch c.Auth.JWTAlgorithm {
+	case "HS256":
+		if c.Auth.JWTSecret == "" {
+			fail("auth.jwt_secret (JWT_SECRET) is required when auth.jwt_algorithm is HS256")
+		}
+	case "RS256", "EdDSA":
+		if c.Auth.KeyRotation.Duration <= 0 {
+			fail("auth.key_rotation (JWT_KEY_ROTATION) must be positive")
+		}
+		if c.Auth.KeyOverlap.Duration <= 0 {
+			fail("auth.key_overlap (JWT_KEY_OVERLAP) must be positive")
+		}
+		if _, err := c.Auth.DecodeKeyEncryptionKey(); err != nil {
+			errs = append(errs, err)
+		}
+	default:
+		fail("auth.jwt_algorithm (JWT_ALGORITHM) must be HS256, RS256 or EdDSA")
+	}
+	if c.Auth.Issuer == "" {
+		fail("auth.issuer (JWT_ISSUER) is required")
+	}
+	if c.Auth.Audience == "" {
+		fail("auth.audience (JWT_AUDIENCE) is required")
+	}
+
+	if c.RateLimit.Store != "memory" && c.RateLimit.Store != "mongo" {
+		fail("rate_limit.store (RATE_LIMIT_STORE) must be memory or mongo")
+	}
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{
+		{"rate_limit.auth_ip_limit (RATE_LIMIT_AUTH_IP)", c.RateLimit.AuthIPLimit},
+		{"rate_limit.auth_account_limit (RATE_LIMIT_AUTH_ACCOUNT)", c.RateLimit.AuthAccountLimit},
+		{"rate_limit.api_ip_limit (RATE_LIMIT_API_IP)", c.RateLimit.APIIPLimit},
+	} {
+		if limit.value <= 0 {
+			fail("%s must be positive", limit.name)
+		}
+	}
+	if c.RateLimit.AuthWindow.Duration <= 0 {
+		fail("rate_limit.auth_window (RATE_LIMIT_AUTH_WINDOW) must be positive")
+	}
+	if c.RateLimit.APIWindow.Duration <= 0 {
+		fail("rate_limit.api_window (RATE_LIMIT_API_WINDOW) must be positive")
+	}
+
+	if c.Mail.SMTPAddr != "" && c.Mail.From == "" {
+		fail("mail.from (SMTP_FROM) is required when mail.smtp_addr is set")
+	}
+
+	switch c.Storage.Backend {
+	case "local":
+		if c.Storage.Dir == "" {
+			fail("storage.dir (BLOB_DIR) is required for the local backend")
+		}
+	case "s3":
+		s3 := c.Storage.S3
+		if !isAbsoluteURL(s3.Endpoint) {
+			fail("storage.s3.endpoint (S3_ENDPOINT) must be an absolute http(s) URL")
+		}
+		if s3.Bucket == "" || s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+			fail("storage.s3.bucket, access_key_id and secret_access_key (S3_BUCKET, S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY) are required for the s3 backend")
+		}
+	default:
+		fail("storage.backend (BLOB_STORE) must be local or s3")
+	}
+
+	if c.Attachments.MaxBytes <= 0 {
+		fail("attachments.max_bytes (ATTACHMENT_MAX_BYTES) must be positive")
+	}
+
+	if c.Metrics.Addr != "" {
+		if _, port, err := net.SplitHostPort(c.Metrics.Addr); err != nil || port == strconv.Itoa(c.Server.Port) {
+			fail("metrics.addr (METRICS_ADDR) must be a host:port different from the API port")
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if !isAbsoluteURL(c.Tracing.Endpoint) {
+			fail("tracing.endpoint (OTLP_ENDPOINT) must be an absolute http(s) URL for the otlp exporter")
+		}
+	default:
+		fail("tracing.exporter (TRACING_EXPORTER) must be none, stdout or otlp")
+	}
+	if c.Tracing.ServiceName == "" {
+		fail("tracing.service_name (TRACING_SERVICE_NAME) is required")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Logging.Level)); err != nil {
+		fail("logging.level (LOG_LEVEL) must be debug, info, warn or error")
+	}
+	if c.Logging.Format != "json" && c.Logging.Format != "text" {
+		fail("logging.format (LOG_FORMAT) must be json or text")
+	}
+
+	for name, provider := range c.OIDC {
+		if !isAbsoluteURL(provider.Issuer) {
+			fail("oidc.%s.issuer must be an absolute http(s) URL", name)
+		}
+		if provider.ClientID == "" {
+			fail("oidc.%s.client_id is required", name)
+		}
+		if !isAbsoluteURL(provider.RedirectURL) {
+			fail("oidc.%s.redirect_url must be an absolute http(s) URL", name)
+		}
+	}
+
+	return errs
+}
+
+func isAbsoluteURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
@@ -0,0 +1,127 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// index is an index to create on a collection. Names are explicit so Down can drop exactly
+// what Up created.
+type index struct {
+	collection string
+	model      mongo.IndexModel
+}
+
+func newIndex(collection, name string, keys bson.D, opts *options.IndexOptions) index {
+	if opts == nil {
+		opts = options.Index()
+	}
+	return index{
+		collection: collection,
+		model:      mongo.IndexModel{Keys: keys, Options: opts.SetName(name)},
+	}
+}
+
+// maxReportedDuplicates caps how many conflicting values a failed uniqueness check lists per index
+const maxReportedDuplicates = 20
+
+// createIndexes returns a migration step creating indexes. Unique indexes are checked for existing
+// duplicates first, so the step fails with a report of the conflicting documents rather than part
+// way through with a bare duplicate key error.
+func createIndexes(indexes ...index) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		var conflicts []string
+		for _, index := range indexes {
+			found, err := findDuplicates(ctx, db, index)
+			if err != nil {
+				return err
+			}
+			conflicts = append(conflicts, found...)
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("existing documents conflict with unique indexes; resolve them and migrate again:\n%s", strings.Join(conflicts, "\n"))
+		}
+
+		for _, index := range indexes {
+			if _, err := db.Collection(index.collection).Indexes().CreateOne(ctx, index.model); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// findDuplicates describes documents that share the key of a unique index, such as accounts created by
+// concurrent sign ups before the index existed. It returns nothing for other indexes.
+func findDuplicates(ctx context.Context, db *mongo.Database, index index) ([]string, error) {
+	opts := index.model.Options
+	if opts.Unique == nil || !*opts.Unique {
+		return nil, nil
+	}
+
+	pipeline := mongo.Pipeline{}
+	if opts.PartialFilterExpression != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: opts.PartialFilterExpression}})
+	}
+	// Keys inside arrays are unique per element, so compare elements
+	group := bson.D{}
+	unwound := map[string]bool{}
+	for _, key := range index.model.Keys.(bson.D) {
+		if array, _, nested := strings.Cut(key.Key, "."); nested && !unwound[array] {
+			unwound[array] = true
+			pipeline = append(pipeline, bson.D{{Key: "$unwind", Value: "$" + array}})
+		}
+		group = append(group, bson.E{Key: strings.ReplaceAll(key.Key, ".", "_"), Value: "$" + key.Key})
+	}
+	pipeline = append(pipeline,
+		bson.D{{Key: "$group", Value: bson.M{"_id": group, "ids": bson.M{"$push": "$_id"}, "count": bson.M{"$sum": 1}}}},
+		bson.D{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+		bson.D{{Key: "$limit", Value: maxReportedDuplicates}},
+	)
+
+	cursor, err := db.Collection(index.collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		IDs []interface{} `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+
+	conflicts := make([]string, len(groups))
+	for i, group := range groups {
+		conflicts[i] = fmt.Sprintf("%s.%s: documents %v share a key", index.collection, *opts.Name, group.IDs)
+	}
+	return conflicts, nil
+}
+
+// dropIndexes returns a migration step dropping indexes created by createIndexes
+func dropIndexes(indexes ...index) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		for _, index := range indexes {
+			_, err := db.Collection(index.collection).Indexes().DropOne(ctx, *index.model.Options.Name)
+			// The collection or index may already be gone
+			if err != nil && !isNamespaceOrIndexNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func isNamespaceOrIndexNotFound(err error) bool {
+	var commandErr mongo.CommandError
+	if !errors.As(err, &commandErr) {
+		return false
+	}
+	// NamespaceNotFound and IndexNotFound
+	return commandErr.Code == 26 || commandErr.Code == 27
+}
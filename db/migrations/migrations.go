@@ -0,0 +1,112 @@
+package migrations
+
+import (
+	"context"
+	"server/db/models"
+	"server/db/repository"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// all lists every migration in version order. Never edit or reorder a released migration;
+// add a new one instead.
+var all = []Migration{
+	{
+		Version:     1,
+		Description: "Create indexes",
+		Up:          createIndexes(initialIndexes...),
+		Down:        dropIndexes(initialIndexes...),
+	},
+	{
+		Version:     2,
+		Description: "Move breakdowns created before workspaces into personal workspaces",
+		Up: func(ctx context.Context, db *mongo.Database) error {
+			return repository.MigratePersonalWorkspaces(ctx,
+				repository.NewUserRepository(db), repository.NewWorkspaceRepository(db), repository.NewBreakdownRepository(db))
+		},
+		// Once breakdowns belong to workspaces they can be shared, so moving them back isn't safe
+		Down: nil,
+	},
+	{
+		Version:     3,
+		Description: "Allow one signing key per algorithm and rotation slot",
+		Up:          createIndexes(signingKeySlotIndex),
+		Down:        dropIndexes(signingKeySlotIndex),
+	},
+	{
+		Version:     4,
+		Description: "Index activity by breakdown, actor and type for folding repeated edits",
+		Up:          createIndexes(activityFoldIndex),
+		Down:        dropIndexes(activityFoldIndex),
+	},
+	{
+		Version:     5,
+		Description: "Remove sessions once their token has expired",
+		Up:          createIndexes(sessionExpiryIndex),
+		Down:        dropIndexes(sessionExpiryIndex),
+	},
+}
+
+// signingKeySlotIndex stops instances rotating at the same time from each creating a key. Keys
+// created before slots existed have none and are left out.
+var signingKeySlotIndex = newIndex("signing_keys", "algorithm_slot_unique", bson.D{{Key: "algorithm", Value: 1}, {Key: "slot", Value: 1}},
+	options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"slot": bson.M{"$exists": true}}))
+
+// activityFoldIndex finds a user's latest edit of one kind to a breakdown when recording another
+var activityFoldIndex = newIndex("activities", "breakdown_actor_type", bson.D{
+	{Key: "breakdown_id", Value: 1}, {Key: "actor_id", Value: 1}, {Key: "type", Value: 1}, {Key: "_id", Value: -1},
+}, nil)
+
+// sessionExpiryIndex removes sessions nobody can use any more, so the session list doesn't grow forever
+var sessionExpiryIndex = newIndex("sessions", "expires_at_ttl", bson.D{{Key: "expires_at", Value: 1}}, expireAtField())
+
+// expireAtField removes documents once the time in their expires_at field has passed
+func expireAtField() *options.IndexOptions {
+	return options.Index().SetExpireAfterSeconds(0)
+}
+
+var initialIndexes = []index{
+	// Users: unique sign-in identifiers, linked identities and the account deletion worker's query
+	newIndex("users", "email_unique", bson.D{{Key: "email", Value: 1}}, options.Index().SetUnique(true)),
+	newIndex("users", "username_unique", bson.D{{Key: "username", Value: 1}}, options.Index().SetUnique(true)),
+	newIndex("users", "identity_unique", bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+		options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}})),
+	newIndex("users", "deletion_scheduled_at", bson.D{{Key: "deletion_scheduled_at", Value: 1}}, options.Index().SetSparse(true)),
+
+	// Breakdowns are listed per workspace and per creator
+	newIndex("breakdowns", "workspace_id", bson.D{{Key: "workspace_id", Value: 1}, {Key: "_id", Value: -1}}, nil),
+	newIndex("breakdowns", "user_id", bson.D{{Key: "user_id", Value: 1}}, nil),
+
+	// Workspaces: one personal workspace per user, and one membership per user per workspace
+	newIndex("workspaces", "personal_owner_unique", bson.D{{Key: "owner_id", Value: 1}},
+		options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"personal": true})),
+	newIndex("workspaces", "owner_id", bson.D{{Key: "owner_id", Value: 1}}, nil),
+	newIndex("memberships", "workspace_user_unique", bson.D{{Key: "workspace_id", Value: 1}, {Key: "user_id", Value: 1}}, options.Index().SetUnique(true)),
+	newIndex("memberships", "user_id", bson.D{{Key: "user_id", Value: 1}}, nil),
+	newIndex("invitations", "token_hash_unique", bson.D{{Key: "token_hash", Value: 1}}, options.Index().SetUnique(true)),
+	newIndex("invitations", "workspace_id", bson.D{{Key: "workspace_id", Value: 1}}, nil),
+
+	// Authentication lookups, and expiry of short-lived records
+	newIndex("sessions", "user_id", bson.D{{Key: "user_id", Value: 1}}, nil),
+	newIndex("access_tokens", "token_hash_unique", bson.D{{Key: "token_hash", Value: 1}}, options.Index().SetUnique(true)),
+	newIndex("access_tokens", "user_id", bson.D{{Key: "user_id", Value: 1}}, nil),
+	newIndex("idempotency_keys", "expires_at_ttl", bson.D{{Key: "expires_at", Value: 1}}, expireAtField()),
+	newIndex("oidc_states", "expires_at_ttl", bson.D{{Key: "expires_at", Value: 1}}, expireAtField()),
+	newIndex("rate_limits", "expires_at_ttl", bson.D{{Key: "expires_at", Value: 1}}, expireAtField()),
+
+	// Transfers: incoming requests, and at most one pending transfer per breakdown
+	newIndex("transfers", "to_user_status", bson.D{{Key: "to_user_id", Value: 1}, {Key: "status", Value: 1}}, nil),
+	newIndex("transfers", "pending_breakdown_unique", bson.D{{Key: "breakdown_id", Value: 1}},
+		options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": models.TransferPending})),
+
+	// Per-breakdown lists and feeds
+	newIndex("comments", "breakdown_id", bson.D{{Key: "breakdown_id", Value: 1}, {Key: "_id", Value: 1}}, nil),
+	newIndex("notifications", "user_id", bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}, nil),
+	newIndex("activities", "workspace_id", bson.D{{Key: "workspace_id", Value: 1}, {Key: "_id", Value: -1}}, nil),
+	newIndex("activities", "breakdown_id", bson.D{{Key: "breakdown_id", Value: 1}, {Key: "_id", Value: -1}}, nil),
+	newIndex("attachments", "breakdown_id", bson.D{{Key: "breakdown_id", Value: 1}}, nil),
+	newIndex("audit_events", "user_id", bson.D{{Key: "user_id", Value: 1}, {Key: "_id", Value: -1}}, nil),
+	newIndex("audit_events", "actor_id", bson.D{{Key: "actor_id", Value: 1}, {Key: "_id", Value: -1}}, nil),
+}
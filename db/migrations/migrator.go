@@ -0,0 +1,267 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// lockID identifies the single migration lock document
+	lockID = "migrations"
+	// lockTTL is how long a lock is held before another instance may take it over,
+	// in case the holder died without releasing it. It is renewed every lockRenewInterval
+	// while a migration runs.
+	lockTTL           = 10 * time.Minute
+	lockRenewInterval = time.Minute
+	// lockRetryInterval is how often a waiting instance retries the lock
+	lockRetryInterval = 2 * time.Second
+)
+
+var (
+	ErrIrreversible = errors.New("migration cannot be reverted")
+	errLockLost     = errors.New("migration lock was taken over by another instance")
+)
+
+// Migration is one versioned change to the database. Down may be nil for migrations that can't be reverted.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+	Down        func(ctx context.Context, db *mongo.Database) error
+}
+
+// Status describes whether a migration has been applied
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// applied is the record kept for each applied migration
+type applied struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// Migrator applies and reverts migrations, recording applied versions in the schema_migrations
+// collection. A lock document in migration_locks keeps concurrent instances from racing.
+type Migrator struct {
+	db         *mongo.Database
+	migrations []Migration
+	applied    *mongo.Collection
+	locks      *mongo.Collection
+}
+
+// New returns a migrator for all of the application's migrations
+func New(db *mongo.Database) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: all,
+		applied:    db.Collection("schema_migrations"),
+		locks:      db.Collection("migration_locks"),
+	}
+}
+
+// Status lists every migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	done, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if record, ok := done[migration.Version]; ok {
+			statuses[i].AppliedAt = &record.AppliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order and returns the ones applied
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func(hold func(step func(ctx context.Context) error) error) error {
+		done, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := done[migration.Version]; ok {
+				continue
+			}
+
+			slog.Info("Applying migration", "version", migration.Version, "description", migration.Description)
+			if err := hold(func(ctx context.Context) error { return migration.Up(ctx, m.db) }); err != nil {
+				return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+			}
+			_, err := m.applied.InsertOne(ctx, applied{
+				Version:     migration.Version,
+				Description: migration.Description,
+				AppliedAt:   time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+// Down reverts the most recently applied steps migrations, newest first, and returns the ones reverted
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var ran []Migration
+	err := m.withLock(ctx, func(hold func(step func(ctx context.Context) error) error) error {
+		done, err := m.appliedVersions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(ran) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := done[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == nil {
+				return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, ErrIrreversible)
+			}
+
+			slog.Info("Reverting migration", "version", migration.Version, "description", migration.Description)
+			if err := hold(func(ctx context.Context) error { return migration.Down(ctx, m.db) }); err != nil {
+				return fmt.Errorf("reverting migration %d (%s): %w", migration.Version, migration.Description, err)
+			}
+			if _, err := m.applied.DeleteOne(ctx, bson.M{"_id": migration.Version}); err != nil {
+				return err
+			}
+			ran = append(ran, migration)
+		}
+		return nil
+	})
+	return ran, err
+}
+
+func (m *Migrator) appliedVersions(ctx context.Context) (map[int]applied, error) {
+	cursor, err := m.applied.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []applied
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]applied, len(records))
+	for _, record := range records {
+		done[record.Version] = record
+	}
+	return done, nil
+}
+
+// withLock waits for the migration lock, runs fn and releases the lock. fn runs each long-running
+// step through hold, which keeps renewing the lock while the step runs and cancels the step with
+// errLockLost if another instance has taken the lock over.
+func (m *Migrator) withLock(ctx context.Context, fn func(hold func(step func(ctx context.Context) error) error) error) error {
+	owner := primitive.NewObjectID().Hex()
+	for {
+		acquired, err := m.acquire(ctx, owner)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			break
+		}
+
+		slog.Info("Waiting for another instance to finish migrating")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+	defer func() {
+		// Release even if ctx was cancelled, so the next run doesn't wait for the TTL
+		if _, err := m.locks.DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": lockID, "owner": owner}); err != nil {
+			slog.Error("Failed to release migration lock", "error", err)
+		}
+	}()
+
+	renew := func() error {
+		result, err := m.locks.UpdateOne(ctx, bson.M{"_id": lockID, "owner": owner}, bson.M{
+			"$set": bson.M{"expires_at": time.Now().Add(lockTTL)},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errLockLost
+		}
+		return nil
+	}
+	return fn(func(step func(ctx context.Context) error) error {
+		return holdLock(ctx, renew, lockRenewInterval, step)
+	})
+}
+
+// holdLock renews the lock, then runs step while renewing it every interval. If a renewal finds the lock
+// lost, the step's context is cancelled and errLockLost returned.
+func holdLock(ctx context.Context, renew func() error, interval time.Duration, step func(ctx context.Context) error) error {
+	if err := renew(); err != nil {
+		return err
+	}
+
+	stepCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopped:
+				return
+			case <-ticker.C:
+				if err := renew(); errors.Is(err, errLockLost) {
+					cancel(errLockLost)
+					return
+				} else if err != nil {
+					// A transient failure leaves the lease to the next tick
+					slog.Warn("Failed to renew migration lock", "error", err)
+				}
+			}
+		}
+	}()
+
+	err := step(stepCtx)
+	if cause := context.Cause(stepCtx); errors.Is(cause, errLockLost) {
+		return errLockLost
+	}
+	return err
+}
+
+// acquire takes the lock if it is free or its holder's lease has expired
+func (m *Migrator) acquire(ctx context.Context, owner string) (bool, error) {
+	now := time.Now()
+	_, err := m.locks.UpdateOne(ctx,
+		bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{"owner": owner, "locked_at": now, "expires_at": now.Add(lockTTL)}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		// The lock exists and hasn't expired
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestHoldLockRenewsWhileStepRuns(t *testing.T) {
+	var renewals atomic.Int32
+	renew := func() error {
+		renewals.Add(1)
+		return nil
+	}
+
+	err := holdLock(context.Background(), renew, 5*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewals.Load() < 3 {
+		t.Errorf("lock renewed %d times during a step ten intervals long", renewals.Load())
+	}
+}
+
+func TestHoldLockCancelsStepWhenLockLost(t *testing.T) {
+	var renewals atomic.Int32
+	renew := func() error {
+		if renewals.Add(1) > 1 {
+			return errLockLost
+		}
+		return nil
+	}
+
+	err := holdLock(context.Background(), renew, 5*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	})
+	if !errors.Is(err, errLockLost) {
+		t.Errorf("holdLock = %v, want errLockLost", err)
+	}
+}
+
+func TestCreateIndexesReportsDuplicates(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("flow_migrations_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+
+	// Two accounts from racing sign ups, and two personal workspaces for one owner
+	first, second, owner := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	users := db.Collection("users")
+	users.InsertOne(ctx, bson.M{"_id": first, "email": "ada@example.com", "username": "ada"})
+	users.InsertOne(ctx, bson.M{"_id": second, "email": "ada@example.com", "username": "ada2"})
+	workspaces := db.Collection("workspaces")
+	workspaces.InsertOne(ctx, bson.M{"owner_id": owner, "personal": true})
+	workspaces.InsertOne(ctx, bson.M{"owner_id": owner, "personal": true})
+	workspaces.InsertOne(ctx, bson.M{"owner_id": owner, "personal": false})
+
+	_, err = New(db).Up(ctx)
+	if err == nil {
+		t.Fatal("migrating with duplicates succeeded")
+	}
+	for _, want := range []string{"users.email_unique", first.Hex(), second.Hex(), "workspaces.personal_owner_unique"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't mention %s: %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "username_unique") {
+		t.Errorf("error reports unique usernames as duplicates: %v", err)
+	}
+
+	// Nothing is half done
+	specs, err := users.Indexes().ListSpecifications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 {
+		t.Errorf("users has %d indexes after the failed migration, want only _id", len(specs))
+	}
+}
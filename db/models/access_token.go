@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Personal access token scopes
+const (
+	ScopeBreakdownsRead  = "breakdowns:read"
+	ScopeBreakdownsWrite = "breakdowns:write"
+	ScopeProfileRead     = "profile:read"
+)
+
+// AccessTokenScopes lists the scopes a personal access token can be granted
+var AccessTokenScopes = []string{ScopeBreakdownsRead, ScopeBreakdownsWrite, ScopeProfileRead}
+
+// AccessToken represents a personal access token used by scripts and integrations.
+type AccessToken struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`                    // MongoDB Object ID
+	UserID     primitive.ObjectID `bson:"user_id" json:"user_id"`                               // Owner of the token
+	Name       string             `bson:"name" json:"name"`                                     // Label chosen by the user
+	Scopes     []string           `bson:"scopes" json:"scopes"`                                 // Granted scopes
+	TokenHash  string             `bson:"token_hash" json:"-"`                                  // SHA-256 of the token
+	Hint       string             `bson:"hint" json:"hint"`                                     // Last characters of the token, for display
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`     // Optional expiry
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"` // Last time the token authenticated a request
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`     // Set when the token is revoked
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`                         // Creation timestamp
+}
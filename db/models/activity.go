@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Activity types
+const (
+	ActivityBreakdownCreated     = "breakdown.created"
+	ActivityBreakdownRenamed     = "breakdown.renamed"
+	ActivityBreakdownEdited      = "breakdown.edited"
+	ActivityBreakdownCompleted   = "breakdown.completed"
+	ActivityBreakdownReopened    = "breakdown.reopened"
+	ActivityBreakdownDuplicated  = "breakdown.duplicated"
+	ActivityBreakdownDeleted     = "breakdown.deleted"
+	ActivityBreakdownTransferred = "breakdown.transferred"
+	ActivityTransferDeclined     = "transfer.declined"
+)
+
+// Activity represents an entry in the activity log.
+// Rapid edits of the same kind by the same user to a breakdown are folded into one entry, counted by Count.
+// The entry keeps its ID, and so its place in the feed, from the first of them.
+type Activity struct {
+	ID          primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`                    // MongoDB Object ID
+	ActorID     primitive.ObjectID     `bson:"actor_id" json:"actor_id"`                             // User who performed the action
+	WorkspaceID primitive.ObjectID     `bson:"workspace_id,omitempty" json:"workspace_id,omitempty"` // Workspace the breakdown was in at the time
+	BreakdownID primitive.ObjectID     `bson:"breakdown_id,omitempty" json:"breakdown_id,omitempty"` // Breakdown the action relates to
+	Type        string                 `bson:"type" json:"type"`                                     // Kind of action, e.g. breakdown.transferred
+	Data        map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`                 // Extra details about the action
+	Count       int                    `bson:"count,omitempty" json:"count,omitempty"`               // Number of edits folded into this entry
+	CreatedAt   time.Time              `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time              `bson:"updated_at,omitempty" json:"updated_at,omitempty"` // Time of the latest folded edit
+}
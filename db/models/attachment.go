@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is a file uploaded to a breakdown. The contents live in blob storage under StorageKey.
+type Attachment struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"` // MongoDB Object ID
+	BreakdownID primitive.ObjectID `bson:"breakdown_id" json:"breakdown_id"`  // Breakdown the file is attached to
+	UploaderID  primitive.ObjectID `bson:"uploader_id" json:"uploader_id"`    // User who uploaded the file
+	Filename    string             `bson:"filename" json:"filename"`          // Original file name
+	ContentType string             `bson:"content_type" json:"content_type"`  // MIME type sniffed from the contents
+	Size        int64              `bson:"size" json:"size"`                  // Size in bytes
+	SHA256      string             `bson:"sha256" json:"sha256"`              // Hex-encoded checksum of the contents
+	StorageKey  string             `bson:"storage_key" json:"-"`              // Key of the blob holding the contents
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`      // Upload timestamp
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditLoginSucceeded           = "auth.login.succeeded"
+	AuditLoginFailed              = "auth.login.failed"
+	AuditRegistered               = "auth.registered"
+	AuditTokenIssued              = "auth.token.issued"
+	AuditTwoFactorEnabled         = "auth.2fa.enabled"
+	AuditTwoFactorDisabled        = "auth.2fa.disabled"
+	AuditProfileUpdated           = "profile.updated"
+	AuditPasswordChanged          = "profile.password.changed"
+	AuditPasswordReset            = "profile.password.reset"
+	AuditEmailChangeRequested     = "profile.email.change_requested"
+	AuditEmailChanged             = "profile.email.changed"
+	AuditAccountDeletionRequested = "profile.deletion.scheduled"
+	AuditAccountDeletionCancelled = "profile.deletion.cancelled"
+	AuditSessionRevoked           = "session.revoked"
+	AuditAccessTokenCreated       = "access_token.created"
+	AuditAccessTokenRevoked       = "access_token.revoked"
+	AuditBreakdownCreated         = "breakdown.created"
+	AuditBreakdownUpdated         = "breakdown.updated"
+	AuditBreakdownDeleted         = "breakdown.deleted"
+	AuditBreakdownDuplicated      = "breakdown.duplicated"
+	AuditAttachmentUploaded       = "breakdown.attachment.uploaded"
+	AuditAttachmentDeleted        = "breakdown.attachment.deleted"
+	AuditTransferRequested        = "transfer.requested"
+	AuditTransferAccepted         = "transfer.accepted"
+	AuditTransferDeclined         = "transfer.declined"
+	AuditUserDisabled             = "admin.user.disabled"
+	AuditUserEnabled              = "admin.user.enabled"
+	AuditUserPasswordReset        = "admin.user.password_reset"
+	AuditUserImpersonated         = "admin.user.impersonated"
+	AuditWorkspaceCreated         = "workspace.created"
+	AuditWorkspaceUpdated         = "workspace.updated"
+	AuditWorkspaceDeleted         = "workspace.deleted"
+	AuditMemberInvited            = "workspace.member.invited"
+	AuditInvitationRevoked        = "workspace.invitation.revoked"
+	AuditMemberJoined             = "workspace.member.joined"
+	AuditMemberRoleChanged        = "workspace.member.role_changed"
+	AuditMemberRemoved            = "workspace.member.removed"
+)
+
+// AuditEvent is an append-only record of a security-relevant action.
+type AuditEvent struct {
+	ID             primitive.ObjectID     `bson:"_id,omitempty" json:"id,omitempty"`                          // MongoDB Object ID
+	Action         string                 `bson:"action" json:"action"`                                       // What happened, e.g. breakdown.updated
+	ActorID        *primitive.ObjectID    `bson:"actor_id,omitempty" json:"actor_id,omitempty"`               // Who did it (absent for anonymous actions such as failed logins)
+	ImpersonatorID *primitive.ObjectID    `bson:"impersonator_id,omitempty" json:"impersonator_id,omitempty"` // Admin acting as the actor, if any
+	UserID         *primitive.ObjectID    `bson:"user_id,omitempty" json:"user_id,omitempty"`                 // Account the event belongs to, for the owner's view
+	TargetType     string                 `bson:"target_type,omitempty" json:"target_type,omitempty"`         // Kind of resource affected, e.g. breakdown
+	TargetID       string                 `bson:"target_id,omitempty" json:"target_id,omitempty"`             // ID of the resource affected
+	IP             string                 `bson:"ip" json:"ip"`                                               // Client IP
+	UserAgent      string                 `bson:"user_agent" json:"user_agent"`                               // Client User-Agent
+	RequestID      string                 `bson:"request_id,omitempty" json:"request_id,omitempty"`           // Request that caused the event
+	Before         map[string]interface{} `bson:"before,omitempty" json:"before,omitempty"`                   // Summary of the resource before the change
+	After          map[string]interface{} `bson:"after,omitempty" json:"after,omitempty"`                     // Summary of the resource after the change
+	Metadata       map[string]interface{} `bson:"metadata,omitempty" json:"metadata,omitempty"`               // Other details
+	CreatedAt      time.Time              `bson:"created_at" json:"created_at"`                               // When it happened
+}
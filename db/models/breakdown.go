@@ -9,9 +9,14 @@ import (
 // Breakdown
 type Breakdown struct {
 	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"` // MongoDB Object ID
+	WorkspaceID primitive.ObjectID `bson:"workspace_id" json:"workspace_id"`  // Workspace the breakdown belongs to
 	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`            // Reference to the user who owns this breakdown
 	Name        string             `bson:"name" json:"name"`
 	Description string             `bson:"description" json:"description"`
+	Completed   bool               `bson:"completed" json:"completed"`                           // Whether the breakdown has been completed
+	CompletedAt *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"` // When the breakdown was completed
 	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+
+	CommentCount int64 `bson:"-" json:"comment_count"` // Number of comments, filled in for list responses
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Comment is a Markdown message in the discussion on a breakdown.
+// Replies point at the comment they answer through ParentID.
+type Comment struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty" json:"id,omitempty"`              // MongoDB Object ID
+	BreakdownID primitive.ObjectID   `bson:"breakdown_id" json:"breakdown_id"`               // Breakdown being discussed
+	ParentID    *primitive.ObjectID  `bson:"parent_id,omitempty" json:"parent_id,omitempty"` // Comment this replies to, if any
+	AuthorID    primitive.ObjectID   `bson:"author_id" json:"author_id"`                     // User who wrote the comment
+	Body        string               `bson:"body" json:"body"`                               // Markdown source
+	Mentions    []primitive.ObjectID `bson:"mentions,omitempty" json:"mentions,omitempty"`   // Users @mentioned in the body
+	Deleted     bool                 `bson:"deleted,omitempty" json:"deleted,omitempty"`     // Deleted comments with replies keep their place in the thread
+	EditedAt    *time.Time           `bson:"edited_at,omitempty" json:"edited_at,omitempty"` // Last edit by the author
+	CreatedAt   time.Time            `bson:"created_at" json:"created_at"`                   // Creation timestamp
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// IdempotencyRecord stores the first response returned for an Idempotency-Key.
+type IdempotencyRecord struct {
+	ID          string              `bson:"_id" json:"id"`                    // Scoped key: user ID (or "anonymous") and the client key
+	RequestHash string              `bson:"request_hash" json:"request_hash"` // Hash of method, path and body of the first request
+	Completed   bool                `bson:"completed" json:"completed"`       // False while the first request is still in flight
+	Status      int                 `bson:"status,omitempty" json:"status"`   // Stored response status code
+	Header      map[string][]string `bson:"header,omitempty" json:"header"`   // Stored response headers
+	Body        []byte              `bson:"body,omitempty" json:"body"`       // Stored response body
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`     // Creation timestamp
+	ExpiresAt   time.Time           `bson:"expires_at" json:"expires_at"`     // When the record may be discarded
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification types
+const (
+	NotificationMentioned = "comment.mentioned"
+)
+
+// Notification tells a user about something that involves them.
+type Notification struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`                    // MongoDB Object ID
+	UserID      primitive.ObjectID  `bson:"user_id" json:"user_id"`                               // Recipient
+	Type        string              `bson:"type" json:"type"`                                     // Kind of notification, e.g. comment.mentioned
+	ActorID     primitive.ObjectID  `bson:"actor_id" json:"actor_id"`                             // User who caused it
+	BreakdownID primitive.ObjectID  `bson:"breakdown_id,omitempty" json:"breakdown_id,omitempty"` // Breakdown it relates to
+	CommentID   *primitive.ObjectID `bson:"comment_id,omitempty" json:"comment_id,omitempty"`     // Comment it relates to
+	ReadAt      *time.Time          `bson:"read_at,omitempty" json:"read_at,omitempty"`           // When the user read it
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`                         // Creation timestamp
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// OIDCState holds the per-login secrets of an in-progress OpenID Connect authorization.
+type OIDCState struct {
+	ID           string    `bson:"_id" json:"id"`                // The state parameter sent to the provider
+	Provider     string    `bson:"provider" json:"provider"`     // Provider the login was started with
+	Nonce        string    `bson:"nonce" json:"-"`               // Expected nonce in the ID token
+	CodeVerifier string    `bson:"code_verifier" json:"-"`       // PKCE code verifier
+	CreatedAt    time.Time `bson:"created_at" json:"created_at"` // Creation timestamp
+	ExpiresAt    time.Time `bson:"expires_at" json:"expires_at"` // The login must complete before this time
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session represents a signed-in device; every JWT token is tied to one.
+type Session struct {
+	ID                  primitive.ObjectID  `bson:"_id,omitempty" json:"id,omitempty"`                                    // MongoDB Object ID, used as the token's sid claim
+	UserID              primitive.ObjectID  `bson:"user_id" json:"user_id"`                                               // User who signed in
+	ImpersonatorID      *primitive.ObjectID `bson:"impersonator_id,omitempty" json:"impersonator_id,omitempty"`           // Admin acting as the user, for support sessions
+	ImpersonationReason string              `bson:"impersonation_reason,omitempty" json:"impersonation_reason,omitempty"` // Why the admin needed to act as the user
+	UserAgent           string              `bson:"user_agent" json:"user_agent"`                                         // User-Agent of the client that signed in
+	IP                  string              `bson:"ip" json:"ip"`                                                         // Client IP at sign in
+	Method              string              `bson:"method,omitempty" json:"method,omitempty"`                             // First factor used to sign in: password or oidc:<provider>
+	CreatedAt           time.Time           `bson:"created_at" json:"created_at"`                                         // Sign in time
+	LastSeenAt          time.Time           `bson:"last_seen_at" json:"last_seen_at"`                                     // Last authenticated request
+	ExpiresAt           time.Time           `bson:"expires_at" json:"expires_at"`                                         // When the session's token expires
+	RevokedAt           *time.Time          `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`                     // Set when the session is signed out
+}
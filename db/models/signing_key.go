@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SigningKey is a private key used to sign JWT tokens, identified in tokens by its kid.
+type SigningKey struct {
+	ID          string    `bson:"_id" json:"kid"`                   // Key ID (kid header)
+	Algorithm   string    `bson:"algorithm" json:"alg"`             // RS256 or EdDSA
+	Slot        int64     `bson:"slot,omitempty" json:"slot"`       // Rotation slot the key signs for; at most one key per algorithm and slot
+	PrivateKey  []byte    `bson:"private_key" json:"-"`             // PKCS #8 DER encoded private key, encrypted when Encryption is set
+	Encryption  string    `bson:"encryption,omitempty" json:"-"`    // aes-256-gcm when PrivateKey is encrypted with the key encryption key
+	ActiveFrom  time.Time `bson:"active_from" json:"active_from"`   // New tokens are signed with this key from this time
+	ActiveUntil time.Time `bson:"active_until" json:"active_until"` // ...until this time
+	ExpiresAt   time.Time `bson:"expires_at" json:"expires_at"`     // Tokens signed with this key are accepted until this time
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`     // Creation timestamp
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Transfer statuses
+const (
+	TransferPending  = "pending"
+	TransferAccepted = "accepted"
+	TransferDeclined = "declined"
+)
+
+// Transfer represents a pending or resolved hand-over of a breakdown to another user.
+type Transfer struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"` // MongoDB Object ID
+	BreakdownID primitive.ObjectID `bson:"breakdown_id" json:"breakdown_id"`  // Breakdown being transferred
+	FromUserID  primitive.ObjectID `bson:"from_user_id" json:"from_user_id"`  // Current owner
+	ToUserID    primitive.ObjectID `bson:"to_user_id" json:"to_user_id"`      // Recipient who must accept
+	Status      string             `bson:"status" json:"status"`              // pending, accepted or declined
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -6,12 +6,48 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Identity links a user to an account at an external OpenID Connect provider.
+type Identity struct {
+	Provider string `bson:"provider" json:"provider"` // Configured provider name
+	Subject  string `bson:"subject" json:"subject"`   // Provider's stable user identifier (sub claim)
+}
+
+// User roles
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User represents a user document in the MongoDB collection.
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"` // MongoDB Object ID
-	Username  string             `bson:"username" json:"username"`          // Username of the user
-	Email     string             `bson:"email" json:"email"`                // Email address
-	Password  string             `bson:"password" json:"password"`          // Hashed password
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`      // Creation timestamp
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`      // Update timestamp
+	ID                         primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`                    // MongoDB Object ID
+	Username                   string             `bson:"username" json:"username"`                             // Username of the user
+	Email                      string             `bson:"email" json:"email"`                                   // Email address
+	DisplayName                string             `bson:"display_name,omitempty" json:"display_name,omitempty"` // Name shown to other users
+	Timezone                   string             `bson:"timezone,omitempty" json:"timezone,omitempty"`         // IANA time zone, e.g. Europe/London
+	Role                       string             `bson:"role,omitempty" json:"role,omitempty"`                 // user (default) or admin
+	Disabled                   bool               `bson:"disabled" json:"disabled"`                             // Disabled accounts can't sign in
+	Password                   string             `bson:"password" json:"password"`                             // Hashed password
+	FailedLogins               int                `bson:"failed_logins" json:"-"`                               // Consecutive failed login attempts
+	LockedUntil                *time.Time         `bson:"locked_until,omitempty" json:"-"`                      // Logins are refused until this time
+	TOTPEnabled                bool               `bson:"totp_enabled" json:"totp_enabled"`                     // Whether two-factor authentication is on
+	TOTPSecret                 string             `bson:"totp_secret,omitempty" json:"-"`                       // Confirmed TOTP secret
+	TOTPPendingSecret          string             `bson:"totp_pending_secret,omitempty" json:"-"`               // Secret awaiting confirmation during enrollment
+	TOTPLastCounter            int64              `bson:"totp_last_counter,omitempty" json:"-"`                 // Last accepted time step, to prevent code replay
+	TOTPChallenge              string             `bson:"totp_challenge,omitempty" json:"-"`                    // ID of the outstanding login challenge, which can be exchanged once
+	RecoveryCodes              []string           `bson:"recovery_codes,omitempty" json:"-"`                    // Hashed single-use recovery codes
+	PasswordResetHash          string             `bson:"password_reset_hash,omitempty" json:"-"`               // Hash of the token sent in a password reset email
+	PasswordResetExpiresAt     *time.Time         `bson:"password_reset_expires_at,omitempty" json:"-"`         // Password reset link expiry
+	PendingEmail               string             `bson:"pending_email,omitempty" json:"-"`                     // New email address awaiting verification
+	EmailVerificationHash      string             `bson:"email_verification_hash,omitempty" json:"-"`           // Hash of the token sent to the pending email
+	EmailVerificationExpiresAt *time.Time         `bson:"email_verification_expires_at,omitempty" json:"-"`     // Verification link expiry
+	DeletionScheduledAt        *time.Time         `bson:"deletion_scheduled_at,omitempty" json:"-"`             // Account and its data are deleted after this time
+	Identities                 []Identity         `bson:"identities,omitempty" json:"-"`                        // Linked external identity provider accounts
+	CreatedAt                  time.Time          `bson:"created_at" json:"created_at"`                         // Creation timestamp
+	UpdatedAt                  time.Time          `bson:"updated_at" json:"updated_at"`                         // Update timestamp
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
 }
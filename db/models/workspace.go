@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Workspace membership roles, from most to least privileged
+const (
+	WorkspaceOwner  = "owner"
+	WorkspaceAdmin  = "admin"
+	WorkspaceMember = "member"
+	WorkspaceGuest  = "guest"
+)
+
+// workspaceRoleRanks orders workspace roles so permissions can be compared
+var workspaceRoleRanks = map[string]int{
+	WorkspaceGuest:  1,
+	WorkspaceMember: 2,
+	WorkspaceAdmin:  3,
+	WorkspaceOwner:  4,
+}
+
+// IsWorkspaceRole reports whether role is a known workspace role
+func IsWorkspaceRole(role string) bool {
+	_, ok := workspaceRoleRanks[role]
+	return ok
+}
+
+// WorkspaceRoleAtLeast reports whether role grants at least the permissions of minimum
+func WorkspaceRoleAtLeast(role, minimum string) bool {
+	return workspaceRoleRanks[role] >= workspaceRoleRanks[minimum]
+}
+
+// Workspace owns breakdowns and is shared by its members.
+type Workspace struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"` // MongoDB Object ID
+	Name      string             `bson:"name" json:"name"`                  // Display name
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"owner_id"`          // User who owns the workspace
+	Personal  bool               `bson:"personal" json:"personal"`          // Every user has exactly one personal workspace, which can't be shared
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`      // Creation timestamp
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`      // Update timestamp
+}
+
+// Membership gives a user a role in a workspace.
+type Membership struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"` // MongoDB Object ID
+	WorkspaceID primitive.ObjectID `bson:"workspace_id" json:"workspace_id"`  // Workspace the user belongs to
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`            // Member
+	Role        string             `bson:"role" json:"role"`                  // owner, admin, member or guest
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`      // When the user joined
+}
+
+// Invitation asks someone, by email, to join a workspace.
+type Invitation struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`                  // MongoDB Object ID
+	WorkspaceID primitive.ObjectID `bson:"workspace_id" json:"workspace_id"`                   // Workspace to join
+	Email       string             `bson:"email" json:"email"`                                 // Invitee's email address
+	Role        string             `bson:"role" json:"role"`                                   // Role granted on acceptance
+	TokenHash   string             `bson:"token_hash" json:"-"`                                // Hash of the token sent by email
+	InvitedBy   primitive.ObjectID `bson:"invited_by" json:"invited_by"`                       // Member who sent the invitation
+	ExpiresAt   time.Time          `bson:"expires_at" json:"expires_at"`                       // Invitation expiry
+	AcceptedAt  *time.Time         `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"` // Set once accepted
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`                       // Creation timestamp
+}
@@ -2,45 +2,86 @@ package db
 
 import (
 	"context"
-	"log"
-	"os"
+	"fmt"
+	"log/slog"
 	"time"
 
-	"github.com/joho/godotenv"
+	"server/config"
+	"server/logging"
+	"server/metrics"
+
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 var Client *mongo.Client
 
-// Connect initializes the MongoDB client
-func Connect() *mongo.Client {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// slowCommandThreshold is how long a command can take before it is logged as slow
+const slowCommandThreshold = 500 * time.Millisecond
 
-	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
-	}
+// Connect initializes the MongoDB client and checks the server is reachable
+func Connect(ctx context.Context, cfg config.Mongo) (*mongo.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout.Duration)
+	defer cancel()
 
 	// Use the SetServerAPIOptions() method to set the version of the Stable API on the client
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
-	uri := os.Getenv("MONGO_URI")
-	opts := options.Client().ApplyURI(uri).SetServerAPIOptions(serverAPI)
+	opts := options.Client().ApplyURI(cfg.URI).SetServerAPIOptions(serverAPI).
+		SetMonitor(combineMonitors(metrics.MongoMonitor(), otelmongo.NewMonitor(), logMonitor()))
 
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
-		log.Fatalf("Failed to connect to MongoDB: %v", err)
+		return nil, fmt.Errorf("connecting to MongoDB: %w", err)
 	}
 
 	// Ping the database to verify the connection
 	if err := client.Ping(ctx, nil); err != nil {
-		log.Fatalf("Failed to ping MongoDB: %v", err)
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("pinging MongoDB: %w", err)
 	}
 
-	log.Println("Connected to MongoDB!")
+	slog.Info("Connected to MongoDB")
 	Client = client
-	return client
+	return client, nil
+}
+
+// logMonitor logs failed and slow commands with the logger of the request that issued them
+func logMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			if e.Duration >= slowCommandThreshold {
+				logging.FromContext(ctx).Warn("Slow MongoDB command", "command", e.CommandName, "duration", e.Duration)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			logging.FromContext(ctx).Warn("MongoDB command failed", "command", e.CommandName, "duration", e.Duration, "error", e.Failure)
+		},
+	}
+}
+
+// combineMonitors passes every command event to each monitor in turn, since the driver accepts only one.
+// Tracing must be set up before connecting, as the tracing monitor captures the tracer provider.
+func combineMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				m.Started(ctx, e)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				m.Succeeded(ctx, e)
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				m.Failed(ctx, e)
+			}
+		},
+	}
 }
 
 // GetCollection retrieves a MongoDB collection
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"server/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// lastUsedResolution limits how often last-used times are written for busy tokens
+const lastUsedResolution = time.Minute
+
+type AccessTokenRepository struct {
+	BaseRepository
+}
+
+func NewAccessTokenRepository(db *mongo.Database) *AccessTokenRepository {
+	return &AccessTokenRepository{
+		BaseRepository{
+			Collection: db.Collection("access_tokens"),
+		},
+	}
+}
+
+// FindActiveToken finds an unrevoked, unexpired token by its raw value and records that it was used
+func (r *AccessTokenRepository) FindActiveToken(ctx context.Context, token string) (*models.AccessToken, error) {
+	now := time.Now()
+	accessToken := &models.AccessToken{}
+	err := r.FindOne(ctx, bson.M{
+		"token_hash": utils.HashAccessToken(token),
+		"revoked_at": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"expires_at": bson.M{"$exists": false}},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		},
+	}, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) > lastUsedResolution {
+		if err := r.Update(ctx, bson.M{"_id": accessToken.ID}, bson.M{"$set": bson.M{"last_used_at": now}}); err != nil {
+			return nil, err
+		}
+		accessToken.LastUsedAt = &now
+	}
+
+	return accessToken, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// activityAggregationWindow is how soon an edit must follow the previous one to be folded into it
+const activityAggregationWindow = 5 * time.Minute
+
+// aggregatedActivities are the activity types whose rapid repeats are folded together.
+// The first entry's "from" data is kept and the latest "to" data wins.
+var aggregatedActivities = map[string]bool{
+	models.ActivityBreakdownRenamed: true,
+	models.ActivityBreakdownEdited:  true,
+}
+
+// ActivityFilter narrows an activity feed query; zero values are ignored
+type ActivityFilter struct {
+	WorkspaceIDs []primitive.ObjectID // Activity in any of these workspaces
+	BreakdownID  primitive.ObjectID
+	ActorID      primitive.ObjectID
+	Type         string
+	Before       primitive.ObjectID // Cursor: only activity older than this entry
+	Limit        int64
+}
+
+type ActivityRepository struct {
+	BaseRepository
+}
+
+func NewActivityRepository(db *mongo.Database) *ActivityRepository {
+	return &ActivityRepository{
+		BaseRepository{
+			Collection: db.Collection("activities"),
+		},
+	}
+}
+
+// Record appends an activity to the log, folding it into the breakdown's latest entry of the same
+// kind by the same user when it follows shortly after. Folded entries keep their place in the feed.
+func (r *ActivityRepository) Record(ctx context.Context, activity *models.Activity) error {
+	if activity.CreatedAt.IsZero() {
+		activity.CreatedAt = time.Now()
+	}
+	activity.UpdatedAt = activity.CreatedAt
+	if activity.Count == 0 {
+		activity.Count = 1
+	}
+
+	if aggregatedActivities[activity.Type] && !activity.BreakdownID.IsZero() {
+		folded, err := r.fold(ctx, activity)
+		if err != nil || folded {
+			return err
+		}
+	}
+
+	if activity.ID.IsZero() {
+		activity.ID = primitive.NewObjectID()
+	}
+	return r.Create(ctx, activity)
+}
+
+// fold merges an activity into the user's latest entry of the same kind on the breakdown if it continues
+// the same run of edits. Other activity in between, such as a rename during a run of description edits,
+// doesn't end the run.
+func (r *ActivityRepository) fold(ctx context.Context, activity *models.Activity) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	latest := &models.Activity{}
+	err := r.Collection.FindOne(ctx,
+		bson.M{"breakdown_id": activity.BreakdownID, "actor_id": activity.ActorID, "type": activity.Type},
+		options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}}),
+	).Decode(latest)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if activity.CreatedAt.Sub(latest.UpdatedAt) > activityAggregationWindow {
+		return false, nil
+	}
+
+	set := bson.M{"updated_at": activity.CreatedAt}
+	for key, value := range activity.Data {
+		if key == "from" {
+			if _, ok := latest.Data["from"]; ok {
+				continue
+			}
+		}
+		set["data."+key] = value
+	}
+
+	// Only fold into the entry if it is still the one we read
+	result, err := r.Collection.UpdateOne(ctx,
+		bson.M{"_id": latest.ID, "updated_at": latest.UpdatedAt},
+		bson.M{"$set": set, "$inc": bson.M{"count": 1}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// Feed returns activity matching the filter, newest first. Entries are ordered by when they started,
+// so the cursor stays stable; an entry that later edits were folded into keeps its place and shows
+// the time of the latest one in UpdatedAt.
+func (r *ActivityRepository) Feed(ctx context.Context, filter ActivityFilter) ([]models.Activity, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.WorkspaceIDs != nil {
+		query["workspace_id"] = bson.M{"$in": filter.WorkspaceIDs}
+	}
+	if !filter.BreakdownID.IsZero() {
+		query["breakdown_id"] = filter.BreakdownID
+	}
+	if !filter.ActorID.IsZero() {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	if !filter.Before.IsZero() {
+		query["_id"] = bson.M{"$lt": filter.Before}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(filter.Limit)
+	cursor, err := r.Collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	activities := []models.Activity{}
+	err = cursor.All(ctx, &activities)
+	return activities, err
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AttachmentRepository struct {
+	BaseRepository
+}
+
+func NewAttachmentRepository(db *mongo.Database) *AttachmentRepository {
+	return &AttachmentRepository{
+		BaseRepository{
+			Collection: db.Collection("attachments"),
+		},
+	}
+}
+
+// FindOrphaned finds up to limit attachments whose breakdown has been deleted
+func (r *AttachmentRepository) FindOrphaned(ctx context.Context, limit int64) ([]models.Attachment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cursor, err := r.Collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "breakdowns",
+			"localField":   "breakdown_id",
+			"foreignField": "_id",
+			"as":           "breakdown",
+		}}},
+		{{Key: "$match", Value: bson.M{"breakdown": bson.M{"$size": 0}}}},
+		{{Key: "$project", Value: bson.M{"breakdown": 0}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	attachments := []models.Attachment{}
+	err = cursor.All(ctx, &attachments)
+	return attachments, err
+}
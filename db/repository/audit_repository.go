@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditFilter narrows an audit log query; zero values are ignored
+type AuditFilter struct {
+	UserID   *primitive.ObjectID // Events belonging to or performed by this user
+	ActorID  *primitive.ObjectID // Events performed by this user
+	Action   string
+	TargetID string
+	From     time.Time
+	To       time.Time
+	Before   primitive.ObjectID // Cursor: only events older than this one
+	Limit    int64
+}
+
+// AuditRepository stores the audit log. Unlike other repositories it doesn't embed
+// BaseRepository, so events can only be appended and read, never changed or removed.
+type AuditRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAuditRepository(db *mongo.Database) *AuditRepository {
+	return &AuditRepository{
+		collection: db.Collection("audit_events"),
+	}
+}
+
+// Record appends an event to the audit log
+func (r *AuditRepository) Record(ctx context.Context, event *models.AuditEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	_, err := r.collection.InsertOne(ctx, event)
+	return err
+}
+
+// Find returns events matching the filter, newest first
+func (r *AuditRepository) Find(ctx context.Context, filter AuditFilter) ([]models.AuditEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := bson.M{}
+	if filter.UserID != nil {
+		query["$or"] = bson.A{bson.M{"user_id": filter.UserID}, bson.M{"actor_id": filter.UserID}}
+	}
+	if filter.ActorID != nil {
+		query["actor_id"] = filter.ActorID
+	}
+	if filter.Action != "" {
+		query["action"] = filter.Action
+	}
+	if filter.TargetID != "" {
+		query["target_id"] = filter.TargetID
+	}
+	createdAt := bson.M{}
+	if !filter.From.IsZero() {
+		createdAt["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		createdAt["$lt"] = filter.To
+	}
+	if len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+	if !filter.Before.IsZero() {
+		query["_id"] = bson.M{"$lt": filter.Before}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(filter.Limit)
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.AuditEvent{}
+	err = cursor.All(ctx, &events)
+	return events, err
+}
@@ -61,6 +61,14 @@ func (r *BaseRepository) Delete(ctx context.Context, filter interface{}) error {
 	return err
 }
 
+// Count returns the number of documents matching the filter.
+func (r *BaseRepository) Count(ctx context.Context, filter interface{}) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	return r.Collection.CountDocuments(ctx, filter)
+}
+
 // GetAll retrieves all documents from the collection and decodes them into the provided results.
 func (r *BaseRepository) FindAll(ctx context.Context, results interface{}) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
@@ -1,15 +1,67 @@
 package repository
 
-import "go.mongodb.org/mongo-driver/mongo"
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
 
 type BreakdownRepository struct {
 	BaseRepository
 }
 
-func NewBreakdownRepository(db *mongo.Client) *BreakdownRepository {
+func NewBreakdownRepository(db *mongo.Database) *BreakdownRepository {
 	return &BreakdownRepository{
 		BaseRepository{
-			Collection: db.Database("flow").Collection("breakdowns"),
+			Collection: db.Collection("breakdowns"),
 		},
 	}
 }
+
+// FindIDs returns the IDs of the breakdowns matching the filter
+func (r *BreakdownRepository) FindIDs(ctx context.Context, filter interface{}) ([]primitive.ObjectID, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.Collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	return ids, nil
+}
+
+// Reassign hands a breakdown from one user to another and moves it to workspaceID, failing with
+// mongo.ErrNoDocuments if it no longer belongs to fromUserID
+func (r *BreakdownRepository) Reassign(ctx context.Context, id, fromUserID, toUserID, workspaceID primitive.ObjectID, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.Collection.UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": fromUserID},
+		bson.M{"$set": bson.M{"user_id": toUserID, "workspace_id": workspaceID, "updated_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
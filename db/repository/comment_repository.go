@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CommentRepository struct {
+	BaseRepository
+}
+
+func NewCommentRepository(db *mongo.Database) *CommentRepository {
+	return &CommentRepository{
+		BaseRepository{
+			Collection: db.Collection("comments"),
+		},
+	}
+}
+
+// FindBreakdownComments lists the comments on a breakdown, oldest first
+func (r *CommentRepository) FindBreakdownComments(ctx context.Context, breakdownID primitive.ObjectID) ([]models.Comment, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.Collection.Find(ctx, bson.M{"breakdown_id": breakdownID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	comments := []models.Comment{}
+	err = cursor.All(ctx, &comments)
+	return comments, err
+}
+
+// CountByBreakdown counts the visible comments on each of the given breakdowns
+func (r *CommentRepository) CountByBreakdown(ctx context.Context, breakdownIDs []primitive.ObjectID) (map[primitive.ObjectID]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.Collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"breakdown_id": bson.M{"$in": breakdownIDs}, "deleted": bson.M{"$ne": true}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$breakdown_id", "count": bson.M{"$sum": 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		ID    primitive.ObjectID `bson:"_id"`
+		Count int64              `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[primitive.ObjectID]int64, len(results))
+	for _, result := range results {
+		counts[result.ID] = result.Count
+	}
+	return counts, nil
+}
+
+// HasReplies reports whether any comment replies to the given one
+func (r *CommentRepository) HasReplies(ctx context.Context, id primitive.ObjectID) (bool, error) {
+	count, err := r.Count(ctx, bson.M{"parent_id": id})
+	return count > 0, err
+}
+
+// DeleteForBreakdowns removes the discussions on the given breakdowns
+func (r *CommentRepository) DeleteForBreakdowns(ctx context.Context, breakdownIDs []primitive.ObjectID) error {
+	if len(breakdownIDs) == 0 {
+		return nil
+	}
+	return r.Delete(ctx, bson.M{"breakdown_id": bson.M{"$in": breakdownIDs}})
+}
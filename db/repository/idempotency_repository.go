@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type IdempotencyRepository struct {
+	BaseRepository
+}
+
+func NewIdempotencyRepository(db *mongo.Database) *IdempotencyRepository {
+	return &IdempotencyRepository{
+		BaseRepository{
+			Collection: db.Collection("idempotency_keys"),
+		},
+	}
+}
+
+// Acquire inserts an in-flight record for the key, which acts as a lock held for the lease.
+// If a live record already exists it is returned instead and acquired is false.
+func (r *IdempotencyRepository) Acquire(ctx context.Context, id, requestHash string, lease time.Duration) (record *models.IdempotencyRecord, acquired bool, err error) {
+	now := time.Now()
+	record = &models.IdempotencyRecord{
+		ID:          id,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(lease),
+	}
+
+	err = r.Create(ctx, record)
+	if err == nil {
+		return record, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	existing := &models.IdempotencyRecord{}
+	if err := r.FindOne(ctx, bson.M{"_id": id}, existing); err != nil {
+		return nil, false, err
+	}
+
+	// Expired records, and in-flight ones whose lease ran out, are replaced by the new request
+	if existing.ExpiresAt.Before(now) {
+		if err := r.Delete(ctx, bson.M{"_id": id, "expires_at": existing.ExpiresAt}); err != nil {
+			return nil, false, err
+		}
+		return r.Acquire(ctx, id, requestHash, lease)
+	}
+
+	return existing, false, nil
+}
+
+// Complete stores the response for an in-flight record and keeps it for ttl
+func (r *IdempotencyRepository) Complete(ctx context.Context, id string, status int, header map[string][]string, body []byte, ttl time.Duration) error {
+	return r.Update(ctx, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{
+			"completed":  true,
+			"expires_at": time.Now().Add(ttl),
+			"status":     status,
+			"header":     header,
+			"body":       body,
+		},
+	})
+}
+
+// Release removes an in-flight record so the request can be retried
+func (r *IdempotencyRepository) Release(ctx context.Context, id string) error {
+	return r.Delete(ctx, bson.M{"_id": id, "completed": false})
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type InvitationRepository struct {
+	BaseRepository
+}
+
+func NewInvitationRepository(db *mongo.Database) *InvitationRepository {
+	return &InvitationRepository{
+		BaseRepository{
+			Collection: db.Collection("invitations"),
+		},
+	}
+}
+
+// pendingFilter matches invitations that are neither accepted nor expired
+func pendingFilter(now time.Time) bson.M {
+	return bson.M{
+		"accepted_at": bson.M{"$exists": false},
+		"expires_at":  bson.M{"$gt": now},
+	}
+}
+
+// FindPendingInvitations lists the open invitations to a workspace
+func (r *InvitationRepository) FindPendingInvitations(ctx context.Context, workspaceID primitive.ObjectID) ([]models.Invitation, error) {
+	filter := pendingFilter(time.Now())
+	filter["workspace_id"] = workspaceID
+
+	invitations := []models.Invitation{}
+	err := r.Find(ctx, filter, &invitations)
+	return invitations, err
+}
+
+// FindPendingInvitationByToken finds an open invitation by the hash of its token
+func (r *InvitationRepository) FindPendingInvitationByToken(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	filter := pendingFilter(time.Now())
+	filter["token_hash"] = tokenHash
+
+	invitation := &models.Invitation{}
+	err := r.FindOne(ctx, filter, invitation)
+	return invitation, err
+}
+
+// Accept marks an invitation as used, failing if it was accepted concurrently
+func (r *InvitationRepository) Accept(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.Collection.UpdateOne(ctx,
+		bson.M{"_id": id, "accepted_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"accepted_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NotificationRepository struct {
+	BaseRepository
+}
+
+func NewNotificationRepository(db *mongo.Database) *NotificationRepository {
+	return &NotificationRepository{
+		BaseRepository{
+			Collection: db.Collection("notifications"),
+		},
+	}
+}
+
+// Notify delivers notifications
+func (r *NotificationRepository) Notify(ctx context.Context, notifications []models.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	documents := make([]interface{}, len(notifications))
+	for i := range notifications {
+		if notifications[i].ID.IsZero() {
+			notifications[i].ID = primitive.NewObjectID()
+		}
+		if notifications[i].CreatedAt.IsZero() {
+			notifications[i].CreatedAt = time.Now()
+		}
+		documents[i] = notifications[i]
+	}
+
+	_, err := r.Collection.InsertMany(ctx, documents)
+	return err
+}
+
+// FindUserNotifications lists a user's most recent notifications, newest first
+func (r *NotificationRepository) FindUserNotifications(ctx context.Context, userID primitive.ObjectID, unreadOnly bool, limit, offset int64) ([]models.Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userID}
+	if unreadOnly {
+		filter["read_at"] = bson.M{"$exists": false}
+	}
+
+	cursor, err := r.Collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit).SetSkip(offset))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := []models.Notification{}
+	err = cursor.All(ctx, &notifications)
+	return notifications, err
+}
+
+// MarkRead marks a user's notifications as read; with no IDs every unread notification is marked
+func (r *NotificationRepository) MarkRead(ctx context.Context, userID primitive.ObjectID, ids ...primitive.ObjectID) error {
+	filter := bson.M{"user_id": userID, "read_at": bson.M{"$exists": false}}
+	if len(ids) > 0 {
+		filter["_id"] = bson.M{"$in": ids}
+	}
+	return r.Update(ctx, filter, bson.M{"$set": bson.M{"read_at": time.Now()}})
+}
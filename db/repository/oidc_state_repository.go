@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type OIDCStateRepository struct {
+	BaseRepository
+}
+
+func NewOIDCStateRepository(db *mongo.Database) *OIDCStateRepository {
+	return &OIDCStateRepository{
+		BaseRepository{
+			Collection: db.Collection("oidc_states"),
+		},
+	}
+}
+
+// Consume removes and returns an unexpired state so it can only be used once
+func (r *OIDCStateRepository) Consume(ctx context.Context, id, provider string) (*models.OIDCState, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	state := &models.OIDCState{}
+	err := r.Collection.FindOneAndDelete(ctx, bson.M{"_id": id, "provider": provider}).Decode(state)
+	if err != nil {
+		return nil, errors.New("unknown or already used login state")
+	}
+	if state.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("login state has expired")
+	}
+	return state, nil
+}
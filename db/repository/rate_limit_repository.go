@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitRepository keeps token buckets in MongoDB so limits are shared between instances.
+type RateLimitRepository struct {
+	BaseRepository
+}
+
+func NewRateLimitRepository(db *mongo.Database) *RateLimitRepository {
+	return &RateLimitRepository{
+		BaseRepository{
+			Collection: db.Collection("rate_limits"),
+		},
+	}
+}
+
+// Take atomically refills the bucket for key and removes a token if one is available
+func (r *RateLimitRepository) Take(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	capacity := float64(limit)
+	rate := capacity / window.Seconds() // tokens per second
+
+	// Refill based on elapsed time, then take a token if there is one
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{capacity, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+				bson.M{"$multiply": bson.A{
+					bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}}, 1000}},
+					rate,
+				}},
+			}}}},
+			"updated_at": now,
+			"expires_at": now.Add(window),
+		}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{"tokens": bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}}}}},
+	}
+
+	var result struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := r.Collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&result)
+	if err != nil {
+		return false, 0, 0, 0, err
+	}
+
+	retryAfter := time.Duration(0)
+	if result.Tokens < 1 {
+		retryAfter = time.Duration((1 - result.Tokens) / rate * float64(time.Second))
+	}
+	reset := time.Duration((capacity - result.Tokens) / rate * float64(time.Second))
+
+	return result.Allowed, int(math.Floor(result.Tokens)), retryAfter, reset, nil
+}
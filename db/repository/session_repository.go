@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// lastSeenResolution limits how often last-seen times are written for busy sessions
+const lastSeenResolution = time.Minute
+
+type SessionRepository struct {
+	BaseRepository
+}
+
+func NewSessionRepository(db *mongo.Database) *SessionRepository {
+	return &SessionRepository{
+		BaseRepository{
+			Collection: db.Collection("sessions"),
+		},
+	}
+}
+
+// activeFilter matches sessions that are neither revoked nor expired
+func activeFilter(now time.Time) bson.M {
+	return bson.M{
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+}
+
+// FindActiveSession finds an unrevoked, unexpired session and records that it was seen
+func (r *SessionRepository) FindActiveSession(ctx context.Context, id string) (*models.Session, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	filter := activeFilter(now)
+	filter["_id"] = objectID
+
+	session := &models.Session{}
+	if err := r.FindOne(ctx, filter, session); err != nil {
+		return nil, err
+	}
+
+	if now.Sub(session.LastSeenAt) > lastSeenResolution {
+		if err := r.Update(ctx, bson.M{"_id": objectID}, bson.M{"$set": bson.M{"last_seen_at": now}}); err != nil {
+			return nil, err
+		}
+		session.LastSeenAt = now
+	}
+
+	return session, nil
+}
+
+// FindActiveSessions lists a user's active sessions
+func (r *SessionRepository) FindActiveSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	filter := activeFilter(time.Now())
+	filter["user_id"] = userID
+
+	sessions := []models.Session{}
+	err := r.Find(ctx, filter, &sessions)
+	return sessions, err
+}
+
+// RevokeSession signs out one of a user's sessions
+func (r *SessionRepository) RevokeSession(ctx context.Context, userID, id primitive.ObjectID) error {
+	return r.Update(ctx, bson.M{"_id": id, "user_id": userID, "revoked_at": bson.M{"$exists": false}}, bson.M{
+		"$set": bson.M{"revoked_at": time.Now()},
+	})
+}
+
+// RevokeOtherSessions signs out all of a user's sessions except keep (which may be zero to sign out everywhere)
+func (r *SessionRepository) RevokeOtherSessions(ctx context.Context, userID, keep primitive.ObjectID) error {
+	return r.Update(ctx, bson.M{
+		"user_id":    userID,
+		"_id":        bson.M{"$ne": keep},
+		"revoked_at": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{"revoked_at": time.Now()},
+	})
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"server/utils"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SigningKeyRepository struct {
+	BaseRepository
+}
+
+func NewSigningKeyRepository(db *mongo.Database) *SigningKeyRepository {
+	return &SigningKeyRepository{
+		BaseRepository{
+			Collection: db.Collection("signing_keys"),
+		},
+	}
+}
+
+// LoadKeys retrieves all stored signing keys
+func (r *SigningKeyRepository) LoadKeys(ctx context.Context) ([]models.SigningKey, error) {
+	var keys []models.SigningKey
+	err := r.FindAll(ctx, &keys)
+	return keys, err
+}
+
+// SaveKey stores a new signing key. The unique slot index lets only one instance create the key for a slot.
+func (r *SigningKeyRepository) SaveKey(ctx context.Context, key *models.SigningKey) error {
+	err := r.Create(ctx, key)
+	if mongo.IsDuplicateKeyError(err) {
+		return utils.ErrKeyExists
+	}
+	return err
+}
+
+// DeleteExpiredKeys removes keys that can no longer validate any token
+func (r *SigningKeyRepository) DeleteExpiredKeys(ctx context.Context, now time.Time) error {
+	return r.Delete(ctx, bson.M{"expires_at": bson.M{"$lt": now}})
+}
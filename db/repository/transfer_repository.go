@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type TransferRepository struct {
+	BaseRepository
+}
+
+func NewTransferRepository(db *mongo.Database) *TransferRepository {
+	return &TransferRepository{
+		BaseRepository{
+			Collection: db.Collection("transfers"),
+		},
+	}
+}
+
+// Resolve moves a pending transfer to its final status, failing with mongo.ErrNoDocuments if it
+// was resolved concurrently
+func (r *TransferRepository) Resolve(ctx context.Context, id primitive.ObjectID, status string, now time.Time) error {
+	return r.setStatus(ctx, id, models.TransferPending, status, now)
+}
+
+// Reopen returns a transfer claimed by Resolve to pending, for when the hand-over can't go ahead
+func (r *TransferRepository) Reopen(ctx context.Context, id primitive.ObjectID, status string, now time.Time) error {
+	return r.setStatus(ctx, id, status, models.TransferPending, now)
+}
+
+func (r *TransferRepository) setStatus(ctx context.Context, id primitive.ObjectID, from, to string, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.Collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": from},
+		bson.M{"$set": bson.M{"status": to, "updated_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
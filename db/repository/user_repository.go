@@ -3,23 +3,53 @@ package repository
 import (
 	"context"
 	"errors"
+	"regexp"
 	"server/db/models"
+	"server/utils"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Login throttling policy: after freeLoginAttempts consecutive failures each further failure
+// blocks logins for an exponentially growing delay, and lockoutThreshold failures lock the
+// account for lockoutDuration.
+const (
+	freeLoginAttempts = 3
+	lockoutThreshold  = 10
+	lockoutDuration   = 15 * time.Minute
+)
+
+// AccountLockedError is returned by ValidateCredentials and VerifyPassword while logins are temporarily blocked
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return "too many failed login attempts, try again later"
+}
+
 type UserRepository struct {
 	BaseRepository
 }
 
-func NewUserRepository(db *mongo.Client) *UserRepository {
+var (
+	ErrEmailTaken    = errors.New("user with this email already exists")
+	ErrUsernameTaken = errors.New("username already taken")
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrChallengeUsed is returned for a two-factor challenge that was already exchanged or replaced
+	ErrChallengeUsed = errors.New("two-factor challenge is no longer valid")
+)
+
+func NewUserRepository(db *mongo.Database) *UserRepository {
 	return &UserRepository{
 		BaseRepository{
-			Collection: db.Database("flow").Collection("users"),
+			Collection: db.Collection("users"),
 		},
 	}
 }
@@ -30,13 +60,13 @@ func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) erro
 	existingUser := &models.User{}
 	err := r.FindOne(ctx, bson.M{"email": user.Email}, existingUser)
 	if err == nil {
-		return errors.New("user with this email already exists")
+		return ErrEmailTaken
 	}
 
 	// Check if username already exists
 	err = r.FindOne(ctx, bson.M{"username": user.Username}, existingUser)
 	if err == nil {
-		return errors.New("username already taken")
+		return ErrUsernameTaken
 	}
 
 	// Hash the password
@@ -52,8 +82,47 @@ func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) erro
 	// Update the password with the hashed version
 	user.Password = string(hashedPassword)
 
-	// Create the user
-	return r.Create(ctx, user)
+	// Assign the ID up front so callers can use it once the user is created
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+
+	// Create the user. The unique indexes catch a concurrent registration the checks above missed.
+	err = r.Create(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		if strings.Contains(err.Error(), "username") {
+			return ErrUsernameTaken
+		}
+		return ErrEmailTaken
+	}
+	return err
+}
+
+// ForcePasswordReset replaces a user's password with a random one nobody knows and starts a
+// password reset valid for ttl, returning the reset token to send to the user
+func (r *UserRepository) ForcePasswordReset(ctx context.Context, userID primitive.ObjectID, ttl time.Duration) (string, error) {
+	randomPassword, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(randomPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = r.Update(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"password":                  string(hashedPassword),
+			"password_reset_hash":       utils.HashToken(token),
+			"password_reset_expires_at": time.Now().Add(ttl),
+			"updated_at":                time.Now(),
+		},
+	})
+	return token, err
 }
 
 // FindUserByEmail finds a user by email address
@@ -66,6 +135,54 @@ func (r *UserRepository) FindUserByEmail(ctx context.Context, email string) (*mo
 	return user, nil
 }
 
+// FindUserByUsername finds a user by username
+func (r *UserRepository) FindUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	user := &models.User{}
+	err := r.FindOne(ctx, bson.M{"username": username}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUsersByUsernames finds the users with the given usernames in one query, keyed by username.
+// Usernames without a user are left out of the map.
+func (r *UserRepository) FindUsersByUsernames(ctx context.Context, usernames []string) (map[string]*models.User, error) {
+	found := map[string]*models.User{}
+	if len(usernames) == 0 {
+		return found, nil
+	}
+
+	users := []models.User{}
+	if err := r.Find(ctx, bson.M{"username": bson.M{"$in": usernames}}, &users); err != nil {
+		return nil, err
+	}
+	for i := range users {
+		found[users[i].Username] = &users[i]
+	}
+	return found, nil
+}
+
+// FindUserByEmailVerification finds the user an email verification token was sent to
+func (r *UserRepository) FindUserByEmailVerification(ctx context.Context, tokenHash string) (*models.User, error) {
+	user := &models.User{}
+	err := r.FindOne(ctx, bson.M{"email_verification_hash": tokenHash}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUserByPasswordReset finds the user a password reset token was sent to
+func (r *UserRepository) FindUserByPasswordReset(ctx context.Context, tokenHash string) (*models.User, error) {
+	user := &models.User{}
+	err := r.FindOne(ctx, bson.M{"password_reset_hash": tokenHash}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
 // FindUserByID finds a user by ID
 func (r *UserRepository) FindUserByID(ctx context.Context, id string) (*models.User, error) {
 	objectID, err := primitive.ObjectIDFromHex(id)
@@ -81,6 +198,74 @@ func (r *UserRepository) FindUserByID(ctx context.Context, id string) (*models.U
 	return user, nil
 }
 
+// FindUsersByIDs finds the users with the given IDs in one query, keyed by ID. IDs without a user
+// are left out of the map.
+func (r *UserRepository) FindUsersByIDs(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]*models.User, error) {
+	found := map[primitive.ObjectID]*models.User{}
+	if len(ids) == 0 {
+		return found, nil
+	}
+
+	users := []models.User{}
+	if err := r.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, &users); err != nil {
+		return nil, err
+	}
+	for i := range users {
+		found[users[i].ID] = &users[i]
+	}
+	return found, nil
+}
+
+// FindUserByIdentity finds a user linked to an external identity provider account
+func (r *UserRepository) FindUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	user := &models.User{}
+	err := r.FindOne(ctx, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}},
+	}, user)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// LinkIdentity attaches an external identity provider account to a user
+func (r *UserRepository) LinkIdentity(ctx context.Context, userID primitive.ObjectID, identity models.Identity) error {
+	return r.Update(ctx, bson.M{"_id": userID}, bson.M{
+		"$addToSet": bson.M{"identities": identity},
+		"$set":      bson.M{"updated_at": time.Now()},
+	})
+}
+
+// SearchUsers finds users whose username or email contains query, newest first, returning a page and the total count
+func (r *UserRepository) SearchUsers(ctx context.Context, query string, limit, offset int64) ([]models.User, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if query != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		filter["$or"] = bson.A{bson.M{"username": pattern}, bson.M{"email": pattern}}
+	}
+
+	total, err := r.Collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().SetSort(bson.M{"created_at": -1}).SetSkip(offset).SetLimit(limit)
+	cursor, err := r.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
 // ValidateCredentials checks if the provided email and password match a user
 func (r *UserRepository) ValidateCredentials(ctx context.Context, email, password string) (*models.User, error) {
 	user, err := r.FindUserByEmail(ctx, email)
@@ -88,11 +273,161 @@ func (r *UserRepository) ValidateCredentials(ctx context.Context, email, passwor
 		return nil, errors.New("invalid email or password")
 	}
 
+	err = r.VerifyPassword(ctx, user, password)
+	if errors.Is(err, ErrWrongPassword) {
+		return nil, errors.New("invalid email or password")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// VerifyPassword checks a user's password, e.g. before a sensitive change. Wrong passwords count
+// towards the login lockout, and attempts are refused while the account is locked.
+func (r *UserRepository) VerifyPassword(ctx context.Context, user *models.User, password string) error {
+	now := time.Now()
+	if user.LockedUntil != nil && user.LockedUntil.After(now) {
+		return &AccountLockedError{Until: *user.LockedUntil}
+	}
+
 	// Compare the provided password with the stored hash
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		if err := r.recordFailedLogin(ctx, user, now); err != nil {
+			return err
+		}
+		return ErrWrongPassword
+	}
+
+	// Reset the failure counter on success
+	if user.FailedLogins > 0 || user.LockedUntil != nil {
+		return r.Update(ctx, bson.M{"_id": user.ID}, bson.M{
+			"$set":   bson.M{"failed_logins": 0},
+			"$unset": bson.M{"locked_until": ""},
+		})
+	}
+	return nil
+}
+
+// StartTwoFactorChallenge records the login challenge a user was issued after their first factor,
+// replacing any earlier one
+func (r *UserRepository) StartTwoFactorChallenge(ctx context.Context, userID primitive.ObjectID, challengeID string) error {
+	return r.Update(ctx, bson.M{"_id": userID}, bson.M{"$set": bson.M{"totp_challenge": challengeID}})
+}
+
+// ConsumeTwoFactorChallenge removes a user's login challenge so it can't be exchanged again. It
+// returns ErrChallengeUsed if the challenge was already exchanged or a newer one was issued.
+func (r *UserRepository) ConsumeTwoFactorChallenge(ctx context.Context, userID primitive.ObjectID, challengeID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.Collection.UpdateOne(ctx, bson.M{"_id": userID, "totp_challenge": challengeID}, bson.M{
+		"$unset": bson.M{"totp_challenge": ""},
+	})
 	if err != nil {
-		return nil, errors.New("invalid email or password")
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return ErrChallengeUsed
 	}
+	return nil
+}
 
-	return user, nil
+// ValidateSecondFactor checks a TOTP code or recovery code for a user with two-factor authentication enabled.
+// Used codes are consumed so they can't be replayed, and failures count towards the login lockout.
+func (r *UserRepository) ValidateSecondFactor(ctx context.Context, user *models.User, code string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	if user.LockedUntil != nil && user.LockedUntil.After(now) {
+		return &AccountLockedError{Until: *user.LockedUntil}
+	}
+
+	// Try the code as a TOTP code first; only advance the counter if it hasn't moved concurrently
+	if counter, ok := utils.ValidateTOTP(user.TOTPSecret, code, now, user.TOTPLastCounter); ok {
+		result, err := r.Collection.UpdateOne(ctx, bson.M{
+			"_id":               user.ID,
+			"totp_last_counter": bson.M{"$not": bson.M{"$gte": counter}},
+		}, bson.M{
+			"$set":   bson.M{"totp_last_counter": counter, "failed_logins": 0},
+			"$unset": bson.M{"locked_until": ""},
+		})
+		if err != nil {
+			return err
+		}
+		if result.ModifiedCount == 1 {
+			return nil
+		}
+	}
+
+	// Then as a recovery code, removing it so it can only be used once
+	result, err := r.Collection.UpdateOne(ctx, bson.M{
+		"_id":            user.ID,
+		"recovery_codes": utils.HashRecoveryCode(code),
+	}, bson.M{
+		"$pull":  bson.M{"recovery_codes": utils.HashRecoveryCode(code)},
+		"$set":   bson.M{"failed_logins": 0},
+		"$unset": bson.M{"locked_until": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 1 {
+		return nil
+	}
+
+	if err := r.recordFailedLogin(ctx, user, now); err != nil {
+		return err
+	}
+	return errors.New("invalid two-factor code")
+}
+
+// recordFailedLogin increments the failure counter and blocks further attempts once the free attempts are used up.
+// The lock is worked out from the count after the increment, so concurrent guesses each see their own count.
+// A lockout that has run its course starts the count again.
+func (r *UserRepository) recordFailedLogin(ctx context.Context, user *models.User, now time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.Collection.UpdateOne(ctx, bson.M{
+		"_id":           user.ID,
+		"failed_logins": bson.M{"$gte": lockoutThreshold},
+		"locked_until":  bson.M{"$lte": now},
+	}, bson.M{
+		"$set":   bson.M{"failed_logins": 0},
+		"$unset": bson.M{"locked_until": ""},
+	})
+	if err != nil {
+		return err
+	}
+
+	updated := &models.User{}
+	err = r.Collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$inc": bson.M{"failed_logins": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"failed_logins": 1}),
+	).Decode(updated)
+	if err != nil {
+		return err
+	}
+
+	until, locked := loginLock(updated.FailedLogins, now)
+	if !locked {
+		return nil
+	}
+	// $max keeps the longest lock when guesses race
+	_, err = r.Collection.UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{"$max": bson.M{"locked_until": until}})
+	return err
+}
+
+// loginLock returns until when logins are blocked after the given number of consecutive failures
+func loginLock(failures int, now time.Time) (time.Time, bool) {
+	switch {
+	case failures >= lockoutThreshold:
+		return now.Add(lockoutDuration), true
+	case failures > freeLoginAttempts:
+		return now.Add(time.Second << (failures - freeLoginAttempts - 1)), true
+	}
+	return time.Time{}, false
 }
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"server/db/models"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestLoginLock(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		failures int
+		want     time.Duration // 0 when logins aren't blocked
+	}{
+		{1, 0},
+		{freeLoginAttempts, 0},
+		{freeLoginAttempts + 1, time.Second},
+		{freeLoginAttempts + 2, 2 * time.Second},
+		{freeLoginAttempts + 3, 4 * time.Second},
+		{lockoutThreshold - 1, time.Second << (lockoutThreshold - freeLoginAttempts - 2)},
+		{lockoutThreshold, lockoutDuration},
+		{lockoutThreshold + 5, lockoutDuration},
+	}
+	for _, tt := range tests {
+		until, locked := loginLock(tt.failures, now)
+		if locked != (tt.want > 0) || (locked && until.Sub(now) != tt.want) {
+			t.Errorf("loginLock(%d) = %v, %v; want a lock of %v", tt.failures, until.Sub(now), locked, tt.want)
+		}
+	}
+}
+
+func newLockoutTestUser(t *testing.T) (*UserRepository, *models.User) {
+	t.Helper()
+	users := NewUserRepository(newTestDatabase(t))
+	user := &models.User{Username: "ada", Email: "ada@example.com", Password: "correct horse battery staple"}
+	if err := users.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return users, user
+}
+
+func TestConcurrentFailedLoginsLockAccount(t *testing.T) {
+	users, user := newLockoutTestUser(t)
+
+	// Every guess reads the account before any failure is recorded
+	var wg sync.WaitGroup
+	for i := 0; i < lockoutThreshold; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			users.ValidateCredentials(context.Background(), user.Email, "wrong")
+		}()
+	}
+	wg.Wait()
+
+	stored, err := users.FindUserByEmail(context.Background(), user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.FailedLogins != lockoutThreshold || stored.LockedUntil == nil || time.Until(*stored.LockedUntil) < lockoutDuration-time.Minute {
+		t.Errorf("after %d concurrent failures: failed_logins = %d, locked until %v; want a %v lockout",
+			lockoutThreshold, stored.FailedLogins, stored.LockedUntil, lockoutDuration)
+	}
+
+	var locked *AccountLockedError
+	if _, err := users.ValidateCredentials(context.Background(), user.Email, "correct horse battery staple"); !errors.As(err, &locked) {
+		t.Errorf("login with the right password while locked = %v, want AccountLockedError", err)
+	}
+}
+
+func TestExpiredLockoutStartsCountAgain(t *testing.T) {
+	users, user := newLockoutTestUser(t)
+	expired := time.Now().Add(-time.Minute)
+	if err := users.Update(context.Background(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"failed_logins": lockoutThreshold, "locked_until": expired},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := users.ValidateCredentials(context.Background(), user.Email, "wrong"); err == nil {
+		t.Fatal("wrong password accepted")
+	}
+	stored, err := users.FindUserByEmail(context.Background(), user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.FailedLogins != 1 || (stored.LockedUntil != nil && stored.LockedUntil.After(time.Now())) {
+		t.Errorf("one failure after an expired lockout: failed_logins = %d, locked until %v; want 1 and unlocked", stored.FailedLogins, stored.LockedUntil)
+	}
+}
+
+func TestVerifyPasswordCountsFailures(t *testing.T) {
+	users, user := newLockoutTestUser(t)
+	ctx := context.Background()
+
+	// Wrong passwords given to confirm a sensitive change count like failed logins
+	for i := 0; i < freeLoginAttempts+1; i++ {
+		if err := users.VerifyPassword(ctx, user, "wrong"); !errors.Is(err, ErrWrongPassword) {
+			t.Fatalf("VerifyPassword with a wrong password = %v, want ErrWrongPassword", err)
+		}
+	}
+	stored, err := users.FindUserByEmail(ctx, user.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locked *AccountLockedError
+	if err := users.VerifyPassword(ctx, stored, "correct horse battery staple"); !errors.As(err, &locked) {
+		t.Errorf("VerifyPassword while locked = %v, want AccountLockedError", err)
+	}
+	if stored.FailedLogins != freeLoginAttempts+1 {
+		t.Errorf("failed_logins = %d, want %d", stored.FailedLogins, freeLoginAttempts+1)
+	}
+}
+
+func TestTwoFactorChallengeIsSingleUse(t *testing.T) {
+	users, user := newLockoutTestUser(t)
+	ctx := context.Background()
+
+	if err := users.StartTwoFactorChallenge(ctx, user.ID, "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.StartTwoFactorChallenge(ctx, user.ID, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.ConsumeTwoFactorChallenge(ctx, user.ID, "first"); !errors.Is(err, ErrChallengeUsed) {
+		t.Errorf("consuming a replaced challenge = %v, want ErrChallengeUsed", err)
+	}
+	if err := users.ConsumeTwoFactorChallenge(ctx, user.ID, "second"); err != nil {
+		t.Errorf("consuming the current challenge = %v", err)
+	}
+	if err := users.ConsumeTwoFactorChallenge(ctx, user.ID, "second"); !errors.Is(err, ErrChallengeUsed) {
+		t.Errorf("consuming a challenge twice = %v, want ErrChallengeUsed", err)
+	}
+}
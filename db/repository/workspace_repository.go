@@ -0,0 +1,291 @@
+package repository
+
+import (
+	"context"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PersonalWorkspaceName is the name given to each user's personal workspace
+const PersonalWorkspaceName = "Personal"
+
+// WorkspaceRepository stores workspaces and their memberships
+type WorkspaceRepository struct {
+	BaseRepository
+	members BaseRepository
+}
+
+func NewWorkspaceRepository(db *mongo.Database) *WorkspaceRepository {
+	return &WorkspaceRepository{
+		BaseRepository: BaseRepository{
+			Collection: db.Collection("workspaces"),
+		},
+		members: BaseRepository{
+			Collection: db.Collection("memberships"),
+		},
+	}
+}
+
+// CreateWorkspace creates a workspace with the given user as its owner
+func (r *WorkspaceRepository) CreateWorkspace(ctx context.Context, workspace *models.Workspace) error {
+	if workspace.ID.IsZero() {
+		workspace.ID = primitive.NewObjectID()
+	}
+	if err := r.Create(ctx, workspace); err != nil {
+		return err
+	}
+
+	return r.AddMember(ctx, workspace.ID, workspace.OwnerID, models.WorkspaceOwner)
+}
+
+// EnsurePersonalWorkspace returns the user's personal workspace, creating it on first use
+func (r *WorkspaceRepository) EnsurePersonalWorkspace(ctx context.Context, userID primitive.ObjectID) (*models.Workspace, error) {
+	existing := &models.Workspace{}
+	if err := r.FindOne(ctx, bson.M{"owner_id": userID, "personal": true}, existing); err == nil {
+		return existing, nil
+	} else if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	workspace := &models.Workspace{}
+	err := r.Collection.FindOneAndUpdate(ctx,
+		bson.M{"owner_id": userID, "personal": true},
+		bson.M{"$setOnInsert": bson.M{
+			"name":       PersonalWorkspaceName,
+			"created_at": now,
+			"updated_at": now,
+		}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(workspace)
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent request created it first; the unique index rejected our insert
+		err = r.FindOne(ctx, bson.M{"owner_id": userID, "personal": true}, workspace)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.AddMember(ctx, workspace.ID, userID, models.WorkspaceOwner); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// FindWorkspaceByID finds a workspace by its hex ID
+func (r *WorkspaceRepository) FindWorkspaceByID(ctx context.Context, id string) (*models.Workspace, error) {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	workspace := &models.Workspace{}
+	err = r.FindOne(ctx, bson.M{"_id": objectID}, workspace)
+	return workspace, err
+}
+
+// FindUserWorkspaces lists the workspaces a user belongs to and their role in each
+func (r *WorkspaceRepository) FindUserWorkspaces(ctx context.Context, userID primitive.ObjectID) ([]models.Workspace, map[primitive.ObjectID]string, error) {
+	memberships := []models.Membership{}
+	if err := r.members.Find(ctx, bson.M{"user_id": userID}, &memberships); err != nil {
+		return nil, nil, err
+	}
+
+	roles := make(map[primitive.ObjectID]string, len(memberships))
+	ids := make([]primitive.ObjectID, 0, len(memberships))
+	for _, membership := range memberships {
+		roles[membership.WorkspaceID] = membership.Role
+		ids = append(ids, membership.WorkspaceID)
+	}
+
+	workspaces := []models.Workspace{}
+	if err := r.Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, &workspaces); err != nil {
+		return nil, nil, err
+	}
+	return workspaces, roles, nil
+}
+
+// FindMembership finds a user's membership of a workspace
+func (r *WorkspaceRepository) FindMembership(ctx context.Context, workspaceID, userID primitive.ObjectID) (*models.Membership, error) {
+	membership := &models.Membership{}
+	err := r.members.FindOne(ctx, bson.M{"workspace_id": workspaceID, "user_id": userID}, membership)
+	return membership, err
+}
+
+// FindMemberships finds which of the given users are members of a workspace in one query,
+// keyed by user ID
+func (r *WorkspaceRepository) FindMemberships(ctx context.Context, workspaceID primitive.ObjectID, userIDs []primitive.ObjectID) (map[primitive.ObjectID]*models.Membership, error) {
+	found := map[primitive.ObjectID]*models.Membership{}
+	if len(userIDs) == 0 {
+		return found, nil
+	}
+
+	memberships := []models.Membership{}
+	if err := r.members.Find(ctx, bson.M{"workspace_id": workspaceID, "user_id": bson.M{"$in": userIDs}}, &memberships); err != nil {
+		return nil, err
+	}
+	for i := range memberships {
+		found[memberships[i].UserID] = &memberships[i]
+	}
+	return found, nil
+}
+
+// FindMembers lists the memberships of a workspace
+func (r *WorkspaceRepository) FindMembers(ctx context.Context, workspaceID primitive.ObjectID) ([]models.Membership, error) {
+	memberships := []models.Membership{}
+	err := r.members.Find(ctx, bson.M{"workspace_id": workspaceID}, &memberships)
+	return memberships, err
+}
+
+// AddMember adds a user to a workspace. Existing members keep their current role.
+func (r *WorkspaceRepository) AddMember(ctx context.Context, workspaceID, userID primitive.ObjectID, role string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.members.Collection.UpdateOne(ctx,
+		bson.M{"workspace_id": workspaceID, "user_id": userID},
+		bson.M{"$setOnInsert": bson.M{"role": role, "created_at": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// UpdateMemberRole changes a member's role. The owner's role can't be changed this way.
+func (r *WorkspaceRepository) UpdateMemberRole(ctx context.Context, workspaceID, userID primitive.ObjectID, role string) error {
+	return r.members.Update(ctx, bson.M{
+		"workspace_id": workspaceID,
+		"user_id":      userID,
+		"role":         bson.M{"$ne": models.WorkspaceOwner},
+	}, bson.M{"$set": bson.M{"role": role}})
+}
+
+// RemoveMember removes a user from a workspace. The owner can't be removed.
+func (r *WorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID primitive.ObjectID) error {
+	return r.members.Delete(ctx, bson.M{
+		"workspace_id": workspaceID,
+		"user_id":      userID,
+		"role":         bson.M{"$ne": models.WorkspaceOwner},
+	})
+}
+
+// DeleteWorkspace deletes a workspace and its memberships
+func (r *WorkspaceRepository) DeleteWorkspace(ctx context.Context, workspaceID primitive.ObjectID) error {
+	if err := r.members.Delete(ctx, bson.M{"workspace_id": workspaceID}); err != nil {
+		return err
+	}
+	return r.Delete(ctx, bson.M{"_id": workspaceID})
+}
+
+// RemoveUser removes a user from every workspace ahead of deleting their account. Shared workspaces
+// they own pass to their longest-standing admin, or failing that member. The IDs of workspaces left
+// without anyone to own them are returned after being deleted, so their contents can be removed.
+func (r *WorkspaceRepository) RemoveUser(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	owned := []models.Workspace{}
+	if err := r.Find(ctx, bson.M{"owner_id": userID}, &owned); err != nil {
+		return nil, err
+	}
+
+	deleted := []primitive.ObjectID{}
+	for _, workspace := range owned {
+		successor, err := r.successor(ctx, workspace)
+		if err != nil {
+			return nil, err
+		}
+		if successor == nil {
+			if err := r.DeleteWorkspace(ctx, workspace.ID); err != nil {
+				return nil, err
+			}
+			deleted = append(deleted, workspace.ID)
+			continue
+		}
+
+		if err := r.members.Update(ctx, bson.M{"_id": successor.ID}, bson.M{"$set": bson.M{"role": models.WorkspaceOwner}}); err != nil {
+			return nil, err
+		}
+		if err := r.Update(ctx, bson.M{"_id": workspace.ID}, bson.M{"$set": bson.M{"owner_id": successor.UserID, "updated_at": time.Now()}}); err != nil {
+			return nil, err
+		}
+	}
+
+	return deleted, r.members.Delete(ctx, bson.M{"user_id": userID})
+}
+
+// FindOrphanedWorkspaces returns the IDs of the workspaces a user owns that nobody can take over:
+// their personal workspace and shared workspaces without another member. RemoveUser deletes them.
+func (r *WorkspaceRepository) FindOrphanedWorkspaces(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	owned := []models.Workspace{}
+	if err := r.Find(ctx, bson.M{"owner_id": userID}, &owned); err != nil {
+		return nil, err
+	}
+
+	orphaned := []primitive.ObjectID{}
+	for _, workspace := range owned {
+		successor, err := r.successor(ctx, workspace)
+		if err != nil {
+			return nil, err
+		}
+		if successor == nil {
+			orphaned = append(orphaned, workspace.ID)
+		}
+	}
+	return orphaned, nil
+}
+
+// successor picks who takes over a shared workspace from its owner, or nil if nobody can
+func (r *WorkspaceRepository) successor(ctx context.Context, workspace models.Workspace) (*models.Membership, error) {
+	if workspace.Personal {
+		return nil, nil
+	}
+
+	members, err := r.FindMembers(ctx, workspace.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *models.Membership
+	for i := range members {
+		candidate := &members[i]
+		if candidate.UserID == workspace.OwnerID || !models.WorkspaceRoleAtLeast(candidate.Role, models.WorkspaceMember) {
+			continue
+		}
+		if best == nil ||
+			models.WorkspaceRoleAtLeast(candidate.Role, models.WorkspaceAdmin) && !models.WorkspaceRoleAtLeast(best.Role, models.WorkspaceAdmin) ||
+			candidate.Role == best.Role && candidate.CreatedAt.Before(best.CreatedAt) {
+			best = candidate
+		}
+	}
+	return best, nil
+}
+
+// MigratePersonalWorkspaces gives every user a personal workspace and moves breakdowns that
+// predate workspaces into their owner's personal workspace. It is safe to run repeatedly.
+func MigratePersonalWorkspaces(ctx context.Context, users *UserRepository, workspaces *WorkspaceRepository, breakdowns *BreakdownRepository) error {
+	var all []models.User
+	if err := users.FindAll(ctx, &all); err != nil {
+		return err
+	}
+
+	for _, user := range all {
+		workspace, err := workspaces.EnsurePersonalWorkspace(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+
+		err = breakdowns.Update(ctx, bson.M{
+			"user_id":      user.ID,
+			"workspace_id": bson.M{"$exists": false},
+		}, bson.M{"$set": bson.M{"workspace_id": workspace.ID}})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"server/db/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// exportVersion is the version of the export format written by export and accepted by import
+const exportVersion = 1
+
+var (
+	errExportUsage = errors.New("usage: export [-o file] <email>")
+	errImportUsage = errors.New("usage: import [-f file] <email>")
+)
+
+// userExport is a user's profile and breakdowns in a portable form. IDs are left out so the
+// data can be imported into another account or installation.
+type userExport struct {
+	Version    int                 `json:"version"`
+	ExportedAt time.Time           `json:"exported_at"`
+	User       exportedUser        `json:"user"`
+	Breakdowns []exportedBreakdown `json:"breakdowns"`
+}
+
+type exportedUser struct {
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	DisplayName string `json:"display_name,omitempty"`
+	Timezone    string `json:"timezone,omitempty"`
+}
+
+type exportedBreakdown struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// runExport writes the breakdowns a user created, and their profile, as JSON
+func runExport(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	output := flags.String("o", "-", "file to write, or - for standard output")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errExportUsage
+	}
+
+	user, err := a.userRepo.FindUserByEmail(ctx, flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("finding user %s: %w", flags.Arg(0), err)
+	}
+	var breakdowns []models.Breakdown
+	if err := a.breakdownRepo.Find(ctx, bson.M{"user_id": user.ID}, &breakdowns); err != nil {
+		return err
+	}
+
+	export := userExport{
+		Version:    exportVersion,
+		ExportedAt: time.Now().UTC(),
+		User: exportedUser{
+			Username:    user.Username,
+			Email:       user.Email,
+			DisplayName: user.DisplayName,
+			Timezone:    user.Timezone,
+		},
+		Breakdowns: make([]exportedBreakdown, len(breakdowns)),
+	}
+	for i, breakdown := range breakdowns {
+		export.Breakdowns[i] = exportedBreakdown{
+			Name:        breakdown.Name,
+			Description: breakdown.Description,
+			Completed:   breakdown.Completed,
+			CompletedAt: breakdown.CompletedAt,
+			CreatedAt:   breakdown.CreatedAt,
+			UpdatedAt:   breakdown.UpdatedAt,
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "-" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(export); err != nil {
+		return err
+	}
+	if *output != "-" {
+		fmt.Fprintf(os.Stderr, "Exported %d breakdowns to %s\n", len(breakdowns), *output)
+	}
+	return nil
+}
+
+// runImport adds the breakdowns from an export to a user's personal workspace. Profile fields
+// in the export are not applied.
+func runImport(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	input := flags.String("f", "-", "file to read, or - for standard input")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errImportUsage
+	}
+
+	r := io.Reader(os.Stdin)
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+	var export userExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return fmt.Errorf("reading export: %w", err)
+	}
+	if export.Version != exportVersion {
+		return fmt.Errorf("unsupported export version %d", export.Version)
+	}
+	for _, imported := range export.Breakdowns {
+		if imported.Name == "" {
+			return errors.New("export contains a breakdown without a name")
+		}
+	}
+
+	user, err := a.userRepo.FindUserByEmail(ctx, flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("finding user %s: %w", flags.Arg(0), err)
+	}
+	workspace, err := a.workspaceRepo.EnsurePersonalWorkspace(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, imported := range export.Breakdowns {
+		breakdown := &models.Breakdown{
+			ID:          primitive.NewObjectID(),
+			WorkspaceID: workspace.ID,
+			UserID:      user.ID,
+			Name:        imported.Name,
+			Description: imported.Description,
+			Completed:   imported.Completed,
+			CompletedAt: imported.CompletedAt,
+			CreatedAt:   imported.CreatedAt,
+			UpdatedAt:   imported.UpdatedAt,
+		}
+		if breakdown.CreatedAt.IsZero() {
+			breakdown.CreatedAt = time.Now()
+		}
+		if breakdown.UpdatedAt.IsZero() {
+			breakdown.UpdatedAt = breakdown.CreatedAt
+		}
+		if err := a.breakdownRepo.Create(ctx, breakdown); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Imported %d breakdowns into %s's personal workspace\n", len(export.Breakdowns), user.Email)
+	return nil
+}
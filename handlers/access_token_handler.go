@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db/models"
+	"server/db/repository"
+	"server/middleware"
+	"server/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errUnknownScope  = errors.New("unknown scope")
+	errExpiryInPast  = errors.New("expires_at must be in the future")
+	errTokenNotFound = errors.New("access token not found")
+	errTokenNoScopes = errors.New("at least one scope is required")
+)
+
+// AccessTokenRequest represents the data needed to create a personal access token
+type AccessTokenRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type AccessTokenHandler struct {
+	BaseHandler
+	Repo *repository.AccessTokenRepository
+}
+
+func NewAccessTokenHandler(repo *repository.AccessTokenRepository, auditRepo *repository.AuditRepository) *AccessTokenHandler {
+	return &AccessTokenHandler{
+		BaseHandler: BaseHandler{AuditRepo: auditRepo},
+		Repo:        repo,
+	}
+}
+
+// GetAccessTokens lists the authenticated user's personal access tokens
+func (h *AccessTokenHandler) GetAccessTokens(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	tokens := []models.AccessToken{}
+	err = h.Repo.Find(c.Request.Context(), bson.M{"user_id": userObjID}, &tokens)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Respond(c, http.StatusOK, tokens)
+}
+
+// CreateAccessToken creates a personal access token; the raw token is only returned once
+func (h *AccessTokenHandler) CreateAccessToken(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	// Parse request body
+	var request AccessTokenRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	// Validate scopes and expiry
+	if len(request.Scopes) == 0 {
+		h.HandleError(c, errTokenNoScopes, http.StatusBadRequest)
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !isAccessTokenScope(scope) {
+			h.HandleError(c, fmt.Errorf("%w: %s", errUnknownScope, scope), http.StatusBadRequest)
+			return
+		}
+	}
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		h.HandleError(c, errExpiryInPast, http.StatusBadRequest)
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	raw, err := utils.GenerateAccessToken()
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	token := &models.AccessToken{
+		ID:        primitive.NewObjectID(),
+		UserID:    userObjID,
+		Name:      request.Name,
+		Scopes:    request.Scopes,
+		TokenHash: utils.HashAccessToken(raw),
+		Hint:      raw[len(raw)-4:],
+		ExpiresAt: request.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	// Save to database
+	err = h.Repo.Create(c.Request.Context(), token)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditAccessTokenCreated,
+		TargetType: "access_token",
+		TargetID:   token.ID.Hex(),
+		After:      map[string]interface{}{"name": token.Name, "scopes": token.Scopes, "expires_at": token.ExpiresAt},
+	})
+	h.Respond(c, http.StatusCreated, gin.H{
+		"token":        raw,
+		"access_token": token,
+	})
+}
+
+// RevokeAccessToken revokes one of the authenticated user's personal access tokens
+func (h *AccessTokenHandler) RevokeAccessToken(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	// Parse token ID from URL
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	// Only the owner's tokens can be revoked
+	userObjID, _ := primitive.ObjectIDFromHex(userID)
+	token := &models.AccessToken{}
+	err = h.Repo.FindOne(c.Request.Context(), bson.M{"_id": objID, "user_id": userObjID}, token)
+	if err != nil {
+		h.HandleError(c, errTokenNotFound, http.StatusNotFound)
+		return
+	}
+
+	if token.RevokedAt == nil {
+		now := time.Now()
+		err = h.Repo.Update(c.Request.Context(), bson.M{"_id": objID}, bson.M{"$set": bson.M{"revoked_at": now}})
+		if err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+		h.Audit(c, &models.AuditEvent{
+			Action:     models.AuditAccessTokenRevoked,
+			TargetType: "access_token",
+			TargetID:   objID.Hex(),
+			Before:     map[string]interface{}{"name": token.Name},
+		})
+	}
+
+	h.Respond(c, http.StatusOK, gin.H{"message": "Access token revoked successfully"})
+}
+
+// isAccessTokenScope reports whether scope can be granted to a personal access token
+func isAccessTokenScope(scope string) bool {
+	for _, known := range models.AccessTokenScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
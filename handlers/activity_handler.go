@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"server/db/models"
+	"server/db/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ActivityHandler struct {
+	BaseHandler
+	Repo          *repository.ActivityRepository
+	BreakdownRepo *repository.BreakdownRepository
+	WorkspaceRepo *repository.WorkspaceRepository
+	UserRepo      *repository.UserRepository
+}
+
+func NewActivityHandler(repo *repository.ActivityRepository, breakdownRepo *repository.BreakdownRepository, workspaceRepo *repository.WorkspaceRepository, userRepo *repository.UserRepository) *ActivityHandler {
+	return &ActivityHandler{
+		Repo:          repo,
+		BreakdownRepo: breakdownRepo,
+		WorkspaceRepo: workspaceRepo,
+		UserRepo:      userRepo,
+	}
+}
+
+// GetBreakdownActivity returns the history of a breakdown, newest first
+func (h *ActivityHandler) GetBreakdownActivity(c *gin.Context) {
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return
+	}
+
+	filter, err := activityFilter(c)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+	filter.BreakdownID = breakdown.ID
+
+	h.respondWithActivity(c, filter)
+}
+
+// GetActivity returns the activity across every workspace the authenticated user belongs to, newest first
+func (h *ActivityHandler) GetActivity(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	filter, err := activityFilter(c)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	workspaces, _, err := h.WorkspaceRepo.FindUserWorkspaces(c.Request.Context(), userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	filter.WorkspaceIDs = make([]primitive.ObjectID, len(workspaces))
+	for i := range workspaces {
+		filter.WorkspaceIDs[i] = workspaces[i].ID
+	}
+
+	h.respondWithActivity(c, filter)
+}
+
+func (h *ActivityHandler) respondWithActivity(c *gin.Context, filter repository.ActivityFilter) {
+	activities, err := h.Repo.Feed(c.Request.Context(), filter)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	actors, err := h.actorNames(c.Request.Context(), activities)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]gin.H, len(activities))
+	for i := range activities {
+		activity := &activities[i]
+		actor := actors[activity.ActorID]
+
+		views[i] = gin.H{
+			"id":           activity.ID,
+			"type":         activity.Type,
+			"actor_id":     activity.ActorID,
+			"actor":        actor,
+			"workspace_id": activity.WorkspaceID,
+			"breakdown_id": activity.BreakdownID,
+			"summary":      describeActivity(actor, activity),
+			"data":         activity.Data,
+			"count":        activity.Count,
+			"created_at":   activity.CreatedAt,
+			"updated_at":   activity.UpdatedAt,
+		}
+	}
+
+	// The last entry's ID is the cursor for the next page
+	response := gin.H{"activities": views}
+	if int64(len(activities)) == filter.Limit {
+		response["next_cursor"] = activities[len(activities)-1].ID.Hex()
+	}
+
+	h.Respond(c, http.StatusOK, response)
+}
+
+// actorNames looks up the display names or usernames of the activities' actors in one query, keyed
+// by user ID. Actors whose accounts no longer exist are named "A deleted user".
+func (h *ActivityHandler) actorNames(ctx context.Context, activities []models.Activity) (map[primitive.ObjectID]string, error) {
+	ids := make([]primitive.ObjectID, 0, len(activities))
+	names := make(map[primitive.ObjectID]string, len(activities))
+	for _, activity := range activities {
+		if _, ok := names[activity.ActorID]; !ok {
+			names[activity.ActorID] = "A deleted user"
+			ids = append(ids, activity.ActorID)
+		}
+	}
+
+	users, err := h.UserRepo.FindUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, user := range users {
+		names[id] = user.Username
+		if user.DisplayName != "" {
+			names[id] = user.DisplayName
+		}
+	}
+	return names, nil
+}
+
+// activityFilter reads the filters shared by both activity feeds from the query string
+func activityFilter(c *gin.Context) (repository.ActivityFilter, error) {
+	limit, _, err := pagination(c)
+	if err != nil {
+		return repository.ActivityFilter{}, err
+	}
+
+	filter := repository.ActivityFilter{
+		Type:  c.Query("type"),
+		Limit: limit,
+	}
+
+	if actor := c.Query("actor_id"); actor != "" {
+		filter.ActorID, err = primitive.ObjectIDFromHex(actor)
+		if err != nil {
+			return repository.ActivityFilter{}, err
+		}
+	}
+	if cursor := c.Query("cursor"); cursor != "" {
+		filter.Before, err = primitive.ObjectIDFromHex(cursor)
+		if err != nil {
+			return repository.ActivityFilter{}, err
+		}
+	}
+
+	return filter, nil
+}
+
+// describeActivity renders an activity as a sentence, e.g. "Alice renamed "Plan" to "Roadmap""
+func describeActivity(actor string, activity *models.Activity) string {
+	name := "a breakdown"
+	if value, ok := activity.Data["name"].(string); ok {
+		name = fmt.Sprintf("%q", value)
+	}
+
+	var summary string
+	switch activity.Type {
+	case models.ActivityBreakdownCreated:
+		summary = fmt.Sprintf("%s created %s", actor, name)
+	case models.ActivityBreakdownRenamed:
+		summary = fmt.Sprintf("%s renamed %q to %q", actor, activity.Data["from"], activity.Data["to"])
+	case models.ActivityBreakdownEdited:
+		summary = fmt.Sprintf("%s edited the description of %s", actor, name)
+	case models.ActivityBreakdownCompleted:
+		summary = fmt.Sprintf("%s completed %s", actor, name)
+	case models.ActivityBreakdownReopened:
+		summary = fmt.Sprintf("%s reopened %s", actor, name)
+	case models.ActivityBreakdownDuplicated:
+		summary = fmt.Sprintf("%s duplicated %q as %s", actor, activity.Data["source_name"], name)
+	case models.ActivityBreakdownDeleted:
+		summary = fmt.Sprintf("%s deleted %s", actor, name)
+	case models.ActivityBreakdownTransferred:
+		summary = fmt.Sprintf("%s took over %s", actor, name)
+	case models.ActivityTransferDeclined:
+		summary = fmt.Sprintf("%s declined to take over %s", actor, name)
+	default:
+		summary = fmt.Sprintf("%s changed %s", actor, name)
+	}
+
+	if activity.Count > 1 {
+		summary += fmt.Sprintf(" (%d edits)", activity.Count)
+	}
+	return summary
+}
@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db/models"
+	"server/db/repository"
+	"server/mail"
+	"server/middleware"
+	"server/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// impersonationTTL limits how long an admin can act as another user
+	impersonationTTL = 10 * time.Minute
+	// defaultPageSize and maxPageSize bound list endpoints
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var (
+	errCannotModifySelf    = errors.New("admins cannot disable, reset or impersonate themselves")
+	errCannotImpersonate   = errors.New("admins cannot be impersonated")
+	errCannotResetAdmin    = errors.New("admins' passwords cannot be reset by another admin")
+	errImpersonationReason = errors.New("a reason is required to impersonate a user")
+)
+
+// ImpersonateRequest represents the justification recorded for a support session
+type ImpersonateRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+type AdminHandler struct {
+	BaseHandler
+	UserRepo      *repository.UserRepository
+	BreakdownRepo *repository.BreakdownRepository
+	SessionRepo   *repository.SessionRepository
+	Mailer        mail.Mailer
+}
+
+func NewAdminHandler(userRepo *repository.UserRepository, breakdownRepo *repository.BreakdownRepository, sessionRepo *repository.SessionRepository, mailer mail.Mailer, auditRepo *repository.AuditRepository) *AdminHandler {
+	return &AdminHandler{
+		BaseHandler:   BaseHandler{AuditRepo: auditRepo},
+		UserRepo:      userRepo,
+		BreakdownRepo: breakdownRepo,
+		SessionRepo:   sessionRepo,
+		Mailer:        mailer,
+	}
+}
+
+// GetUsers lists users, optionally filtered by a search on username or email
+func (h *AdminHandler) GetUsers(c *gin.Context) {
+	limit, offset, err := pagination(c)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	users, total, err := h.UserRepo.SearchUsers(c.Request.Context(), c.Query("q"), limit, offset)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]gin.H, len(users))
+	for i := range users {
+		response[i] = adminUserView(&users[i])
+	}
+
+	h.Respond(c, http.StatusOK, gin.H{
+		"users":  response,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GetUser retrieves a single user
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, err := h.UserRepo.FindUserByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return
+	}
+
+	h.Respond(c, http.StatusOK, adminUserView(user))
+}
+
+// DisableUser blocks a user from signing in and signs them out everywhere
+func (h *AdminHandler) DisableUser(c *gin.Context) {
+	h.setDisabled(c, true)
+}
+
+// EnableUser re-enables a disabled user
+func (h *AdminHandler) EnableUser(c *gin.Context) {
+	h.setDisabled(c, false)
+}
+
+func (h *AdminHandler) setDisabled(c *gin.Context, disabled bool) {
+	user, ok := h.findTargetUser(c)
+	if !ok {
+		return
+	}
+
+	err := h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"disabled": disabled, "updated_at": time.Now()},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	if disabled {
+		if err := h.SessionRepo.RevokeOtherSessions(c.Request.Context(), user.ID, primitive.NilObjectID); err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	action := models.AuditUserEnabled
+	if disabled {
+		action = models.AuditUserDisabled
+	}
+	h.Audit(c, &models.AuditEvent{
+		Action:     action,
+		UserID:     &user.ID,
+		TargetType: "user",
+		TargetID:   user.ID.Hex(),
+		Before:     map[string]interface{}{"disabled": user.Disabled},
+		After:      map[string]interface{}{"disabled": disabled},
+	})
+
+	user.Disabled = disabled
+	h.Respond(c, http.StatusOK, adminUserView(user))
+}
+
+// ForcePasswordReset invalidates a user's password, signs them out and emails them a reset link
+func (h *AdminHandler) ForcePasswordReset(c *gin.Context) {
+	user, ok := h.findTargetUser(c)
+	if !ok {
+		return
+	}
+	// Admins are reset from the command line, so one admin can't lock the others out
+	if user.IsAdmin() {
+		h.HandleError(c, errCannotResetAdmin, http.StatusForbidden)
+		return
+	}
+
+	token, err := h.UserRepo.ForcePasswordReset(c.Request.Context(), user.ID, PasswordResetTTL)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.SessionRepo.RevokeOtherSessions(c.Request.Context(), user.ID, primitive.NilObjectID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := SendForcedPasswordReset(c.Request.Context(), h.Mailer, user, token); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditUserPasswordReset,
+		UserID:     &user.ID,
+		TargetType: "user",
+		TargetID:   user.ID.Hex(),
+	})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Password reset email sent"})
+}
+
+// SendForcedPasswordReset emails a user whose password was reset by an administrator a link to choose a new one
+func SendForcedPasswordReset(ctx context.Context, mailer mail.Mailer, user *models.User, token string) error {
+	return mailer.Send(ctx, mail.Message{
+		To:      user.Email,
+		Subject: "Reset your flow password",
+		Body: fmt.Sprintf("Hi %s,\n\nAn administrator has reset the password on your flow account. Choose a new password by opening the link below within 24 hours:\n\n%s/reset-password?token=%s\n",
+			user.Username, appURL(), token),
+	})
+}
+
+// ImpersonateUser issues a short-lived token that lets an admin act as a user for support.
+// The session records the admin and their reason.
+func (h *AdminHandler) ImpersonateUser(c *gin.Context) {
+	var request ImpersonateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, errImpersonationReason, http.StatusBadRequest)
+		return
+	}
+
+	user, ok := h.findTargetUser(c)
+	if !ok {
+		return
+	}
+	if user.IsAdmin() {
+		h.HandleError(c, errCannotImpersonate, http.StatusForbidden)
+		return
+	}
+	if user.Disabled {
+		h.HandleError(c, errAccountDisabled, http.StatusForbidden)
+		return
+	}
+
+	adminID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+	adminObjID, _ := primitive.ObjectIDFromHex(adminID)
+
+	now := time.Now()
+	session := &models.Session{
+		ID:                  primitive.NewObjectID(),
+		UserID:              user.ID,
+		ImpersonatorID:      &adminObjID,
+		ImpersonationReason: request.Reason,
+		UserAgent:           c.Request.UserAgent(),
+		IP:                  c.ClientIP(),
+		CreatedAt:           now,
+		LastSeenAt:          now,
+		ExpiresAt:           now.Add(impersonationTTL),
+	}
+	if err := h.SessionRepo.Create(c.Request.Context(), session); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	token, err := utils.GenerateToken(user.ID.Hex(), session.ID.Hex())
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditUserImpersonated,
+		UserID:     &user.ID,
+		TargetType: "user",
+		TargetID:   user.ID.Hex(),
+		Metadata: map[string]interface{}{
+			"session_id": session.ID.Hex(),
+			"reason":     request.Reason,
+			"expires_at": session.ExpiresAt,
+		},
+	})
+
+	h.Respond(c, http.StatusOK, gin.H{
+		"token":      token,
+		"session_id": session.ID.Hex(),
+		"expires_at": session.ExpiresAt,
+		"user":       adminUserView(user),
+	})
+}
+
+// GetStats returns system-wide user and breakdown counts
+func (h *AdminHandler) GetStats(c *gin.Context) {
+	ctx := c.Request.Context()
+	counts := []struct {
+		name   string
+		repo   *repository.BaseRepository
+		filter bson.M
+	}{
+		{"users", &h.UserRepo.BaseRepository, bson.M{}},
+		{"disabled_users", &h.UserRepo.BaseRepository, bson.M{"disabled": true}},
+		{"admins", &h.UserRepo.BaseRepository, bson.M{"role": models.RoleAdmin}},
+		{"breakdowns", &h.BreakdownRepo.BaseRepository, bson.M{}},
+		{"completed_breakdowns", &h.BreakdownRepo.BaseRepository, bson.M{"completed": true}},
+		{"active_sessions", &h.SessionRepo.BaseRepository, bson.M{
+			"revoked_at": bson.M{"$exists": false},
+			"expires_at": bson.M{"$gt": time.Now()},
+		}},
+	}
+
+	stats := gin.H{}
+	for _, count := range counts {
+		n, err := count.repo.Count(ctx, count.filter)
+		if err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+		stats[count.name] = n
+	}
+
+	h.Respond(c, http.StatusOK, stats)
+}
+
+// findTargetUser loads the user from the URL, refusing actions an admin takes on their own account
+func (h *AdminHandler) findTargetUser(c *gin.Context) (*models.User, bool) {
+	user, err := h.UserRepo.FindUserByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return nil, false
+	}
+
+	if adminID, _ := middleware.GetUserID(c); adminID == user.ID.Hex() {
+		h.HandleError(c, errCannotModifySelf, http.StatusBadRequest)
+		return nil, false
+	}
+	return user, true
+}
+
+// adminUserView is the representation of a user shown to admins (excludes secrets)
+func adminUserView(user *models.User) gin.H {
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return gin.H{
+		"id":                    user.ID.Hex(),
+		"username":              user.Username,
+		"email":                 user.Email,
+		"display_name":          user.DisplayName,
+		"role":                  role,
+		"disabled":              user.Disabled,
+		"two_factor_enabled":    user.TOTPEnabled,
+		"locked_until":          user.LockedUntil,
+		"deletion_scheduled_at": user.DeletionScheduledAt,
+		"created_at":            user.CreatedAt,
+		"updated_at":            user.UpdatedAt,
+	}
+}
+
+// pagination reads limit and offset query parameters
+func pagination(c *gin.Context) (limit, offset int64, err error) {
+	limit = defaultPageSize
+	if value := c.Query("limit"); value != "" {
+		limit, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if value := c.Query("offset"); value != "" {
+		offset, err = strconv.ParseInt(value, 10, 64)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
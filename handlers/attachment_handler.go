@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"server/db/models"
+	"server/db/repository"
+	"server/logging"
+	"server/middleware"
+	"server/storage"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// multipartOverhead allows for the multipart framing around an uploaded file
+const multipartOverhead = 64 << 10
+
+// allowedAttachmentTypes are the MIME types accepted for attachments, as sniffed from the contents.
+// Anything a browser might render as active content (HTML, SVG) is excluded.
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+}
+
+var (
+	errMissingFile          = errors.New("a file is required")
+	errAttachmentTooLarge   = errors.New("file is too large")
+	errAttachmentType       = errors.New("file type is not allowed")
+	errAttachmentNotFound   = errors.New("attachment not found")
+	errNotAttachmentOwner   = errors.New("only the uploader or a workspace admin can delete an attachment")
+	errAttachmentUnreadable = errors.New("attachment contents are unavailable")
+)
+
+type AttachmentHandler struct {
+	BaseHandler
+	Repo          *repository.AttachmentRepository
+	BreakdownRepo *repository.BreakdownRepository
+	Store         storage.BlobStore
+	MaxSize       int64
+}
+
+func NewAttachmentHandler(repo *repository.AttachmentRepository, breakdownRepo *repository.BreakdownRepository, store storage.BlobStore, maxSize int64, auditRepo *repository.AuditRepository) *AttachmentHandler {
+	return &AttachmentHandler{
+		BaseHandler:   BaseHandler{AuditRepo: auditRepo},
+		Repo:          repo,
+		BreakdownRepo: breakdownRepo,
+		Store:         store,
+		MaxSize:       maxSize,
+	}
+}
+
+// GetAttachments lists the files attached to a breakdown
+func (h *AttachmentHandler) GetAttachments(c *gin.Context) {
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return
+	}
+
+	attachments := []models.Attachment{}
+	if err := h.Repo.Find(c.Request.Context(), bson.M{"breakdown_id": breakdown.ID}, &attachments); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.Respond(c, http.StatusOK, attachments)
+}
+
+// UploadAttachment attaches the multipart "file" field to a breakdown. The type is sniffed from the
+// contents rather than trusted from the client.
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return
+	}
+
+	header, data, contentType, ok := h.readUpload(c)
+	if !ok {
+		return
+	}
+
+	checksum := sha256.Sum256(data)
+	attachment := &models.Attachment{
+		ID:          primitive.NewObjectID(),
+		BreakdownID: breakdown.ID,
+		UploaderID:  userID,
+		Filename:    sanitizeFilename(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(checksum[:]),
+		CreatedAt:   time.Now(),
+	}
+	attachment.StorageKey = fmt.Sprintf("attachments/%s/%s", breakdown.ID.Hex(), attachment.ID.Hex())
+
+	if err := h.Store.Put(c.Request.Context(), attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.Repo.Create(c.Request.Context(), attachment); err != nil {
+		// Don't leave a blob behind that nothing refers to
+		if err := h.Store.Delete(c.Request.Context(), attachment.StorageKey); err != nil {
+			logging.FromContext(c.Request.Context()).Error("Failed to delete blob", "key", attachment.StorageKey, "error", err)
+		}
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditAttachmentUploaded,
+		TargetType: "breakdown",
+		TargetID:   breakdown.ID.Hex(),
+		Metadata:   map[string]interface{}{"attachment_id": attachment.ID.Hex(), "filename": attachment.Filename, "sha256": attachment.SHA256},
+	})
+	h.Respond(c, http.StatusCreated, attachment)
+}
+
+// DownloadAttachment streams an attachment's contents, always as a download rather than inline
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+
+	etag := `"` + attachment.SHA256 + `"`
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	contents, err := h.Store.Get(c.Request.Context(), attachment.StorageKey)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to read blob", "key", attachment.StorageKey, "error", err)
+		h.HandleError(c, errAttachmentUnreadable, http.StatusBadGateway)
+		return
+	}
+	defer contents.Close()
+
+	checksum, _ := hex.DecodeString(attachment.SHA256)
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, contents, map[string]string{
+		"Content-Disposition":    "attachment; filename*=UTF-8''" + url.PathEscape(attachment.Filename),
+		"X-Content-Type-Options": "nosniff",
+		"ETag":                   etag,
+		"Repr-Digest":            "sha-256=:" + base64.StdEncoding.EncodeToString(checksum) + ":",
+	})
+}
+
+// DeleteAttachment removes an attachment. Uploaders can delete their own files and workspace admins any file.
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+	if attachment.UploaderID != userID && !models.WorkspaceRoleAtLeast(middleware.GetWorkspaceRole(c), models.WorkspaceAdmin) {
+		h.HandleError(c, errNotAttachmentOwner, http.StatusForbidden)
+		return
+	}
+
+	// Remove the blob first; a record without its blob can still be deleted on retry
+	if err := h.Store.Delete(c.Request.Context(), attachment.StorageKey); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.Repo.Delete(c.Request.Context(), bson.M{"_id": attachment.ID}); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditAttachmentDeleted,
+		TargetType: "breakdown",
+		TargetID:   attachment.BreakdownID.Hex(),
+		Metadata:   map[string]interface{}{"attachment_id": attachment.ID.Hex(), "filename": attachment.Filename},
+	})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Attachment deleted successfully"})
+}
+
+// readUpload reads the multipart "file" field, enforcing the size limit and sniffing the type from the
+// contents. Rejected uploads are answered here.
+func (h *AttachmentHandler) readUpload(c *gin.Context) (*multipart.FileHeader, []byte, string, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxSize+multipartOverhead)
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.HandleError(c, errAttachmentTooLarge, http.StatusRequestEntityTooLarge)
+			return nil, nil, "", false
+		}
+		h.HandleError(c, errMissingFile, http.StatusBadRequest)
+		return nil, nil, "", false
+	}
+	if header.Size > h.MaxSize {
+		h.HandleError(c, errAttachmentTooLarge, http.StatusRequestEntityTooLarge)
+		return nil, nil, "", false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return nil, nil, "", false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, h.MaxSize+1))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return nil, nil, "", false
+	}
+	if int64(len(data)) > h.MaxSize {
+		h.HandleError(c, errAttachmentTooLarge, http.StatusRequestEntityTooLarge)
+		return nil, nil, "", false
+	}
+
+	contentType := http.DetectContentType(data)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || !allowedAttachmentTypes[mediaType] {
+		h.HandleError(c, errAttachmentType, http.StatusUnsupportedMediaType)
+		return nil, nil, "", false
+	}
+
+	return header, data, contentType, true
+}
+
+// findAttachment loads the attachment from the URL, which must belong to a breakdown in the current workspace
+func (h *AttachmentHandler) findAttachment(c *gin.Context) (*models.Attachment, bool) {
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return nil, false
+	}
+
+	objID, err := primitive.ObjectIDFromHex(c.Param("attachment_id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	attachment := &models.Attachment{}
+	if err := h.Repo.FindOne(c.Request.Context(), bson.M{"_id": objID, "breakdown_id": breakdown.ID}, attachment); err != nil {
+		h.HandleError(c, errAttachmentNotFound, http.StatusNotFound)
+		return nil, false
+	}
+	return attachment, true
+}
+
+// sanitizeFilename keeps the base name of an uploaded file, without control characters or excessive length
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = "attachment"
+	}
+	return name
+}
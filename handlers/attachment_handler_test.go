@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pngHeader is enough of a PNG file for content sniffing
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// uploadContext returns a context for a multipart upload of contents as the "file" field, declared
+// with the given filename and content type
+func uploadContext(t *testing.T, field, filename, contentType string, contents []byte) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="`+field+`"; filename="`+filename+`"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(contents)
+	writer.Close()
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/breakdowns/1/attachments", &body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+	return c, recorder
+}
+
+func TestReadUpload(t *testing.T) {
+	const maxSize = 1024
+	tests := []struct {
+		name        string
+		field       string
+		filename    string
+		contentType string
+		contents    []byte
+		wantStatus  int    // 0 when the upload is accepted
+		wantType    string // sniffed type of accepted uploads
+	}{
+		{
+			name: "png", field: "file", filename: "photo.png", contentType: "image/png",
+			contents: pngHeader, wantType: "image/png",
+		},
+		{
+			name: "plain text declared as an image", field: "file", filename: "notes.png", contentType: "image/png",
+			contents: []byte("just some notes"), wantType: "text/plain; charset=utf-8",
+		},
+		{
+			name: "html declared as an image", field: "file", filename: "photo.png", contentType: "image/png",
+			contents: []byte("<!DOCTYPE html><script>alert(1)</script>"), wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name: "svg", field: "file", filename: "icon.svg", contentType: "image/svg+xml",
+			contents: []byte(`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`), wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			// Without an XML declaration the markup sniffs as text, and is only ever served as such
+			name: "svg without a declaration", field: "file", filename: "icon.svg", contentType: "image/svg+xml",
+			contents: []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), wantType: "text/plain; charset=utf-8",
+		},
+		{
+			name: "exactly the size limit", field: "file", filename: "notes.txt", contentType: "text/plain",
+			contents: []byte(strings.Repeat("a", maxSize)), wantType: "text/plain; charset=utf-8",
+		},
+		{
+			name: "one byte over the size limit", field: "file", filename: "notes.txt", contentType: "text/plain",
+			contents: []byte(strings.Repeat("a", maxSize+1)), wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "body larger than the limit allows for", field: "file", filename: "notes.txt", contentType: "text/plain",
+			contents: []byte(strings.Repeat("a", maxSize+multipartOverhead+1)), wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "missing file", field: "other", filename: "notes.txt", contentType: "text/plain",
+			contents: []byte("notes"), wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &AttachmentHandler{MaxSize: maxSize}
+			c, recorder := uploadContext(t, tt.field, tt.filename, tt.contentType, tt.contents)
+
+			_, data, contentType, ok := h.readUpload(c)
+			if tt.wantStatus != 0 {
+				if ok || recorder.Code != tt.wantStatus {
+					t.Errorf("readUpload = %v with status %d, want rejection with %d", ok, recorder.Code, tt.wantStatus)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("readUpload rejected the upload: %d %s", recorder.Code, recorder.Body.String())
+			}
+			if contentType != tt.wantType || !bytes.Equal(data, tt.contents) {
+				t.Errorf("readUpload = %d bytes of %q, want %d bytes of %q", len(data), contentType, len(tt.contents), tt.wantType)
+			}
+		})
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := map[string]string{
+		"report.pdf":             "report.pdf",
+		"../../etc/passwd":       "passwd",
+		`C:\Users\ada\notes.txt`: "notes.txt",
+		"line\nbreak.txt":        "linebreak.txt",
+		"":                       "attachment",
+		"..":                     "attachment",
+		"/":                      "attachment",
+		strings.Repeat("é", 300): strings.Repeat("é", 255),
+	}
+	for name, want := range tests {
+		if got := sanitizeFilename(name); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
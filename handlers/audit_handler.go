@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"server/db/repository"
+	"server/middleware"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errInvalidTime = errors.New("from and to must be RFC 3339 timestamps")
+
+type AuditHandler struct {
+	BaseHandler
+	Repo *repository.AuditRepository
+}
+
+func NewAuditHandler(repo *repository.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		Repo: repo,
+	}
+}
+
+// GetAuditEvents lets admins query the whole audit log
+func (h *AuditHandler) GetAuditEvents(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	for param, target := range map[string]**primitive.ObjectID{"user_id": &filter.UserID, "actor_id": &filter.ActorID} {
+		if value := c.Query(param); value != "" {
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				h.HandleError(c, err, http.StatusBadRequest)
+				return
+			}
+			*target = &id
+		}
+	}
+
+	h.respondWithEvents(c, filter)
+}
+
+// GetMyAuditEvents returns the audit events for the authenticated user's own account
+func (h *AuditHandler) GetMyAuditEvents(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	filter, err := auditFilter(c)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+	filter.UserID = &userObjID
+
+	h.respondWithEvents(c, filter)
+}
+
+func (h *AuditHandler) respondWithEvents(c *gin.Context, filter repository.AuditFilter) {
+	events, err := h.Repo.Find(c.Request.Context(), filter)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// The last event's ID is the cursor for the next page
+	response := gin.H{"events": events}
+	if int64(len(events)) == filter.Limit {
+		response["next_cursor"] = events[len(events)-1].ID.Hex()
+	}
+
+	h.Respond(c, http.StatusOK, response)
+}
+
+// auditFilter reads the filters shared by both audit views from the query string
+func auditFilter(c *gin.Context) (repository.AuditFilter, error) {
+	limit, _, err := pagination(c)
+	if err != nil {
+		return repository.AuditFilter{}, err
+	}
+
+	filter := repository.AuditFilter{
+		Action:   c.Query("action"),
+		TargetID: c.Query("target_id"),
+		Limit:    limit,
+	}
+
+	for param, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return repository.AuditFilter{}, errInvalidTime
+			}
+			*target = parsed
+		}
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		before, err := primitive.ObjectIDFromHex(cursor)
+		if err != nil {
+			return repository.AuditFilter{}, err
+		}
+		filter.Before = before
+	}
+
+	return filter, nil
+}
@@ -1,18 +1,37 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
 	"server/db/models"
 	"server/db/repository"
+	"server/mail"
+	"server/metrics"
+	"server/middleware"
 	"server/utils"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+var (
+	errAccountDisabled          = errors.New("account is disabled")
+	errReauthenticationRequired = errors.New("password or a recent sign in with your identity provider is required")
+)
+
+// reauthenticationWindow is how long after signing in with an identity provider a session may make
+// sensitive changes without the account password
+const reauthenticationWindow = 10 * time.Minute
+
 type AuthHandler struct {
 	BaseHandler
-	UserRepo *repository.UserRepository
+	UserRepo    *repository.UserRepository
+	SessionRepo *repository.SessionRepository
+	Mailer      mail.Mailer
 }
 
 // LoginRequest represents the login form data
@@ -28,9 +47,12 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
-func NewAuthHandler(repo *repository.UserRepository) *AuthHandler {
+func NewAuthHandler(repo *repository.UserRepository, sessionRepo *repository.SessionRepository, mailer mail.Mailer, auditRepo *repository.AuditRepository) *AuthHandler {
 	return &AuthHandler{
-		UserRepo: repo,
+		BaseHandler: BaseHandler{AuditRepo: auditRepo},
+		UserRepo:    repo,
+		SessionRepo: sessionRepo,
+		Mailer:      mailer,
 	}
 }
 
@@ -45,26 +67,62 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	// Validate credentials
 	user, err := h.UserRepo.ValidateCredentials(c.Request.Context(), request.Email, request.Password)
 	if err != nil {
-		h.HandleError(c, err, http.StatusUnauthorized)
+		event := &models.AuditEvent{
+			Action:   models.AuditLoginFailed,
+			Metadata: map[string]interface{}{"method": "password", "reason": err.Error()},
+		}
+		if existing, err := h.UserRepo.FindUserByEmail(c.Request.Context(), request.Email); err == nil {
+			event.UserID = &existing.ID
+		} else {
+			event.Metadata["email_hash"] = hashEmail(request.Email)
+		}
+		h.Audit(c, event)
+		metrics.LoginAttempt("password", loginFailure(err))
+		h.handleCredentialsError(c, err)
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID.Hex())
-	if err != nil {
-		h.HandleError(c, err, http.StatusInternalServerError)
+	h.completeLogin(c, user, "password")
+}
+
+// completeLogin issues a JWT token for a user whose first factor (verified by method) has been checked,
+// or a challenge token if they must still complete two-factor authentication
+func (h *AuthHandler) completeLogin(c *gin.Context, user *models.User, method string) {
+	if !h.checkEnabled(c, user, method) {
 		return
 	}
 
-	// Return the token
-	h.Respond(c, http.StatusOK, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":       user.ID.Hex(),
-			"username": user.Username,
-			"email":    user.Email,
-		},
+	// Users with two-factor authentication must complete a second step
+	if user.TOTPEnabled {
+		challengeID, err := utils.GenerateRandomToken()
+		if err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+		if err := h.UserRepo.StartTwoFactorChallenge(c.Request.Context(), user.ID, challengeID); err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+		challenge, err := utils.GenerateChallengeToken(user.ID.Hex(), method, challengeID)
+		if err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+		metrics.LoginAttempt(method, metrics.LoginTwoFactorRequired)
+		h.Respond(c, http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:   models.AuditLoginSucceeded,
+		ActorID:  &user.ID,
+		Metadata: map[string]interface{}{"method": method},
 	})
+	metrics.LoginAttempt(method, metrics.LoginSucceeded)
+	h.respondWithToken(c, http.StatusOK, user, method)
 }
 
 // Register handles user registration
@@ -91,15 +149,65 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	h.Audit(c, &models.AuditEvent{
+		Action:   models.AuditRegistered,
+		ActorID:  &user.ID,
+		After:    map[string]interface{}{"username": user.Username, "email": user.Email},
+		Metadata: map[string]interface{}{"method": "password"},
+	})
+	h.respondWithToken(c, http.StatusCreated, user, "password")
+}
+
+// checkEnabled rejects a login to a disabled account, recording it as a failed attempt
+func (h *AuthHandler) checkEnabled(c *gin.Context, user *models.User, method string) bool {
+	if !user.Disabled {
+		return true
+	}
+	h.Audit(c, &models.AuditEvent{
+		Action:   models.AuditLoginFailed,
+		UserID:   &user.ID,
+		Metadata: map[string]interface{}{"method": method, "reason": errAccountDisabled.Error()},
+	})
+	metrics.LoginAttempt(method, metrics.LoginDisabled)
+	h.HandleError(c, errAccountDisabled, http.StatusForbidden)
+	return false
+}
+
+// respondWithToken starts a session for a user who signed in by method and returns a JWT token for it
+// with their details. Logins must have checked that the account is enabled.
+func (h *AuthHandler) respondWithToken(c *gin.Context, status int, user *models.User, method string) {
+	// Record the device signing in
+	now := time.Now()
+	session := &models.Session{
+		ID:         primitive.NewObjectID(),
+		UserID:     user.ID,
+		UserAgent:  c.Request.UserAgent(),
+		IP:         c.ClientIP(),
+		Method:     method,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(utils.TokenTTL),
+	}
+	if err := h.SessionRepo.Create(c.Request.Context(), session); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
 	// Generate JWT token
-	token, err := utils.GenerateToken(user.ID.Hex())
+	token, err := utils.GenerateToken(user.ID.Hex(), session.ID.Hex())
 	if err != nil {
 		h.HandleError(c, err, http.StatusInternalServerError)
 		return
 	}
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditTokenIssued,
+		ActorID:    &user.ID,
+		TargetType: "session",
+		TargetID:   session.ID.Hex(),
+	})
 
 	// Return the token
-	h.Respond(c, http.StatusCreated, gin.H{
+	h.Respond(c, status, gin.H{
 		"token": token,
 		"user": gin.H{
 			"id":       user.ID.Hex(),
@@ -127,10 +235,84 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 
 	// Return user profile (exclude password)
 	h.Respond(c, http.StatusOK, gin.H{
-		"id":        user.ID.Hex(),
-		"username":  user.Username,
-		"email":     user.Email,
-		"createdAt": user.CreatedAt,
-		"updatedAt": user.UpdatedAt,
+		"id":                  user.ID.Hex(),
+		"username":            user.Username,
+		"email":               user.Email,
+		"displayName":         user.DisplayName,
+		"timezone":            user.Timezone,
+		"twoFactorEnabled":    user.TOTPEnabled,
+		"deletionScheduledAt": user.DeletionScheduledAt,
+		"createdAt":           user.CreatedAt,
+		"updatedAt":           user.UpdatedAt,
 	})
 }
+
+// currentUser loads the authenticated user, responding with an error if they can't be found
+func (h *AuthHandler) currentUser(c *gin.Context) (*models.User, error) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return nil, err
+	}
+
+	user, err := h.UserRepo.FindUserByID(c.Request.Context(), userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return nil, err
+	}
+	return user, nil
+}
+
+// reauthenticate checks that the caller still holds the account before a sensitive change, either by
+// its password or, since accounts created through OIDC don't know theirs, by the current session having
+// signed in with an identity provider in the last few minutes. It responds with 401 when neither holds.
+// Wrong passwords count towards the login lockout, so this can't be used to guess the password.
+func (h *AuthHandler) reauthenticate(c *gin.Context, user *models.User, password string) bool {
+	if password != "" {
+		err := h.UserRepo.VerifyPassword(c.Request.Context(), user, password)
+		if errors.Is(err, repository.ErrWrongPassword) {
+			err = errInvalidPassword
+		}
+		if err != nil {
+			h.handleCredentialsError(c, err)
+			return false
+		}
+		return true
+	}
+
+	sessionID := middleware.GetSessionID(c)
+	if sessionID != "" && middleware.GetImpersonatorID(c) == "" {
+		session, err := h.SessionRepo.FindActiveSession(c.Request.Context(), sessionID)
+		if err == nil && strings.HasPrefix(session.Method, "oidc:") && time.Since(session.CreatedAt) < reauthenticationWindow {
+			return true
+		}
+	}
+	h.HandleError(c, errReauthenticationRequired, http.StatusUnauthorized)
+	return false
+}
+
+// hashEmail identifies an email address in audit events without storing it, so attempts against
+// the same unknown address can still be told apart
+func hashEmail(email string) string {
+	return utils.HashToken(strings.ToLower(strings.TrimSpace(email)))
+}
+
+// loginFailure returns the metrics result for a failed password or second factor check
+func loginFailure(err error) string {
+	var locked *repository.AccountLockedError
+	if errors.As(err, &locked) {
+		return metrics.LoginLocked
+	}
+	return metrics.LoginFailed
+}
+
+// handleCredentialsError responds to a failed password or second factor check
+func (h *AuthHandler) handleCredentialsError(c *gin.Context, err error) {
+	var locked *repository.AccountLockedError
+	if errors.As(err, &locked) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(locked.Until).Seconds()))))
+		h.HandleError(c, err, http.StatusTooManyRequests)
+		return
+	}
+	h.HandleError(c, err, http.StatusUnauthorized)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"server/db/models"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCompleteLoginRejectsDisabledAccounts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, totp := range []bool{false, true} {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		c.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+
+		// With no repositories or signing key, only the disabled check can answer 403
+		h := &AuthHandler{}
+		h.completeLogin(c, &models.User{ID: primitive.NewObjectID(), Disabled: true, TOTPEnabled: totp}, "password")
+		if recorder.Code != http.StatusForbidden {
+			t.Errorf("completeLogin with two-factor %v = %d %s, want 403", totp, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestHashEmail(t *testing.T) {
+	hash := hashEmail(" Ada@Example.com")
+	if hash != hashEmail("ada@example.com") || strings.Contains(hash, "ada") {
+		t.Errorf("hashEmail = %q, want the same opaque hash for either spelling", hash)
+	}
+}
@@ -1,26 +1,123 @@
 package handlers
 
 import (
+	"context"
 	"net/http"
+	"server/db/models"
+	"server/db/repository"
+	"server/logging"
+	"server/middleware"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // BaseHandler provides common response methods for all handlers.
-type BaseHandler struct{}
+type BaseHandler struct {
+	AuditRepo *repository.AuditRepository // Audit log; nil for handlers that don't record events
+}
 
 // Respond sends a JSON response with a status code.
 func (h *BaseHandler) Respond(c *gin.Context, status int, data interface{}) {
 	c.JSON(status, data)
 }
 
-// HandleError sends a standardized error response.
+// HandleError sends a standardized error response. Server errors are attached to the
+// request so they appear in its log entry.
 func (h *BaseHandler) HandleError(c *gin.Context, err error, status int) {
+	if status >= http.StatusInternalServerError {
+		c.Error(err)
+	}
 	c.JSON(status, gin.H{
 		"error": err.Error(),
 	})
 }
 
+// Audit records a security-relevant action, filling in the actor and request details.
+// Failures are logged rather than failing the request, since the action has already happened.
+func (h *BaseHandler) Audit(c *gin.Context, event *models.AuditEvent) {
+	if h.AuditRepo == nil {
+		return
+	}
+
+	if event.ActorID == nil {
+		if userID, err := middleware.GetUserID(c); err == nil {
+			if actorID, err := primitive.ObjectIDFromHex(userID); err == nil {
+				event.ActorID = &actorID
+			}
+		}
+	}
+	if impersonatorID, err := primitive.ObjectIDFromHex(middleware.GetImpersonatorID(c)); err == nil {
+		event.ImpersonatorID = &impersonatorID
+	}
+	if event.UserID == nil {
+		event.UserID = event.ActorID
+	}
+	event.IP = c.ClientIP()
+	event.UserAgent = c.Request.UserAgent()
+	event.RequestID = middleware.GetRequestID(c)
+
+	if err := h.AuditRepo.Record(context.WithoutCancel(c.Request.Context()), event); err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to record audit event", "action", event.Action, "error", err)
+	}
+}
+
+// currentWorkspace returns the workspace the request is scoped to
+func (h *BaseHandler) currentWorkspace(c *gin.Context) (primitive.ObjectID, bool) {
+	workspaceID, err := middleware.GetWorkspaceID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return primitive.NilObjectID, false
+	}
+
+	objID, err := primitive.ObjectIDFromHex(workspaceID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	return objID, true
+}
+
+// currentUserID returns the authenticated user's ID
+func (h *BaseHandler) currentUserID(c *gin.Context) (primitive.ObjectID, bool) {
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return primitive.NilObjectID, false
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return primitive.NilObjectID, false
+	}
+	return objID, true
+}
+
+// findBreakdown loads the breakdown from the URL, which must belong to the current workspace
+func (h *BaseHandler) findBreakdown(c *gin.Context, repo *repository.BreakdownRepository) (*models.Breakdown, bool) {
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return nil, false
+	}
+
+	// Parse breakdown ID from URL
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	breakdown := &models.Breakdown{}
+	err = repo.FindOne(c.Request.Context(), bson.M{"_id": objID, "workspace_id": workspaceID}, breakdown)
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return nil, false
+	}
+	return breakdown, true
+}
+
 // Create handles the creation of resources (a generic method for creating documents).
 func (h *BaseHandler) Create(c *gin.Context, repo interface{}, document interface{}) {
 	// Bind JSON payload into the document (i.e., breakdown model, user model, etc.)
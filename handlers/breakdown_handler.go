@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"io"
 	"net/http"
 	"server/db/models"
 	"server/db/repository"
+	"server/logging"
 	"server/middleware"
 	"time"
 
@@ -19,83 +22,77 @@ var errUnauthorized = errors.New("unauthorized access")
 type BreakdownRequest struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description"`
+	Completed   bool   `json:"completed"`
 }
 
+// DuplicateRequest represents the options for duplicating a breakdown
+type DuplicateRequest struct {
+	Suffix          *string `json:"suffix"`
+	ResetCompletion bool    `json:"reset_completion"`
+}
+
+// defaultDuplicateSuffix is appended to the name of a duplicated breakdown when no suffix is given
+const defaultDuplicateSuffix = " (copy)"
+
 type BreakdownHandler struct {
 	BaseHandler
-	Repo *repository.BreakdownRepository
+	Repo         *repository.BreakdownRepository
+	CommentRepo  *repository.CommentRepository
+	ActivityRepo *repository.ActivityRepository
 }
 
-func NewBreakdownHandler(repo *repository.BreakdownRepository) *BreakdownHandler {
+func NewBreakdownHandler(repo *repository.BreakdownRepository, commentRepo *repository.CommentRepository, activityRepo *repository.ActivityRepository, auditRepo *repository.AuditRepository) *BreakdownHandler {
 	return &BreakdownHandler{
-		Repo: repo,
+		BaseHandler:  BaseHandler{AuditRepo: auditRepo},
+		Repo:         repo,
+		CommentRepo:  commentRepo,
+		ActivityRepo: activityRepo,
 	}
 }
 
-// GetBreakdowns retrieves all breakdowns for the authenticated user
+// GetBreakdowns retrieves all breakdowns in the current workspace
 func (h *BreakdownHandler) GetBreakdowns(c *gin.Context) {
-	// Get user ID from context (set by auth middleware)
-	userID, err := middleware.GetUserID(c)
-	if err != nil {
-		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
 		return
 	}
 
-	userObjID, err := primitive.ObjectIDFromHex(userID)
+	// Find all breakdowns in this workspace
+	var breakdowns []models.Breakdown
+	err := h.Repo.Find(c.Request.Context(), bson.M{"workspace_id": workspaceID}, &breakdowns)
 	if err != nil {
-		h.HandleError(c, err, http.StatusBadRequest)
+		h.HandleError(c, err, http.StatusInternalServerError)
 		return
 	}
 
-	// Find all breakdowns for this user
-	var breakdowns []models.Breakdown
-	err = h.Repo.Find(c.Request.Context(), bson.M{"user_id": userObjID}, &breakdowns)
+	// Include the size of each discussion
+	ids := make([]primitive.ObjectID, len(breakdowns))
+	for i := range breakdowns {
+		ids[i] = breakdowns[i].ID
+	}
+	counts, err := h.CommentRepo.CountByBreakdown(c.Request.Context(), ids)
 	if err != nil {
 		h.HandleError(c, err, http.StatusInternalServerError)
 		return
 	}
+	for i := range breakdowns {
+		breakdowns[i].CommentCount = counts[breakdowns[i].ID]
+	}
 
 	h.Respond(c, http.StatusOK, breakdowns)
 }
 
 // GetBreakdownByID retrieves a specific breakdown by ID
 func (h *BreakdownHandler) GetBreakdownByID(c *gin.Context) {
-	// Get user ID from context
-	userID, err := middleware.GetUserID(c)
-	if err != nil {
-		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
-		return
-	}
-
-	// Parse breakdown ID from URL
-	breakdownID := c.Param("id")
-	objID, err := primitive.ObjectIDFromHex(breakdownID)
-	if err != nil {
-		h.HandleError(c, err, http.StatusBadRequest)
-		return
-	}
-
-	// Find the breakdown
-	breakdown := &models.Breakdown{}
-	err = h.Repo.FindOne(c.Request.Context(), bson.M{
-		"_id": objID,
-	}, breakdown)
-	if err != nil {
-		h.HandleError(c, err, http.StatusNotFound)
-		return
-	}
-
-	// Verify that the breakdown belongs to the authenticated user
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
-	if breakdown.UserID != userObjID {
-		h.HandleError(c, errUnauthorized, http.StatusForbidden)
+	breakdown, ok := h.findBreakdown(c, h.Repo)
+	if !ok {
 		return
 	}
 
 	h.Respond(c, http.StatusOK, breakdown)
 }
 
-// CreateBreakdown creates a new breakdown for the authenticated user
+// CreateBreakdown creates a new breakdown in the current workspace, owned by the authenticated user
 func (h *BreakdownHandler) CreateBreakdown(c *gin.Context) {
 	// Get user ID from context
 	userID, err := middleware.GetUserID(c)
@@ -104,6 +101,11 @@ func (h *BreakdownHandler) CreateBreakdown(c *gin.Context) {
 		return
 	}
 
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return
+	}
+
 	// Parse request body
 	var request BreakdownRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -122,10 +124,15 @@ func (h *BreakdownHandler) CreateBreakdown(c *gin.Context) {
 	breakdown := &models.Breakdown{
 		Name:        request.Name,
 		Description: request.Description,
+		Completed:   request.Completed,
+		WorkspaceID: workspaceID,
 		UserID:      userObjID,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
+	if breakdown.Completed {
+		breakdown.CompletedAt = &breakdown.CreatedAt
+	}
 
 	// Save to database
 	err = h.Repo.Create(c.Request.Context(), breakdown)
@@ -134,40 +141,23 @@ func (h *BreakdownHandler) CreateBreakdown(c *gin.Context) {
 		return
 	}
 
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditBreakdownCreated,
+		TargetType: "breakdown",
+		TargetID:   breakdown.ID.Hex(),
+		After:      breakdownSummary(breakdown),
+	})
+	h.recordActivity(c, breakdown, models.ActivityBreakdownCreated, nil)
 	h.Respond(c, http.StatusCreated, breakdown)
 }
 
 // UpdateBreakdown updates an existing breakdown
 func (h *BreakdownHandler) UpdateBreakdown(c *gin.Context) {
-	// Get user ID from context
-	userID, err := middleware.GetUserID(c)
-	if err != nil {
-		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
-		return
-	}
-
-	// Parse breakdown ID from URL
-	breakdownID := c.Param("id")
-	objID, err := primitive.ObjectIDFromHex(breakdownID)
-	if err != nil {
-		h.HandleError(c, err, http.StatusBadRequest)
-		return
-	}
-
-	// Find the breakdown
-	existing := &models.Breakdown{}
-	err = h.Repo.FindOne(c.Request.Context(), bson.M{"_id": objID}, existing)
-	if err != nil {
-		h.HandleError(c, err, http.StatusNotFound)
-		return
-	}
-
-	// Verify that the breakdown belongs to the authenticated user
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
-	if existing.UserID != userObjID {
-		h.HandleError(c, errUnauthorized, http.StatusForbidden)
+	existing, ok := h.findBreakdown(c, h.Repo)
+	if !ok {
 		return
 	}
+	objID := existing.ID
 
 	// Parse request body
 	var request BreakdownRequest
@@ -177,16 +167,22 @@ func (h *BreakdownHandler) UpdateBreakdown(c *gin.Context) {
 	}
 
 	// Update fields
-	update := bson.M{
-		"$set": bson.M{
-			"name":        request.Name,
-			"description": request.Description,
-			"updated_at":  time.Now(),
-		},
+	now := time.Now()
+	set := bson.M{
+		"name":        request.Name,
+		"description": request.Description,
+		"completed":   request.Completed,
+		"updated_at":  now,
+	}
+	update := bson.M{"$set": set}
+	if request.Completed && !existing.Completed {
+		set["completed_at"] = now
+	} else if !request.Completed {
+		update["$unset"] = bson.M{"completed_at": ""}
 	}
 
 	// Save to database
-	err = h.Repo.Update(c.Request.Context(), bson.M{"_id": objID}, update)
+	err := h.Repo.Update(c.Request.Context(), bson.M{"_id": objID}, update)
 	if err != nil {
 		h.HandleError(c, err, http.StatusInternalServerError)
 		return
@@ -200,11 +196,58 @@ func (h *BreakdownHandler) UpdateBreakdown(c *gin.Context) {
 		return
 	}
 
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditBreakdownUpdated,
+		TargetType: "breakdown",
+		TargetID:   objID.Hex(),
+		Before:     breakdownSummary(existing),
+		After:      breakdownSummary(updated),
+	})
+	if updated.Name != existing.Name {
+		h.recordActivity(c, updated, models.ActivityBreakdownRenamed, map[string]interface{}{"from": existing.Name, "to": updated.Name})
+	}
+	if updated.Description != existing.Description {
+		h.recordActivity(c, updated, models.ActivityBreakdownEdited, nil)
+	}
+	if updated.Completed && !existing.Completed {
+		h.recordActivity(c, updated, models.ActivityBreakdownCompleted, nil)
+	} else if !updated.Completed && existing.Completed {
+		h.recordActivity(c, updated, models.ActivityBreakdownReopened, nil)
+	}
 	h.Respond(c, http.StatusOK, updated)
 }
 
 // DeleteBreakdown deletes a breakdown
 func (h *BreakdownHandler) DeleteBreakdown(c *gin.Context) {
+	existing, ok := h.findBreakdown(c, h.Repo)
+	if !ok {
+		return
+	}
+	objID := existing.ID
+
+	// Delete from database
+	err := h.Repo.Delete(c.Request.Context(), bson.M{"_id": objID})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.CommentRepo.DeleteForBreakdowns(c.Request.Context(), []primitive.ObjectID{objID}); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditBreakdownDeleted,
+		TargetType: "breakdown",
+		TargetID:   objID.Hex(),
+		Before:     breakdownSummary(existing),
+	})
+	h.recordActivity(c, existing, models.ActivityBreakdownDeleted, nil)
+	h.Respond(c, http.StatusOK, gin.H{"message": "Breakdown deleted successfully"})
+}
+
+// DuplicateBreakdown creates a copy of an existing breakdown in the same workspace, owned by the authenticated user
+func (h *BreakdownHandler) DuplicateBreakdown(c *gin.Context) {
 	// Get user ID from context
 	userID, err := middleware.GetUserID(c)
 	if err != nil {
@@ -212,35 +255,91 @@ func (h *BreakdownHandler) DeleteBreakdown(c *gin.Context) {
 		return
 	}
 
-	// Parse breakdown ID from URL
-	breakdownID := c.Param("id")
-	objID, err := primitive.ObjectIDFromHex(breakdownID)
-	if err != nil {
+	// Parse request body (optional)
+	var request DuplicateRequest
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
 		h.HandleError(c, err, http.StatusBadRequest)
 		return
 	}
 
-	// Find the breakdown
-	existing := &models.Breakdown{}
-	err = h.Repo.FindOne(c.Request.Context(), bson.M{"_id": objID}, existing)
-	if err != nil {
-		h.HandleError(c, err, http.StatusNotFound)
+	existing, ok := h.findBreakdown(c, h.Repo)
+	if !ok {
 		return
 	}
 
-	// Verify that the breakdown belongs to the authenticated user
-	userObjID, _ := primitive.ObjectIDFromHex(userID)
-	if existing.UserID != userObjID {
-		h.HandleError(c, errUnauthorized, http.StatusForbidden)
-		return
+	suffix := defaultDuplicateSuffix
+	if request.Suffix != nil {
+		suffix = *request.Suffix
 	}
 
-	// Delete from database
-	err = h.Repo.Delete(c.Request.Context(), bson.M{"_id": objID})
+	// Copy the breakdown under a new ID
+	now := time.Now()
+	duplicate := *existing
+	duplicate.ID = primitive.NewObjectID()
+	duplicate.UserID, _ = primitive.ObjectIDFromHex(userID)
+	duplicate.Name = existing.Name + suffix
+	duplicate.CreatedAt = now
+	duplicate.UpdatedAt = now
+	if existing.CompletedAt != nil {
+		completedAt := *existing.CompletedAt
+		duplicate.CompletedAt = &completedAt
+	}
+	if request.ResetCompletion {
+		duplicate.Completed = false
+		duplicate.CompletedAt = nil
+	}
+
+	// Save to database
+	err = h.Repo.Create(c.Request.Context(), &duplicate)
 	if err != nil {
 		h.HandleError(c, err, http.StatusInternalServerError)
 		return
 	}
 
-	h.Respond(c, http.StatusOK, gin.H{"message": "Breakdown deleted successfully"})
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditBreakdownDuplicated,
+		TargetType: "breakdown",
+		TargetID:   duplicate.ID.Hex(),
+		After:      breakdownSummary(&duplicate),
+		Metadata:   map[string]interface{}{"source_id": existing.ID.Hex()},
+	})
+	h.recordActivity(c, &duplicate, models.ActivityBreakdownDuplicated, map[string]interface{}{"source_id": existing.ID, "source_name": existing.Name})
+	h.Respond(c, http.StatusCreated, duplicate)
+}
+
+// recordActivity adds an entry to the breakdown's activity feed. The breakdown's name is always
+// included so the entry can be described after later renames or deletion. Failures are logged
+// rather than failing the request, since the change has already been made.
+func (h *BreakdownHandler) recordActivity(c *gin.Context, breakdown *models.Breakdown, activityType string, data map[string]interface{}) {
+	actorID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["name"] = breakdown.Name
+
+	err := h.ActivityRepo.Record(context.WithoutCancel(c.Request.Context()), &models.Activity{
+		ActorID:     actorID,
+		WorkspaceID: breakdown.WorkspaceID,
+		BreakdownID: breakdown.ID,
+		Type:        activityType,
+		Data:        data,
+	})
+	if err != nil {
+		logging.FromContext(c.Request.Context()).Error("Failed to record activity", "type", activityType, "breakdown_id", breakdown.ID.Hex(), "error", err)
+	}
+}
+
+// breakdownSummary captures the fields of a breakdown recorded in the audit log
+func breakdownSummary(breakdown *models.Breakdown) map[string]interface{} {
+	return map[string]interface{}{
+		"name":         breakdown.Name,
+		"description":  breakdown.Description,
+		"completed":    breakdown.Completed,
+		"user_id":      breakdown.UserID.Hex(),
+		"workspace_id": breakdown.WorkspaceID.Hex(),
+	}
 }
@@ -0,0 +1,416 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"server/db/models"
+	"server/db/repository"
+	"server/logging"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// maxCommentLength bounds the Markdown body of a comment
+	maxCommentLength = 10000
+	// maxMentions bounds how many people one comment can mention, and so notify
+	maxMentions = 20
+)
+
+// mentionPattern matches @username mentions that aren't part of an email address or another word
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@([A-Za-z0-9_.-]*[A-Za-z0-9_])`)
+
+var (
+	errEmptyComment    = errors.New("comment cannot be empty")
+	errCommentTooLong  = errors.New("comment is too long")
+	errTooManyMentions = fmt.Errorf("a comment can mention at most %d people", maxMentions)
+	errCommentNotFound = errors.New("comment not found")
+	errNotCommentOwner = errors.New("only the author can change a comment")
+)
+
+// CommentRequest represents the data needed to post a comment
+type CommentRequest struct {
+	Body     string `json:"body" binding:"required"`
+	ParentID string `json:"parent_id"`
+}
+
+// UpdateCommentRequest represents an edit to a comment
+type UpdateCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+type CommentHandler struct {
+	BaseHandler
+	Repo             *repository.CommentRepository
+	BreakdownRepo    *repository.BreakdownRepository
+	UserRepo         *repository.UserRepository
+	WorkspaceRepo    *repository.WorkspaceRepository
+	NotificationRepo *repository.NotificationRepository
+}
+
+func NewCommentHandler(repo *repository.CommentRepository, breakdownRepo *repository.BreakdownRepository, userRepo *repository.UserRepository, workspaceRepo *repository.WorkspaceRepository, notificationRepo *repository.NotificationRepository) *CommentHandler {
+	return &CommentHandler{
+		Repo:             repo,
+		BreakdownRepo:    breakdownRepo,
+		UserRepo:         userRepo,
+		WorkspaceRepo:    workspaceRepo,
+		NotificationRepo: notificationRepo,
+	}
+}
+
+// GetComments retrieves the discussion on a breakdown as a tree of threads
+func (h *CommentHandler) GetComments(c *gin.Context) {
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return
+	}
+
+	comments, err := h.Repo.FindBreakdownComments(c.Request.Context(), breakdown.ID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	authors, err := h.authorNames(c.Request.Context(), comments...)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Comments are sorted oldest first, so parents are always seen before their replies
+	views := make(map[primitive.ObjectID]gin.H, len(comments))
+	threads := []gin.H{}
+	for i := range comments {
+		comment := &comments[i]
+		view := commentView(comment, authors)
+		views[comment.ID] = view
+
+		if comment.ParentID != nil {
+			if parent, ok := views[*comment.ParentID]; ok {
+				parent["replies"] = append(parent["replies"].([]gin.H), view)
+				continue
+			}
+		}
+		threads = append(threads, view)
+	}
+
+	h.Respond(c, http.StatusOK, threads)
+}
+
+// CreateComment posts a comment, or a reply when parent_id is given, and notifies mentioned members
+func (h *CommentHandler) CreateComment(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return
+	}
+
+	var request CommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+	body, err := validateCommentBody(request.Body)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	comment := &models.Comment{
+		ID:          primitive.NewObjectID(),
+		BreakdownID: breakdown.ID,
+		AuthorID:    userID,
+		Body:        body,
+		CreatedAt:   time.Now(),
+	}
+
+	// Replies must answer a comment on the same breakdown
+	if request.ParentID != "" {
+		parentID, err := primitive.ObjectIDFromHex(request.ParentID)
+		if err != nil {
+			h.HandleError(c, err, http.StatusBadRequest)
+			return
+		}
+		parent := &models.Comment{}
+		if err := h.Repo.FindOne(c.Request.Context(), bson.M{"_id": parentID, "breakdown_id": breakdown.ID}, parent); err != nil {
+			h.HandleError(c, errCommentNotFound, http.StatusNotFound)
+			return
+		}
+		comment.ParentID = &parentID
+	}
+
+	comment.Mentions, err = h.resolveMentions(c.Request.Context(), breakdown.WorkspaceID, body)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.Repo.Create(c.Request.Context(), comment); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.notifyMentioned(c.Request.Context(), comment, comment.Mentions)
+
+	h.Respond(c, http.StatusCreated, h.savedCommentView(c.Request.Context(), comment))
+}
+
+// UpdateComment edits the body of one of the authenticated user's comments.
+// Only users newly mentioned by the edit are notified.
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return
+	}
+	comment, ok := h.findOwnComment(c, breakdown.ID)
+	if !ok {
+		return
+	}
+
+	var request UpdateCommentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+	body, err := validateCommentBody(request.Body)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	mentions, err := h.resolveMentions(c.Request.Context(), breakdown.WorkspaceID, body)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	err = h.Repo.Update(c.Request.Context(), bson.M{"_id": comment.ID}, bson.M{
+		"$set": bson.M{"body": body, "mentions": mentions, "edited_at": now},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	previous := make(map[primitive.ObjectID]bool, len(comment.Mentions))
+	for _, id := range comment.Mentions {
+		previous[id] = true
+	}
+	added := []primitive.ObjectID{}
+	for _, id := range mentions {
+		if !previous[id] {
+			added = append(added, id)
+		}
+	}
+
+	comment.Body = body
+	comment.Mentions = mentions
+	comment.EditedAt = &now
+	h.notifyMentioned(c.Request.Context(), comment, added)
+
+	h.Respond(c, http.StatusOK, h.savedCommentView(c.Request.Context(), comment))
+}
+
+// DeleteComment deletes one of the authenticated user's comments. Comments with replies are
+// blanked rather than removed so the thread stays intact.
+func (h *CommentHandler) DeleteComment(c *gin.Context) {
+	breakdown, ok := h.findBreakdown(c, h.BreakdownRepo)
+	if !ok {
+		return
+	}
+	comment, ok := h.findOwnComment(c, breakdown.ID)
+	if !ok {
+		return
+	}
+
+	hasReplies, err := h.Repo.HasReplies(c.Request.Context(), comment.ID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	if hasReplies {
+		err = h.Repo.Update(c.Request.Context(), bson.M{"_id": comment.ID}, bson.M{
+			"$set":   bson.M{"deleted": true, "body": ""},
+			"$unset": bson.M{"mentions": ""},
+		})
+	} else {
+		err = h.Repo.Delete(c.Request.Context(), bson.M{"_id": comment.ID})
+	}
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Respond(c, http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// findOwnComment loads the comment from the URL, which must be on the breakdown and written by the authenticated user
+func (h *CommentHandler) findOwnComment(c *gin.Context, breakdownID primitive.ObjectID) (*models.Comment, bool) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return nil, false
+	}
+
+	commentID, err := primitive.ObjectIDFromHex(c.Param("comment_id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	comment := &models.Comment{}
+	err = h.Repo.FindOne(c.Request.Context(), bson.M{"_id": commentID, "breakdown_id": breakdownID, "deleted": bson.M{"$ne": true}}, comment)
+	if err != nil {
+		h.HandleError(c, errCommentNotFound, http.StatusNotFound)
+		return nil, false
+	}
+	if comment.AuthorID != userID {
+		h.HandleError(c, errNotCommentOwner, http.StatusForbidden)
+		return nil, false
+	}
+	return comment, true
+}
+
+// resolveMentions finds the members of the workspace mentioned in a comment body.
+// Unknown usernames and people outside the workspace are ignored.
+func (h *CommentHandler) resolveMentions(ctx context.Context, workspaceID primitive.ObjectID, body string) ([]primitive.ObjectID, error) {
+	usernames := mentionedUsernames(body)
+	users, err := h.UserRepo.FindUsersByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]primitive.ObjectID, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.ID)
+	}
+	memberships, err := h.WorkspaceRepo.FindMemberships(ctx, workspaceID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Mentions keep the order they appear in the body
+	mentions := []primitive.ObjectID{}
+	for _, username := range usernames {
+		user, ok := users[username]
+		if !ok {
+			continue
+		}
+		if _, ok := memberships[user.ID]; ok {
+			mentions = append(mentions, user.ID)
+		}
+	}
+	return mentions, nil
+}
+
+// mentionedUsernames lists the distinct usernames mentioned in a comment body, in order
+func mentionedUsernames(body string) []string {
+	seen := map[string]bool{}
+	usernames := []string{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			usernames = append(usernames, match[1])
+		}
+	}
+	return usernames
+}
+
+// notifyMentioned tells mentioned users about a comment. Authors aren't notified of their own mentions.
+// Failures are logged, since the comment has already been saved.
+func (h *CommentHandler) notifyMentioned(ctx context.Context, comment *models.Comment, mentioned []primitive.ObjectID) {
+	notifications := []models.Notification{}
+	for _, userID := range mentioned {
+		if userID == comment.AuthorID {
+			continue
+		}
+		commentID := comment.ID
+		notifications = append(notifications, models.Notification{
+			UserID:      userID,
+			Type:        models.NotificationMentioned,
+			ActorID:     comment.AuthorID,
+			BreakdownID: comment.BreakdownID,
+			CommentID:   &commentID,
+			CreatedAt:   time.Now(),
+		})
+	}
+
+	if err := h.NotificationRepo.Notify(context.WithoutCancel(ctx), notifications); err != nil {
+		logging.FromContext(ctx).Error("Failed to notify mentioned users", "comment_id", comment.ID.Hex(), "error", err)
+	}
+}
+
+// authorNames looks up the usernames of the comments' authors in one query, keyed by user ID
+func (h *CommentHandler) authorNames(ctx context.Context, comments ...models.Comment) (map[primitive.ObjectID]string, error) {
+	ids := make([]primitive.ObjectID, 0, len(comments))
+	seen := make(map[primitive.ObjectID]bool, len(comments))
+	for _, comment := range comments {
+		if !seen[comment.AuthorID] {
+			seen[comment.AuthorID] = true
+			ids = append(ids, comment.AuthorID)
+		}
+	}
+
+	users, err := h.UserRepo.FindUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[primitive.ObjectID]string, len(users))
+	for id, user := range users {
+		names[id] = user.Username
+	}
+	return names, nil
+}
+
+// savedCommentView is the JSON representation of a comment that has just been saved. Failing to look up
+// the author only leaves out their name, since the change has already been made.
+func (h *CommentHandler) savedCommentView(ctx context.Context, comment *models.Comment) gin.H {
+	authors, err := h.authorNames(ctx, *comment)
+	if err != nil {
+		logging.FromContext(ctx).Error("Failed to look up comment author", "comment_id", comment.ID.Hex(), "error", err)
+	}
+	return commentView(comment, authors)
+}
+
+// commentView is the JSON representation of a comment, with its author's name when known
+func commentView(comment *models.Comment, authors map[primitive.ObjectID]string) gin.H {
+	view := gin.H{
+		"id":         comment.ID,
+		"parent_id":  comment.ParentID,
+		"author_id":  comment.AuthorID,
+		"body":       comment.Body,
+		"deleted":    comment.Deleted,
+		"edited_at":  comment.EditedAt,
+		"created_at": comment.CreatedAt,
+		"replies":    []gin.H{},
+	}
+	if author, ok := authors[comment.AuthorID]; ok {
+		view["author"] = author
+	}
+	return view
+}
+
+// validateCommentBody trims a comment body and checks its length
+func validateCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", errEmptyComment
+	}
+	if len(body) > maxCommentLength {
+		return "", errCommentTooLong
+	}
+	if len(mentionedUsernames(body)) > maxMentions {
+		return "", errTooManyMentions
+	}
+	return body, nil
+}
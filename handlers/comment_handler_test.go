@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMentionedUsernames(t *testing.T) {
+	got := mentionedUsernames("@ada thanks, cc @grace.h and @ada again; mail ada@example.com")
+	if strings.Join(got, " ") != "ada grace.h" {
+		t.Errorf("mentionedUsernames = %q, want [ada grace.h]", got)
+	}
+}
+
+func TestValidateCommentBodyCapsMentions(t *testing.T) {
+	var body strings.Builder
+	for i := 0; i < maxMentions; i++ {
+		body.WriteString("@user" + strings.Repeat("x", i) + " ")
+	}
+	if _, err := validateCommentBody(body.String()); err != nil {
+		t.Errorf("comment with %d mentions = %v", maxMentions, err)
+	}
+
+	body.WriteString("@onemore")
+	if _, err := validateCommentBody(body.String()); !errors.Is(err, errTooManyMentions) {
+		t.Errorf("comment with %d mentions = %v, want errTooManyMentions", maxMentions+1, err)
+	}
+}
@@ -2,14 +2,51 @@ package handlers
 
 import (
 	"net/http"
+	"server/health"
 
 	"github.com/gin-gonic/gin"
 )
 
-// HealthCheckHandler handles the health check endpoint
-func HealthCheckHandler(c *gin.Context) {
+type HealthHandler struct {
+	Registry *health.Registry
+}
+
+func NewHealthHandler(registry *health.Registry) *HealthHandler {
+	return &HealthHandler{
+		Registry: registry,
+	}
+}
+
+// Health is the original health check, kept for existing monitors. Like Live it doesn't check
+// dependencies, and it keeps its original response body.
+func (h *HealthHandler) Health(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "Server is running",
 	})
 }
+
+// Live reports that the process is up and serving requests. It doesn't check dependencies,
+// so a database outage doesn't get the process restarted.
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": health.StatusOK,
+		"build":  health.Build(),
+	})
+}
+
+// Ready reports whether the service can handle traffic. It fails when a critical dependency is
+// down or graceful shutdown has begun; a degraded service is still ready.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	report := h.Registry.Run(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == health.StatusFailing {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{
+		"status": report.Status,
+		"checks": report.Checks,
+		"build":  health.Build(),
+	})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"server/health"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := health.NewRegistry()
+	registry.Register(health.Check{Name: "mongo", Critical: true, Check: func(ctx context.Context) error {
+		return errors.New("unreachable")
+	}})
+	h := NewHealthHandler(registry)
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		code    int
+		status  string
+	}{
+		// The database is down: only readiness fails
+		{"health", h.Health, http.StatusOK, "success"},
+		{"live", h.Live, http.StatusOK, string(health.StatusOK)},
+		{"ready", h.Ready, http.StatusServiceUnavailable, string(health.StatusFailing)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/health", nil)
+			tt.handler(c)
+
+			var body struct {
+				Status string `json:"status"`
+			}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if recorder.Code != tt.code || body.Status != tt.status {
+				t.Errorf("%s = %d %s, want %d with status %q", tt.name, recorder.Code, recorder.Body.String(), tt.code, tt.status)
+			}
+		})
+	}
+}
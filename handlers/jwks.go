@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+	"server/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler serves the public keys other services use to verify our tokens
+func JWKSHandler(keys *utils.KeyManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// With HS256 signing there are no public keys to publish
+		jwks := []utils.JSONWebKey{}
+		if keys != nil {
+			jwks = keys.JWKS()
+		}
+
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{
+			"keys": jwks,
+		})
+	}
+}
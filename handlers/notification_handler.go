@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"server/db/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationHandler struct {
+	BaseHandler
+	Repo *repository.NotificationRepository
+}
+
+func NewNotificationHandler(repo *repository.NotificationRepository) *NotificationHandler {
+	return &NotificationHandler{
+		Repo: repo,
+	}
+}
+
+// GetNotifications lists the authenticated user's notifications, newest first.
+// Pass unread=true to only see unread notifications.
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := pagination(c)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	notifications, err := h.Repo.FindUserNotifications(c.Request.Context(), userID, c.Query("unread") == "true", limit, offset)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.Respond(c, http.StatusOK, notifications)
+}
+
+// MarkNotificationRead marks one of the authenticated user's notifications as read
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.MarkRead(c.Request.Context(), userID, objID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.Respond(c, http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllNotificationsRead marks all of the authenticated user's notifications as read
+func (h *NotificationHandler) MarkAllNotificationsRead(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.Repo.MarkRead(c.Request.Context(), userID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.Respond(c, http.StatusOK, gin.H{"message": "All notifications marked as read"})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"regexp"
+	"server/db/models"
+	"server/db/repository"
+	"server/metrics"
+	"server/oidc"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateTTL is how long a user has to complete a login at the provider
+const oidcStateTTL = 10 * time.Minute
+
+// oidcStateCookie carries the state to the callback in the browser that started the login, so a
+// callback URL from someone else's login can't sign this browser in
+const oidcStateCookie = "oidc_state"
+
+var (
+	errUnknownProvider  = errors.New("unknown identity provider")
+	errEmailNotVerified = errors.New("identity provider did not return a verified email address")
+	errMissingCode      = errors.New("authorization code is required")
+	errStateMismatch    = errors.New("login was not started in this browser")
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9_.-]`)
+
+// OIDCHandler signs users in with external OpenID Connect providers
+type OIDCHandler struct {
+	*AuthHandler
+	Providers map[string]*oidc.Provider
+	StateRepo *repository.OIDCStateRepository
+}
+
+func NewOIDCHandler(authHandler *AuthHandler, providers map[string]*oidc.Provider, stateRepo *repository.OIDCStateRepository) *OIDCHandler {
+	return &OIDCHandler{
+		AuthHandler: authHandler,
+		Providers:   providers,
+		StateRepo:   stateRepo,
+	}
+}
+
+// GetProviders lists the configured identity providers
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	names := make([]string, 0, len(h.Providers))
+	for name := range h.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h.Respond(c, http.StatusOK, gin.H{"providers": names})
+}
+
+// StartLogin redirects the user to the provider's authorization endpoint
+func (h *OIDCHandler) StartLogin(c *gin.Context) {
+	provider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		h.HandleError(c, errUnknownProvider, http.StatusNotFound)
+		return
+	}
+
+	// Per-login secrets: state, together with the cookie below, protects against CSRF, nonce binds the
+	// ID token, verifier is for PKCE
+	state := &models.OIDCState{Provider: provider.Name, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(oidcStateTTL)}
+	for _, value := range []*string{&state.ID, &state.Nonce, &state.CodeVerifier} {
+		random, err := oidc.RandomString()
+		if err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+		*value = random
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state.ID, state.Nonce, state.CodeVerifier)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadGateway)
+		return
+	}
+
+	if err := h.StateRepo.Create(c.Request.Context(), state); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, state.ID, int(oidcStateTTL.Seconds()), "/auth/oidc", "", secureCookie(provider), true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the login after the provider redirects back with an authorization code
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	provider, ok := h.Providers[c.Param("provider")]
+	if !ok {
+		h.HandleError(c, errUnknownProvider, http.StatusNotFound)
+		return
+	}
+
+	// The provider reports user cancellations and other failures as query parameters
+	if providerErr := c.Query("error"); providerErr != "" {
+		h.HandleError(c, fmt.Errorf("identity provider error: %s %s", providerErr, c.Query("error_description")), http.StatusUnauthorized)
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		h.HandleError(c, errMissingCode, http.StatusBadRequest)
+		return
+	}
+
+	// The state must belong to a login started in this browser
+	cookie, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/auth/oidc", "", secureCookie(provider), true)
+	if cookie == "" || subtle.ConstantTimeCompare([]byte(cookie), []byte(c.Query("state"))) != 1 {
+		h.HandleError(c, errStateMismatch, http.StatusBadRequest)
+		return
+	}
+
+	state, err := h.StateRepo.Consume(c.Request.Context(), c.Query("state"), provider.Name)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	claims, err := provider.Exchange(c.Request.Context(), code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		h.Audit(c, &models.AuditEvent{
+			Action:   models.AuditLoginFailed,
+			Metadata: map[string]interface{}{"method": "oidc:" + provider.Name, "reason": err.Error()},
+		})
+		metrics.LoginAttempt("oidc:"+provider.Name, metrics.LoginFailed)
+		h.HandleError(c, err, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.findOrCreateUser(c, provider.Name, claims)
+	if err != nil {
+		h.Audit(c, &models.AuditEvent{
+			Action:   models.AuditLoginFailed,
+			Metadata: map[string]interface{}{"method": "oidc:" + provider.Name, "email_hash": hashEmail(claims.Email), "reason": err.Error()},
+		})
+		metrics.LoginAttempt("oidc:"+provider.Name, metrics.LoginFailed)
+		if errors.Is(err, errEmailNotVerified) {
+			h.HandleError(c, err, http.StatusForbidden)
+			return
+		}
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.completeLogin(c, user, "oidc:"+provider.Name)
+}
+
+// secureCookie reports whether the state cookie should be limited to HTTPS, which it is whenever the
+// provider redirects back over HTTPS
+func secureCookie(provider *oidc.Provider) bool {
+	return strings.HasPrefix(provider.RedirectURL, "https://")
+}
+
+// findOrCreateUser resolves the user for an external identity, linking it to an existing
+// account with the same verified email or creating a new account
+func (h *OIDCHandler) findOrCreateUser(c *gin.Context, providerName string, claims *oidc.Claims) (*models.User, error) {
+	ctx := c.Request.Context()
+	identity := models.Identity{Provider: providerName, Subject: claims.Subject}
+
+	// Already linked
+	if user, err := h.UserRepo.FindUserByIdentity(ctx, identity.Provider, identity.Subject); err == nil {
+		return user, nil
+	}
+
+	// Linking and sign-up both rely on the provider vouching for the email address
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, errEmailNotVerified
+	}
+
+	if user, err := h.UserRepo.FindUserByEmail(ctx, claims.Email); err == nil {
+		if err := h.UserRepo.LinkIdentity(ctx, user.ID, identity); err != nil {
+			return nil, err
+		}
+		h.Audit(c, &models.AuditEvent{
+			Action:  models.AuditProfileUpdated,
+			ActorID: &user.ID,
+			After:   map[string]interface{}{"linked_identity": identity.Provider},
+		})
+		return user, nil
+	}
+
+	// New account; it has no usable password until the user sets one
+	password, err := oidc.RandomString()
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Email:      claims.Email,
+		Password:   password,
+		Identities: []models.Identity{identity},
+	}
+
+	base := usernameFromEmail(claims.Email)
+	user.Username = base
+	for attempt := 0; attempt < 5; attempt++ {
+		err = h.UserRepo.CreateUser(ctx, user)
+		if err == nil {
+			h.Audit(c, &models.AuditEvent{
+				Action:   models.AuditRegistered,
+				ActorID:  &user.ID,
+				After:    map[string]interface{}{"username": user.Username, "email": user.Email},
+				Metadata: map[string]interface{}{"method": "oidc:" + providerName},
+			})
+			return user, nil
+		}
+
+		// Username taken; try again with a random suffix
+		suffix, randErr := rand.Int(rand.Reader, big.NewInt(10000))
+		if randErr != nil {
+			return nil, randErr
+		}
+		user.Username = fmt.Sprintf("%s%04d", base, suffix.Int64())
+	}
+	return nil, err
+}
+
+// usernameFromEmail derives a username from the local part of an email address
+func usernameFromEmail(email string) string {
+	local := strings.ToLower(strings.SplitN(email, "@", 2)[0])
+	username := usernameInvalidChars.ReplaceAllString(local, "")
+	if username == "" {
+		username = "user"
+	}
+	return username
+}
@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db/models"
+	"server/mail"
+	"server/middleware"
+	"server/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// PasswordResetTTL is how long a password reset link stays valid
+	PasswordResetTTL = 24 * time.Hour
+	// emailVerificationTTL is how long an email change link stays valid
+	emailVerificationTTL = 24 * time.Hour
+	// AccountDeletionGracePeriod is how long a deleted account can still be restored
+	AccountDeletionGracePeriod = 7 * 24 * time.Hour
+)
+
+var (
+	errUsernameTaken        = errors.New("username already taken")
+	errEmailTaken           = errors.New("user with this email already exists")
+	errEmptyUsername        = errors.New("username cannot be empty")
+	errInvalidTimezone      = errors.New("invalid timezone")
+	errInvalidVerification  = errors.New("invalid or expired verification token")
+	errDeletionNotScheduled = errors.New("account deletion is not scheduled")
+	errInvalidPasswordReset = errors.New("invalid or expired password reset token")
+)
+
+// UpdateProfileRequest represents the profile fields a user can change; omitted fields are left as they are
+type UpdateProfileRequest struct {
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+	Timezone    *string `json:"timezone"`
+}
+
+// ChangePasswordRequest represents the data needed to change a password. The current password may be
+// left out just after signing in with an identity provider, so accounts created that way can set one.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangeEmailRequest represents the data needed to start an email change; the password may be left
+// out just after signing in with an identity provider
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password"`
+}
+
+// VerifyEmailRequest represents the token from an email verification link
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResetPasswordRequest represents the token from a password reset link and the new password
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// DeleteAccountRequest represents the re-authentication needed to delete an account; the password may
+// be left out just after signing in with an identity provider
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// UpdateProfile updates the authenticated user's username, display name and timezone
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	var request UpdateProfileRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+
+	set := bson.M{"updated_at": time.Now()}
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+	if request.Username != nil {
+		username := strings.TrimSpace(*request.Username)
+		if username == "" {
+			h.HandleError(c, errEmptyUsername, http.StatusBadRequest)
+			return
+		}
+		if username != user.Username {
+			if _, err := h.UserRepo.FindUserByUsername(c.Request.Context(), username); err == nil {
+				h.HandleError(c, errUsernameTaken, http.StatusConflict)
+				return
+			}
+		}
+		set["username"] = username
+		before["username"], after["username"] = user.Username, username
+	}
+	if request.DisplayName != nil {
+		set["display_name"] = strings.TrimSpace(*request.DisplayName)
+		before["display_name"], after["display_name"] = user.DisplayName, set["display_name"]
+	}
+	if request.Timezone != nil {
+		if _, err := time.LoadLocation(*request.Timezone); err != nil || *request.Timezone == "" {
+			h.HandleError(c, errInvalidTimezone, http.StatusBadRequest)
+			return
+		}
+		set["timezone"] = *request.Timezone
+		before["timezone"], after["timezone"] = user.Timezone, *request.Timezone
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{"$set": set})
+	if mongo.IsDuplicateKeyError(err) {
+		h.HandleError(c, errUsernameTaken, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.Audit(c, &models.AuditEvent{Action: models.AuditProfileUpdated, Before: before, After: after})
+
+	h.GetProfile(c)
+}
+
+// ChangePassword sets a new password after re-authenticating the user, and signs out all other sessions
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var request ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+
+	if !h.reauthenticate(c, user, request.CurrentPassword) {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"password":   string(hashedPassword),
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Sign out everywhere except the session making this request
+	currentSession, _ := primitive.ObjectIDFromHex(middleware.GetSessionID(c))
+	if err := h.SessionRepo.RevokeOtherSessions(c.Request.Context(), user.ID, currentSession); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{Action: models.AuditPasswordChanged})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// ChangeEmail starts an email change by sending a verification link to the new address
+func (h *AuthHandler) ChangeEmail(c *gin.Context) {
+	var request ChangeEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+
+	if !h.reauthenticate(c, user, request.Password) {
+		return
+	}
+	if _, err := h.UserRepo.FindUserByEmail(c.Request.Context(), request.Email); err == nil {
+		h.HandleError(c, errEmailTaken, http.StatusConflict)
+		return
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"pending_email":                 request.Email,
+			"email_verification_hash":       utils.HashToken(token),
+			"email_verification_expires_at": time.Now().Add(emailVerificationTTL),
+			"updated_at":                    time.Now(),
+		},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = h.Mailer.Send(c.Request.Context(), mail.Message{
+		To:      request.Email,
+		Subject: "Confirm your new flow email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm this address for your flow account by opening the link below within 24 hours:\n\n%s/verify-email?token=%s\n\nIf you didn't request this change you can ignore this email.\n",
+			user.Username, appURL(), token),
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:   models.AuditEmailChangeRequested,
+		Metadata: map[string]interface{}{"new_email": request.Email},
+	})
+	h.Respond(c, http.StatusAccepted, gin.H{"message": "Verification email sent"})
+}
+
+// VerifyEmail completes an email change using the token from the verification link
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var request VerifyEmailRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.UserRepo.FindUserByEmailVerification(c.Request.Context(), utils.HashToken(request.Token))
+	if err != nil || user.EmailVerificationExpiresAt == nil || user.EmailVerificationExpiresAt.Before(time.Now()) {
+		h.HandleError(c, errInvalidVerification, http.StatusBadRequest)
+		return
+	}
+
+	// The address may have been registered since the link was sent
+	if _, err := h.UserRepo.FindUserByEmail(c.Request.Context(), user.PendingEmail); err == nil {
+		h.HandleError(c, errEmailTaken, http.StatusConflict)
+		return
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"email": user.PendingEmail, "updated_at": time.Now()},
+		"$unset": bson.M{
+			"pending_email":                 "",
+			"email_verification_hash":       "",
+			"email_verification_expires_at": "",
+		},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		h.HandleError(c, errEmailTaken, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:  models.AuditEmailChanged,
+		ActorID: &user.ID,
+		Before:  map[string]interface{}{"email": user.Email},
+		After:   map[string]interface{}{"email": user.PendingEmail},
+	})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Email address updated", "email": user.PendingEmail})
+}
+
+// ResetPassword sets a new password using the token from a password reset link
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var request ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.UserRepo.FindUserByPasswordReset(c.Request.Context(), utils.HashToken(request.Token))
+	if err != nil || user.PasswordResetExpiresAt == nil || user.PasswordResetExpiresAt.Before(time.Now()) {
+		h.HandleError(c, errInvalidPasswordReset, http.StatusBadRequest)
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"password": string(hashedPassword), "failed_logins": 0, "updated_at": time.Now()},
+		"$unset": bson.M{
+			"password_reset_hash":       "",
+			"password_reset_expires_at": "",
+			"locked_until":              "",
+		},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Sign out everywhere in case the old password was compromised
+	if err := h.SessionRepo.RevokeOtherSessions(c.Request.Context(), user.ID, primitive.NilObjectID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{Action: models.AuditPasswordReset, ActorID: &user.ID})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// DeleteAccount schedules the authenticated user's account and breakdowns for deletion after a grace period
+func (h *AuthHandler) DeleteAccount(c *gin.Context) {
+	var request DeleteAccountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+
+	if !h.reauthenticate(c, user, request.Password) {
+		return
+	}
+
+	deleteAt := time.Now().Add(AccountDeletionGracePeriod)
+	if user.DeletionScheduledAt != nil {
+		deleteAt = *user.DeletionScheduledAt
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"deletion_scheduled_at": deleteAt, "updated_at": time.Now()},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:   models.AuditAccountDeletionRequested,
+		Metadata: map[string]interface{}{"delete_at": deleteAt},
+	})
+	h.Respond(c, http.StatusAccepted, gin.H{
+		"message":   "Account scheduled for deletion",
+		"delete_at": deleteAt,
+	})
+}
+
+// CancelAccountDeletion cancels a scheduled account deletion during the grace period
+func (h *AuthHandler) CancelAccountDeletion(c *gin.Context) {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+	if user.DeletionScheduledAt == nil {
+		h.HandleError(c, errDeletionNotScheduled, http.StatusBadRequest)
+		return
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set":   bson.M{"updated_at": time.Now()},
+		"$unset": bson.M{"deletion_scheduled_at": ""},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{Action: models.AuditAccountDeletionCancelled})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// baseURL is the base URL of the web app, set at startup with SetAppURL
+var baseURL = "http://localhost:8080"
+
+// appURL returns the base URL of the web app used in links sent by email
+func appURL() string {
+	return strings.TrimSuffix(baseURL, "/")
+}
+
+// SetAppURL sets the base URL of the app used in links sent by email
+func SetAppURL(url string) {
+	baseURL = url
+}
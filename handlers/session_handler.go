@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"server/db/models"
+	"server/db/repository"
+	"server/middleware"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SessionHandler struct {
+	BaseHandler
+	Repo *repository.SessionRepository
+}
+
+func NewSessionHandler(repo *repository.SessionRepository, auditRepo *repository.AuditRepository) *SessionHandler {
+	return &SessionHandler{
+		BaseHandler: BaseHandler{AuditRepo: auditRepo},
+		Repo:        repo,
+	}
+}
+
+// GetSessions lists the devices the authenticated user is signed in on
+func (h *SessionHandler) GetSessions(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := h.Repo.FindActiveSessions(c.Request.Context(), userObjID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Flag the session making this request
+	currentSession := middleware.GetSessionID(c)
+	response := make([]gin.H, len(sessions))
+	for i, session := range sessions {
+		response[i] = gin.H{
+			"id":           session.ID.Hex(),
+			"user_agent":   session.UserAgent,
+			"ip":           session.IP,
+			"created_at":   session.CreatedAt,
+			"last_seen_at": session.LastSeenAt,
+			"expires_at":   session.ExpiresAt,
+			"current":      session.ID.Hex() == currentSession,
+		}
+	}
+
+	h.Respond(c, http.StatusOK, response)
+}
+
+// RevokeSession signs out one of the authenticated user's sessions
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	// Parse session ID from URL
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	userObjID, _ := primitive.ObjectIDFromHex(userID)
+	if err := h.Repo.RevokeSession(c.Request.Context(), userObjID, objID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{Action: models.AuditSessionRevoked, TargetType: "session", TargetID: objID.Hex()})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Session signed out successfully"})
+}
@@ -0,0 +1,395 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"server/db/models"
+	"server/db/repository"
+	"server/logging"
+	"server/middleware"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	errTransferToSelf      = errors.New("cannot transfer a breakdown to yourself")
+	errTransferPending     = errors.New("breakdown already has a pending transfer")
+	errTransferNotPending  = errors.New("transfer is no longer pending")
+	errRecipientNotFound   = errors.New("recipient not found")
+	errTransferOwnerChange = errors.New("breakdown owner has changed since the transfer was requested")
+	errRecipientNotMember  = errors.New("breakdowns in a shared workspace can only be transferred to its members")
+)
+
+// TransferRequest represents the data needed to transfer a breakdown
+type TransferRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type TransferHandler struct {
+	BaseHandler
+	BreakdownRepo *repository.BreakdownRepository
+	UserRepo      *repository.UserRepository
+	Repo          *repository.TransferRepository
+	ActivityRepo  *repository.ActivityRepository
+	WorkspaceRepo *repository.WorkspaceRepository
+}
+
+func NewTransferHandler(repo *repository.TransferRepository, breakdownRepo *repository.BreakdownRepository, userRepo *repository.UserRepository, activityRepo *repository.ActivityRepository, workspaceRepo *repository.WorkspaceRepository, auditRepo *repository.AuditRepository) *TransferHandler {
+	return &TransferHandler{
+		BaseHandler:   BaseHandler{AuditRepo: auditRepo},
+		BreakdownRepo: breakdownRepo,
+		UserRepo:      userRepo,
+		Repo:          repo,
+		ActivityRepo:  activityRepo,
+		WorkspaceRepo: workspaceRepo,
+	}
+}
+
+// CreateTransfer offers a breakdown to another user. Breakdowns in a personal workspace move to the
+// recipient's personal workspace on acceptance; breakdowns in a shared workspace stay there and can
+// only be offered to its members. Breakdowns can be offered by their owner or by a workspace admin.
+func (h *TransferHandler) CreateTransfer(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	// Parse breakdown ID from URL
+	breakdownID := c.Param("id")
+	objID, err := primitive.ObjectIDFromHex(breakdownID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var request TransferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return
+	}
+
+	// Find the breakdown
+	breakdown := &models.Breakdown{}
+	err = h.BreakdownRepo.FindOne(c.Request.Context(), bson.M{"_id": objID, "workspace_id": workspaceID}, breakdown)
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return
+	}
+
+	// Verify that the breakdown belongs to the authenticated user, or that they administer the workspace
+	userObjID, _ := primitive.ObjectIDFromHex(userID)
+	if breakdown.UserID != userObjID && !models.WorkspaceRoleAtLeast(middleware.GetWorkspaceRole(c), models.WorkspaceAdmin) {
+		h.HandleError(c, errUnauthorized, http.StatusForbidden)
+		return
+	}
+
+	// Find the recipient
+	recipient, err := h.UserRepo.FindUserByEmail(c.Request.Context(), request.Email)
+	if err != nil {
+		h.HandleError(c, errRecipientNotFound, http.StatusNotFound)
+		return
+	}
+	if recipient.ID == breakdown.UserID {
+		h.HandleError(c, errTransferToSelf, http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.sharedWorkspace(c, breakdown, recipient.ID); !ok {
+		return
+	}
+
+	// Only one pending transfer per breakdown
+	pending := &models.Transfer{}
+	err = h.Repo.FindOne(c.Request.Context(), bson.M{
+		"breakdown_id": objID,
+		"status":       models.TransferPending,
+	}, pending)
+	if err == nil {
+		h.HandleError(c, errTransferPending, http.StatusConflict)
+		return
+	}
+
+	transfer := &models.Transfer{
+		ID:          primitive.NewObjectID(),
+		BreakdownID: objID,
+		FromUserID:  breakdown.UserID,
+		ToUserID:    recipient.ID,
+		Status:      models.TransferPending,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	// Save to database
+	err = h.Repo.Create(c.Request.Context(), transfer)
+	if mongo.IsDuplicateKeyError(err) {
+		h.HandleError(c, errTransferPending, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditTransferRequested,
+		TargetType: "breakdown",
+		TargetID:   objID.Hex(),
+		Metadata:   map[string]interface{}{"transfer_id": transfer.ID.Hex(), "to_user_id": recipient.ID.Hex()},
+	})
+	h.Respond(c, http.StatusCreated, transfer)
+}
+
+// GetTransfers retrieves the pending transfers offered to the authenticated user
+func (h *TransferHandler) GetTransfers(c *gin.Context) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	userObjID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	var transfers []models.Transfer
+	err = h.Repo.Find(c.Request.Context(), bson.M{
+		"to_user_id": userObjID,
+		"status":     models.TransferPending,
+	}, &transfers)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Respond(c, http.StatusOK, transfers)
+}
+
+// AcceptTransfer accepts a pending transfer, handing the breakdown to the authenticated user. Breakdowns
+// from a personal workspace move to the user's personal workspace.
+func (h *TransferHandler) AcceptTransfer(c *gin.Context) {
+	transfer, ok := h.findPendingTransfer(c)
+	if !ok {
+		return
+	}
+
+	// The breakdown must still belong to the user who offered it
+	breakdown := &models.Breakdown{}
+	err := h.BreakdownRepo.FindOne(c.Request.Context(), bson.M{"_id": transfer.BreakdownID}, breakdown)
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return
+	}
+	if breakdown.UserID != transfer.FromUserID {
+		h.HandleError(c, errTransferOwnerChange, http.StatusConflict)
+		return
+	}
+
+	// The recipient must still be a member if the breakdown stays in a shared workspace
+	workspace, ok := h.sharedWorkspace(c, breakdown, transfer.ToUserID)
+	if !ok {
+		return
+	}
+	if workspace == nil {
+		workspace, err = h.WorkspaceRepo.EnsurePersonalWorkspace(c.Request.Context(), transfer.ToUserID)
+		if err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Claim the transfer before moving anything, so a concurrent accept or decline can't also succeed
+	now := time.Now()
+	if !h.resolve(c, transfer, models.TransferAccepted, now) {
+		return
+	}
+
+	// Hand over ownership
+	err = h.BreakdownRepo.Reassign(c.Request.Context(), transfer.BreakdownID, transfer.FromUserID, transfer.ToUserID, workspace.ID, now)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			err, status = errTransferOwnerChange, http.StatusConflict
+		}
+		// Leave the transfer pending, as it is when the owner check above fails
+		if reopenErr := h.Repo.Reopen(c.Request.Context(), transfer.ID, models.TransferAccepted, time.Now()); reopenErr != nil {
+			logging.FromContext(c.Request.Context()).Error("Failed to reopen transfer", "transfer_id", transfer.ID.Hex(), "error", reopenErr)
+		}
+		h.HandleError(c, err, status)
+		return
+	}
+
+	// Record the transfer in the activity log
+	err = h.ActivityRepo.Record(c.Request.Context(), &models.Activity{
+		ActorID:     transfer.ToUserID,
+		WorkspaceID: workspace.ID,
+		BreakdownID: transfer.BreakdownID,
+		Type:        models.ActivityBreakdownTransferred,
+		Data: map[string]interface{}{
+			"transfer_id":  transfer.ID,
+			"from_user_id": transfer.FromUserID,
+			"to_user_id":   transfer.ToUserID,
+			"workspace_id": workspace.ID,
+			"name":         breakdown.Name,
+		},
+		CreatedAt: now,
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditTransferAccepted,
+		TargetType: "breakdown",
+		TargetID:   transfer.BreakdownID.Hex(),
+		Before:     map[string]interface{}{"user_id": transfer.FromUserID.Hex(), "workspace_id": breakdown.WorkspaceID.Hex()},
+		After:      map[string]interface{}{"user_id": transfer.ToUserID.Hex(), "workspace_id": workspace.ID.Hex()},
+		Metadata:   map[string]interface{}{"transfer_id": transfer.ID.Hex()},
+	})
+	h.Respond(c, http.StatusOK, transfer)
+}
+
+// DeclineTransfer declines a pending transfer offered to the authenticated user. The breakdown stays
+// with the user who offered it.
+func (h *TransferHandler) DeclineTransfer(c *gin.Context) {
+	transfer, ok := h.findPendingTransfer(c)
+	if !ok {
+		return
+	}
+
+	// The breakdown may have been deleted since the transfer was offered; it can still be declined
+	breakdown := &models.Breakdown{}
+	err := h.BreakdownRepo.FindOne(c.Request.Context(), bson.M{"_id": transfer.BreakdownID}, breakdown)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	found := err == nil
+
+	now := time.Now()
+	if !h.resolve(c, transfer, models.TransferDeclined, now) {
+		return
+	}
+
+	// Record the decline in the activity log of the breakdown's workspace
+	if found {
+		err = h.ActivityRepo.Record(c.Request.Context(), &models.Activity{
+			ActorID:     transfer.ToUserID,
+			WorkspaceID: breakdown.WorkspaceID,
+			BreakdownID: transfer.BreakdownID,
+			Type:        models.ActivityTransferDeclined,
+			Data: map[string]interface{}{
+				"transfer_id":  transfer.ID,
+				"from_user_id": transfer.FromUserID,
+				"to_user_id":   transfer.ToUserID,
+				"name":         breakdown.Name,
+			},
+			CreatedAt: now,
+		})
+		if err != nil {
+			h.HandleError(c, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditTransferDeclined,
+		TargetType: "breakdown",
+		TargetID:   transfer.BreakdownID.Hex(),
+		Metadata:   map[string]interface{}{"transfer_id": transfer.ID.Hex(), "from_user_id": transfer.FromUserID.Hex()},
+	})
+	h.Respond(c, http.StatusOK, transfer)
+}
+
+// sharedWorkspace returns the breakdown's workspace if it is shared, after checking that recipientID can
+// own breakdowns there, or nil if it is a personal workspace
+func (h *TransferHandler) sharedWorkspace(c *gin.Context, breakdown *models.Breakdown, recipientID primitive.ObjectID) (*models.Workspace, bool) {
+	workspace := &models.Workspace{}
+	if err := h.WorkspaceRepo.FindOne(c.Request.Context(), bson.M{"_id": breakdown.WorkspaceID}, workspace); err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return nil, false
+	}
+	if workspace.Personal {
+		return nil, true
+	}
+
+	membership, err := h.WorkspaceRepo.FindMembership(c.Request.Context(), workspace.ID, recipientID)
+	if errors.Is(err, mongo.ErrNoDocuments) || err == nil && !models.WorkspaceRoleAtLeast(membership.Role, models.WorkspaceMember) {
+		h.HandleError(c, errRecipientNotMember, http.StatusForbidden)
+		return nil, false
+	}
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return workspace, true
+}
+
+// findPendingTransfer loads the transfer from the URL and checks it is pending and addressed to the authenticated user
+func (h *TransferHandler) findPendingTransfer(c *gin.Context) (*models.Transfer, bool) {
+	// Get user ID from context
+	userID, err := middleware.GetUserID(c)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Parse transfer ID from URL
+	objID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	transfer := &models.Transfer{}
+	err = h.Repo.FindOne(c.Request.Context(), bson.M{"_id": objID}, transfer)
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return nil, false
+	}
+
+	// Only the recipient can resolve a transfer
+	userObjID, _ := primitive.ObjectIDFromHex(userID)
+	if transfer.ToUserID != userObjID {
+		h.HandleError(c, errUnauthorized, http.StatusForbidden)
+		return nil, false
+	}
+	if transfer.Status != models.TransferPending {
+		h.HandleError(c, errTransferNotPending, http.StatusConflict)
+		return nil, false
+	}
+
+	return transfer, true
+}
+
+// resolve moves a pending transfer to its final status, responding with a conflict if it was resolved
+// concurrently
+func (h *TransferHandler) resolve(c *gin.Context, transfer *models.Transfer, status string, now time.Time) bool {
+	err := h.Repo.Resolve(c.Request.Context(), transfer.ID, status, now)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		h.HandleError(c, errTransferNotPending, http.StatusConflict)
+		return false
+	}
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return false
+	}
+
+	transfer.Status = status
+	transfer.UpdatedAt = now
+	return true
+}
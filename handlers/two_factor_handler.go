@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"server/db/models"
+	"server/db/repository"
+	"server/metrics"
+	"server/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// totpIssuer is the account issuer shown in authenticator apps
+const totpIssuer = "flow"
+
+// recoveryCodeCount is the number of recovery codes issued when two-factor authentication is enabled
+const recoveryCodeCount = 10
+
+var (
+	errTwoFactorEnabled    = errors.New("two-factor authentication is already enabled")
+	errTwoFactorDisabled   = errors.New("two-factor authentication is not enabled")
+	errNoPendingEnrollment = errors.New("no two-factor enrollment in progress")
+	errInvalidCode         = errors.New("invalid two-factor code")
+	errInvalidPassword     = errors.New("invalid password")
+)
+
+// TwoFactorCodeRequest represents a TOTP code submitted to confirm enrollment
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest represents the re-authentication needed to turn off two-factor authentication;
+// the password may be left out just after signing in with an identity provider
+type DisableTwoFactorRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+// TwoFactorLoginRequest represents the second step of a login with two-factor authentication
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+// EnrollTwoFactor starts two-factor enrollment and returns a new secret for the authenticator app
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+	if user.TOTPEnabled {
+		h.HandleError(c, errTwoFactorEnabled, http.StatusConflict)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Keep the secret pending until the user proves their app generates valid codes
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"totp_pending_secret": secret, "updated_at": time.Now()},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Respond(c, http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// ConfirmTwoFactor enables two-factor authentication once a code from the pending secret is verified
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	var request TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+	if user.TOTPEnabled {
+		h.HandleError(c, errTwoFactorEnabled, http.StatusConflict)
+		return
+	}
+	if user.TOTPPendingSecret == "" {
+		h.HandleError(c, errNoPendingEnrollment, http.StatusBadRequest)
+		return
+	}
+
+	counter, ok := utils.ValidateTOTP(user.TOTPPendingSecret, request.Code, time.Now(), 0)
+	if !ok {
+		h.HandleError(c, errInvalidCode, http.StatusBadRequest)
+		return
+	}
+
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	hashed := make([]string, len(codes))
+	for i, code := range codes {
+		hashed[i] = utils.HashRecoveryCode(code)
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"totp_enabled":      true,
+			"totp_secret":       user.TOTPPendingSecret,
+			"totp_last_counter": counter,
+			"recovery_codes":    hashed,
+			"updated_at":        time.Now(),
+		},
+		"$unset": bson.M{"totp_pending_secret": ""},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{Action: models.AuditTwoFactorEnabled})
+
+	// Recovery codes are only ever shown here
+	h.Respond(c, http.StatusOK, gin.H{
+		"two_factor_enabled": true,
+		"recovery_codes":     codes,
+	})
+}
+
+// DisableTwoFactor turns off two-factor authentication after re-authenticating the user and checking a code
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var request DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.currentUser(c)
+	if err != nil {
+		return
+	}
+	if !user.TOTPEnabled {
+		h.HandleError(c, errTwoFactorDisabled, http.StatusBadRequest)
+		return
+	}
+
+	if !h.reauthenticate(c, user, request.Password) {
+		return
+	}
+	if err := h.UserRepo.ValidateSecondFactor(c.Request.Context(), user, request.Code); err != nil {
+		h.handleCredentialsError(c, err)
+		return
+	}
+
+	err = h.UserRepo.Update(c.Request.Context(), bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{"totp_enabled": false, "updated_at": time.Now()},
+		"$unset": bson.M{
+			"totp_secret":         "",
+			"totp_pending_secret": "",
+			"totp_last_counter":   "",
+			"totp_challenge":      "",
+			"recovery_codes":      "",
+		},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{Action: models.AuditTwoFactorDisabled})
+	h.Respond(c, http.StatusOK, gin.H{"two_factor_enabled": false})
+}
+
+// LoginTwoFactor completes a login by exchanging a challenge token and a valid code for a JWT token
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var request TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	claims, err := utils.ValidateChallengeToken(request.ChallengeToken)
+	if err != nil {
+		metrics.LoginAttempt("2fa", metrics.LoginFailed)
+		h.HandleError(c, err, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.UserRepo.FindUserByID(c.Request.Context(), claims.UserID)
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+	if !user.TOTPEnabled {
+		h.HandleError(c, errTwoFactorDisabled, http.StatusBadRequest)
+		return
+	}
+	// The account may have been disabled since the challenge was issued
+	if !h.checkEnabled(c, user, "2fa") {
+		return
+	}
+
+	if err := h.UserRepo.ValidateSecondFactor(c.Request.Context(), user, request.Code); err != nil {
+		h.Audit(c, &models.AuditEvent{
+			Action:   models.AuditLoginFailed,
+			UserID:   &user.ID,
+			Metadata: map[string]interface{}{"method": "2fa", "reason": err.Error()},
+		})
+		metrics.LoginAttempt("2fa", loginFailure(err))
+		h.handleCredentialsError(c, err)
+		return
+	}
+
+	// Each challenge can only be exchanged once
+	if err := h.UserRepo.ConsumeTwoFactorChallenge(c.Request.Context(), user.ID, claims.ID); err != nil {
+		if errors.Is(err, repository.ErrChallengeUsed) {
+			metrics.LoginAttempt("2fa", metrics.LoginFailed)
+			h.HandleError(c, err, http.StatusUnauthorized)
+			return
+		}
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:   models.AuditLoginSucceeded,
+		ActorID:  &user.ID,
+		Metadata: map[string]interface{}{"method": "2fa"},
+	})
+	metrics.LoginAttempt("2fa", metrics.LoginSucceeded)
+	h.respondWithToken(c, http.StatusOK, user, claims.Method)
+}
@@ -0,0 +1,593 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"server/db/models"
+	"server/db/repository"
+	"server/mail"
+	"server/middleware"
+	"server/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// invitationTTL is how long a workspace invitation stays valid
+const invitationTTL = 7 * 24 * time.Hour
+
+var (
+	errPersonalWorkspace  = errors.New("personal workspaces cannot be shared or deleted")
+	errInvalidMemberRole  = errors.New("role must be admin, member or guest")
+	errOwnerOnly          = errors.New("only the workspace owner can manage admins")
+	errOwnerMembership    = errors.New("the workspace owner cannot be removed or change role")
+	errMemberNotFound     = errors.New("member not found")
+	errAlreadyMember      = errors.New("user is already a member of this workspace")
+	errInvalidInvitation  = errors.New("invalid or expired invitation")
+	errInvitationMismatch = errors.New("invitation was sent to a different email address")
+)
+
+// WorkspaceRequest represents the data needed to create or rename a workspace
+type WorkspaceRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MemberRoleRequest represents a change to a member's role
+type MemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// InvitationRequest represents the data needed to invite someone to a workspace
+type InvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// AcceptInvitationRequest represents the token from an invitation email
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type WorkspaceHandler struct {
+	BaseHandler
+	Repo           *repository.WorkspaceRepository
+	InvitationRepo *repository.InvitationRepository
+	UserRepo       *repository.UserRepository
+	BreakdownRepo  *repository.BreakdownRepository
+	CommentRepo    *repository.CommentRepository
+	Mailer         mail.Mailer
+}
+
+func NewWorkspaceHandler(repo *repository.WorkspaceRepository, invitationRepo *repository.InvitationRepository, userRepo *repository.UserRepository, breakdownRepo *repository.BreakdownRepository, commentRepo *repository.CommentRepository, mailer mail.Mailer, auditRepo *repository.AuditRepository) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		BaseHandler:    BaseHandler{AuditRepo: auditRepo},
+		Repo:           repo,
+		InvitationRepo: invitationRepo,
+		UserRepo:       userRepo,
+		BreakdownRepo:  breakdownRepo,
+		CommentRepo:    commentRepo,
+		Mailer:         mailer,
+	}
+}
+
+// GetWorkspaces lists the workspaces the authenticated user belongs to, for the workspace switcher
+func (h *WorkspaceHandler) GetWorkspaces(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	// Make sure the personal workspace is listed even before it's first used
+	if _, err := h.Repo.EnsurePersonalWorkspace(c.Request.Context(), userID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	workspaces, roles, err := h.Repo.FindUserWorkspaces(c.Request.Context(), userID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]gin.H, len(workspaces))
+	for i := range workspaces {
+		response[i] = workspaceView(&workspaces[i], roles[workspaces[i].ID])
+	}
+	h.Respond(c, http.StatusOK, response)
+}
+
+// CreateWorkspace creates a shared workspace owned by the authenticated user
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var request WorkspaceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	workspace := &models.Workspace{
+		Name:      strings.TrimSpace(request.Name),
+		OwnerID:   userID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := h.Repo.CreateWorkspace(c.Request.Context(), workspace); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditWorkspaceCreated,
+		TargetType: "workspace",
+		TargetID:   workspace.ID.Hex(),
+		After:      map[string]interface{}{"name": workspace.Name},
+	})
+	h.Respond(c, http.StatusCreated, workspaceView(workspace, models.WorkspaceOwner))
+}
+
+// GetWorkspace retrieves the current workspace
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+	workspace, ok := h.currentWorkspaceDocument(c)
+	if !ok {
+		return
+	}
+
+	h.Respond(c, http.StatusOK, workspaceView(workspace, middleware.GetWorkspaceRole(c)))
+}
+
+// UpdateWorkspace renames the current workspace
+func (h *WorkspaceHandler) UpdateWorkspace(c *gin.Context) {
+	workspace, ok := h.currentWorkspaceDocument(c)
+	if !ok {
+		return
+	}
+
+	var request WorkspaceRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	before := workspace.Name
+	workspace.Name = strings.TrimSpace(request.Name)
+	workspace.UpdatedAt = time.Now()
+	err := h.Repo.Update(c.Request.Context(), bson.M{"_id": workspace.ID}, bson.M{
+		"$set": bson.M{"name": workspace.Name, "updated_at": workspace.UpdatedAt},
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditWorkspaceUpdated,
+		TargetType: "workspace",
+		TargetID:   workspace.ID.Hex(),
+		Before:     map[string]interface{}{"name": before},
+		After:      map[string]interface{}{"name": workspace.Name},
+	})
+	h.Respond(c, http.StatusOK, workspaceView(workspace, middleware.GetWorkspaceRole(c)))
+}
+
+// DeleteWorkspace deletes a shared workspace along with its breakdowns
+func (h *WorkspaceHandler) DeleteWorkspace(c *gin.Context) {
+	workspace, ok := h.currentWorkspaceDocument(c)
+	if !ok {
+		return
+	}
+	if workspace.Personal {
+		h.HandleError(c, errPersonalWorkspace, http.StatusBadRequest)
+		return
+	}
+
+	breakdownIDs, err := h.BreakdownRepo.FindIDs(c.Request.Context(), bson.M{"workspace_id": workspace.ID})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.CommentRepo.DeleteForBreakdowns(c.Request.Context(), breakdownIDs); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.BreakdownRepo.Delete(c.Request.Context(), bson.M{"workspace_id": workspace.ID}); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.InvitationRepo.Delete(c.Request.Context(), bson.M{"workspace_id": workspace.ID}); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	if err := h.Repo.DeleteWorkspace(c.Request.Context(), workspace.ID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditWorkspaceDeleted,
+		TargetType: "workspace",
+		TargetID:   workspace.ID.Hex(),
+		Before:     map[string]interface{}{"name": workspace.Name},
+	})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Workspace deleted successfully"})
+}
+
+// GetMembers lists the members of the current workspace
+func (h *WorkspaceHandler) GetMembers(c *gin.Context) {
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return
+	}
+
+	members, err := h.Repo.FindMembers(c.Request.Context(), workspaceID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	userIDs := make([]primitive.ObjectID, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	users, err := h.UserRepo.FindUsersByIDs(c.Request.Context(), userIDs)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Every member is listed; one whose account can't be found is shown without its details.
+	// Emails are only shown to members and above in an interactive session, not to guests or
+	// to access tokens, which may only have been granted access to breakdowns.
+	showEmails := models.WorkspaceRoleAtLeast(middleware.GetWorkspaceRole(c), models.WorkspaceMember) && !middleware.UsesAccessToken(c)
+	response := make([]gin.H, 0, len(members))
+	for _, member := range members {
+		view := gin.H{
+			"user_id":   member.UserID,
+			"role":      member.Role,
+			"joined_at": member.CreatedAt,
+		}
+		if user, ok := users[member.UserID]; ok {
+			view["username"] = user.Username
+			view["display_name"] = user.DisplayName
+			if showEmails {
+				view["email"] = user.Email
+			}
+		}
+		response = append(response, view)
+	}
+	h.Respond(c, http.StatusOK, response)
+}
+
+// UpdateMember changes a member's role. Only the owner can promote members to admin or demote admins.
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return
+	}
+
+	var request MemberRoleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+	if !isAssignableRole(request.Role) {
+		h.HandleError(c, errInvalidMemberRole, http.StatusBadRequest)
+		return
+	}
+
+	member, ok := h.findMember(c, workspaceID)
+	if !ok {
+		return
+	}
+	if member.Role == models.WorkspaceOwner {
+		h.HandleError(c, errOwnerMembership, http.StatusBadRequest)
+		return
+	}
+	if (member.Role == models.WorkspaceAdmin || request.Role == models.WorkspaceAdmin) && middleware.GetWorkspaceRole(c) != models.WorkspaceOwner {
+		h.HandleError(c, errOwnerOnly, http.StatusForbidden)
+		return
+	}
+
+	if err := h.Repo.UpdateMemberRole(c.Request.Context(), workspaceID, member.UserID, request.Role); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditMemberRoleChanged,
+		TargetType: "workspace",
+		TargetID:   workspaceID.Hex(),
+		Before:     map[string]interface{}{"role": member.Role},
+		After:      map[string]interface{}{"role": request.Role},
+		Metadata:   map[string]interface{}{"member_id": member.UserID.Hex()},
+	})
+	h.Respond(c, http.StatusOK, gin.H{"user_id": member.UserID, "role": request.Role})
+}
+
+// RemoveMember removes someone from the current workspace. Any member can leave; removing others
+// takes an admin, and removing an admin takes the owner.
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return
+	}
+
+	member, ok := h.findMember(c, workspaceID)
+	if !ok {
+		return
+	}
+	role := middleware.GetWorkspaceRole(c)
+	switch {
+	case member.Role == models.WorkspaceOwner:
+		h.HandleError(c, errOwnerMembership, http.StatusBadRequest)
+		return
+	case member.UserID == userID:
+		// Leaving the workspace
+	case member.Role == models.WorkspaceAdmin && role != models.WorkspaceOwner:
+		h.HandleError(c, errOwnerOnly, http.StatusForbidden)
+		return
+	case !models.WorkspaceRoleAtLeast(role, models.WorkspaceAdmin):
+		h.HandleError(c, errUnauthorized, http.StatusForbidden)
+		return
+	}
+
+	if err := h.Repo.RemoveMember(c.Request.Context(), workspaceID, member.UserID); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditMemberRemoved,
+		TargetType: "workspace",
+		TargetID:   workspaceID.Hex(),
+		Before:     map[string]interface{}{"role": member.Role},
+		Metadata:   map[string]interface{}{"member_id": member.UserID.Hex()},
+	})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// CreateInvitation emails an invitation to join the current workspace
+func (h *WorkspaceHandler) CreateInvitation(c *gin.Context) {
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+	workspace, ok := h.currentWorkspaceDocument(c)
+	if !ok {
+		return
+	}
+	if workspace.Personal {
+		h.HandleError(c, errPersonalWorkspace, http.StatusBadRequest)
+		return
+	}
+
+	var request InvitationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+	if !isAssignableRole(request.Role) {
+		h.HandleError(c, errInvalidMemberRole, http.StatusBadRequest)
+		return
+	}
+	if request.Role == models.WorkspaceAdmin && middleware.GetWorkspaceRole(c) != models.WorkspaceOwner {
+		h.HandleError(c, errOwnerOnly, http.StatusForbidden)
+		return
+	}
+
+	// Don't invite people who have already joined
+	if invitee, err := h.UserRepo.FindUserByEmail(c.Request.Context(), request.Email); err == nil {
+		if _, err := h.Repo.FindMembership(c.Request.Context(), workspace.ID, invitee.ID); err == nil {
+			h.HandleError(c, errAlreadyMember, http.StatusConflict)
+			return
+		}
+	}
+
+	token, err := utils.GenerateRandomToken()
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	invitation := &models.Invitation{
+		ID:          primitive.NewObjectID(),
+		WorkspaceID: workspace.ID,
+		Email:       request.Email,
+		Role:        request.Role,
+		TokenHash:   utils.HashToken(token),
+		InvitedBy:   userID,
+		ExpiresAt:   time.Now().Add(invitationTTL),
+		CreatedAt:   time.Now(),
+	}
+	if err := h.InvitationRepo.Create(c.Request.Context(), invitation); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	err = h.Mailer.Send(c.Request.Context(), mail.Message{
+		To:      request.Email,
+		Subject: fmt.Sprintf("You've been invited to %s on flow", workspace.Name),
+		Body: fmt.Sprintf("Hi,\n\nYou've been invited to join the %s workspace on flow as a %s. Accept the invitation by opening the link below within 7 days:\n\n%s/invitations/accept?token=%s\n\nIf you weren't expecting this invitation you can ignore this email.\n",
+			workspace.Name, request.Role, appURL(), token),
+	})
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditMemberInvited,
+		TargetType: "workspace",
+		TargetID:   workspace.ID.Hex(),
+		Metadata:   map[string]interface{}{"invitation_id": invitation.ID.Hex(), "email": invitation.Email, "role": invitation.Role},
+	})
+	h.Respond(c, http.StatusCreated, invitation)
+}
+
+// GetInvitations lists the pending invitations to the current workspace
+func (h *WorkspaceHandler) GetInvitations(c *gin.Context) {
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := h.InvitationRepo.FindPendingInvitations(c.Request.Context(), workspaceID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+	h.Respond(c, http.StatusOK, invitations)
+}
+
+// RevokeInvitation cancels a pending invitation to the current workspace
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(c.Param("invitation_id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	invitation := &models.Invitation{}
+	filter := bson.M{"_id": objID, "workspace_id": workspaceID, "accepted_at": bson.M{"$exists": false}}
+	if err := h.InvitationRepo.FindOne(c.Request.Context(), filter, invitation); err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return
+	}
+	if err := h.InvitationRepo.Delete(c.Request.Context(), filter); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditInvitationRevoked,
+		TargetType: "workspace",
+		TargetID:   workspaceID.Hex(),
+		Metadata:   map[string]interface{}{"invitation_id": objID.Hex(), "email": invitation.Email},
+	})
+	h.Respond(c, http.StatusOK, gin.H{"message": "Invitation revoked successfully"})
+}
+
+// AcceptInvitation adds the authenticated user to a workspace using the token from an invitation email.
+// The invitation must have been sent to the user's email address.
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	var request AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return
+	}
+
+	userID, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+	user, err := h.UserRepo.FindUserByID(c.Request.Context(), userID.Hex())
+	if err != nil {
+		h.HandleError(c, errUnauthorized, http.StatusUnauthorized)
+		return
+	}
+
+	invitation, err := h.InvitationRepo.FindPendingInvitationByToken(c.Request.Context(), utils.HashToken(request.Token))
+	if err != nil {
+		h.HandleError(c, errInvalidInvitation, http.StatusBadRequest)
+		return
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) {
+		h.HandleError(c, errInvitationMismatch, http.StatusForbidden)
+		return
+	}
+
+	workspace, err := h.Repo.FindWorkspaceByID(c.Request.Context(), invitation.WorkspaceID.Hex())
+	if err != nil {
+		h.HandleError(c, errInvalidInvitation, http.StatusBadRequest)
+		return
+	}
+
+	// Claim the invitation before joining so it can only be used once
+	if err := h.InvitationRepo.Accept(c.Request.Context(), invitation.ID); err != nil {
+		h.HandleError(c, errInvalidInvitation, http.StatusBadRequest)
+		return
+	}
+	if err := h.Repo.AddMember(c.Request.Context(), workspace.ID, user.ID, invitation.Role); err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	membership, err := h.Repo.FindMembership(c.Request.Context(), workspace.ID, user.ID)
+	if err != nil {
+		h.HandleError(c, err, http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit(c, &models.AuditEvent{
+		Action:     models.AuditMemberJoined,
+		TargetType: "workspace",
+		TargetID:   workspace.ID.Hex(),
+		Metadata:   map[string]interface{}{"invitation_id": invitation.ID.Hex(), "role": membership.Role},
+	})
+	h.Respond(c, http.StatusOK, workspaceView(workspace, membership.Role))
+}
+
+// currentWorkspaceDocument loads the workspace the request is scoped to
+func (h *WorkspaceHandler) currentWorkspaceDocument(c *gin.Context) (*models.Workspace, bool) {
+	workspaceID, ok := h.currentWorkspace(c)
+	if !ok {
+		return nil, false
+	}
+
+	workspace, err := h.Repo.FindWorkspaceByID(c.Request.Context(), workspaceID.Hex())
+	if err != nil {
+		h.HandleError(c, err, http.StatusNotFound)
+		return nil, false
+	}
+	return workspace, true
+}
+
+// findMember loads the membership named by the :user_id route parameter
+func (h *WorkspaceHandler) findMember(c *gin.Context, workspaceID primitive.ObjectID) (*models.Membership, bool) {
+	memberID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+	if err != nil {
+		h.HandleError(c, err, http.StatusBadRequest)
+		return nil, false
+	}
+
+	member, err := h.Repo.FindMembership(c.Request.Context(), workspaceID, memberID)
+	if err != nil {
+		h.HandleError(c, errMemberNotFound, http.StatusNotFound)
+		return nil, false
+	}
+	return member, true
+}
+
+// isAssignableRole reports whether a role can be given through invitations or role changes
+func isAssignableRole(role string) bool {
+	return models.IsWorkspaceRole(role) && role != models.WorkspaceOwner
+}
+
+// workspaceView is the JSON representation of a workspace for one of its members
+func workspaceView(workspace *models.Workspace, role string) gin.H {
+	return gin.H{
+		"id":         workspace.ID,
+		"name":       workspace.Name,
+		"owner_id":   workspace.OwnerID,
+		"personal":   workspace.Personal,
+		"role":       role,
+		"created_at": workspace.CreatedAt,
+		"updated_at": workspace.UpdatedAt,
+	}
+}
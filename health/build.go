@@ -0,0 +1,49 @@
+package health
+
+import (
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// Version and Commit identify the build. Set them at link time, e.g.
+// go build -ldflags "-X server/health.Version=1.4.0 -X server/health.Commit=$(git rev-parse HEAD)"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+var startedAt = time.Now()
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	GoVersion string    `json:"go_version"`
+	StartedAt time.Time `json:"started_at"`
+	Uptime    string    `json:"uptime"`
+}
+
+// Build returns the build information and how long the process has been running
+func Build() BuildInfo {
+	commit := Commit
+	if commit == "" {
+		commit = "unknown"
+		// Fall back to the revision the Go toolchain stamps into binaries built from a checkout
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, setting := range info.Settings {
+				if setting.Key == "vcs.revision" {
+					commit = setting.Value
+				}
+			}
+		}
+	}
+
+	return BuildInfo{
+		Version:   Version,
+		Commit:    commit,
+		GoVersion: runtime.Version(),
+		StartedAt: startedAt,
+		Uptime:    time.Since(startedAt).Round(time.Second).String(),
+	}
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Status summarises the result of one check or of all of them
+type Status string
+
+const (
+	StatusOK       Status = "ok"
+	StatusDegraded Status = "degraded" // A non-critical dependency is failing; the service still works
+	StatusFailing  Status = "failing"
+)
+
+const (
+	// defaultTimeout bounds a check that doesn't set its own timeout
+	defaultTimeout = 2 * time.Second
+	// defaultCacheTTL is how long results are reused so frequent probes don't load dependencies
+	defaultCacheTTL = 5 * time.Second
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// Check is a named dependency check
+type Check struct {
+	Name     string
+	Check    func(ctx context.Context) error
+	Timeout  time.Duration // Defaults to 2 seconds
+	Critical bool          // A failing critical check makes the service unready; others only degrade it
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the combined outcome of every registered check
+type Report struct {
+	Status Status            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Registry holds the checks that decide whether the service is ready to receive traffic
+type Registry struct {
+	CacheTTL time.Duration
+
+	mu           sync.Mutex
+	entries      []*entry
+	shuttingDown atomic.Bool
+}
+
+// entry caches the last result of a check; its lock makes concurrent probes share one run
+type entry struct {
+	check  Check
+	mu     sync.Mutex
+	result Result
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		CacheTTL: defaultCacheTTL,
+	}
+}
+
+// Register adds a check
+func (r *Registry) Register(check Check) {
+	if check.Timeout <= 0 {
+		check.Timeout = defaultTimeout
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, &entry{check: check})
+}
+
+// SetShuttingDown makes the service report itself unready from now on
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// ShuttingDown reports whether graceful shutdown has begun
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Run runs every check concurrently, reusing results younger than CacheTTL
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.Lock()
+	entries := append([]*entry(nil), r.entries...)
+	r.mu.Unlock()
+
+	results := make([]Result, len(entries))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = e.run(ctx, r.CacheTTL)
+		}(i, e)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(entries))}
+	for i, e := range entries {
+		result := results[i]
+		if result.Status == StatusFailing && !e.check.Critical {
+			result.Status = StatusDegraded
+		}
+		report.Checks[e.check.Name] = result
+		report.Status = worse(report.Status, result.Status)
+	}
+
+	if r.ShuttingDown() {
+		report.Status = StatusFailing
+		report.Checks["shutdown"] = Result{Status: StatusFailing, Error: errShuttingDown.Error(), CheckedAt: time.Now()}
+	}
+	return report
+}
+
+func (e *entry) run(ctx context.Context, ttl time.Duration) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.result.CheckedAt.IsZero() && time.Since(e.result.CheckedAt) < ttl {
+		return e.result
+	}
+
+	// The result is shared with other probes, so a probe that gives up early mustn't cut the
+	// check short and have its cancellation cached as a failure
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := e.check.Check(ctx)
+	e.result = Result{
+		Status:    StatusOK,
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		e.result.Status = StatusFailing
+		e.result.Error = err.Error()
+	}
+	return e.result
+}
+
+// worse returns the more severe of two statuses
+func worse(a, b Status) Status {
+	rank := map[Status]int{StatusOK: 0, StatusDegraded: 1, StatusFailing: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
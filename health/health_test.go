@@ -0,0 +1,119 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunCombinesChecks(t *testing.T) {
+	failing := func(ctx context.Context) error { return errors.New("unreachable") }
+	passing := func(ctx context.Context) error { return nil }
+
+	tests := []struct {
+		name   string
+		checks []Check
+		want   Status
+	}{
+		{"no checks", nil, StatusOK},
+		{"all passing", []Check{{Name: "mongo", Check: passing, Critical: true}, {Name: "mail", Check: passing}}, StatusOK},
+		{"non-critical failing", []Check{{Name: "mongo", Check: passing, Critical: true}, {Name: "mail", Check: failing}}, StatusDegraded},
+		{"critical failing", []Check{{Name: "mongo", Check: failing, Critical: true}, {Name: "mail", Check: failing}}, StatusFailing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry()
+			for _, check := range tt.checks {
+				registry.Register(check)
+			}
+			report := registry.Run(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("status = %s, want %s", report.Status, tt.want)
+			}
+			if len(report.Checks) != len(tt.checks) {
+				t.Errorf("checks = %v, want one result per check", report.Checks)
+			}
+		})
+	}
+}
+
+func TestRunFailsDuringShutdown(t *testing.T) {
+	registry := NewRegistry()
+	registry.SetShuttingDown()
+
+	report := registry.Run(context.Background())
+	if report.Status != StatusFailing || report.Checks["shutdown"].Status != StatusFailing {
+		t.Errorf("report during shutdown = %+v, want failing", report)
+	}
+}
+
+func TestRunCachesResults(t *testing.T) {
+	var runs atomic.Int32
+	registry := NewRegistry()
+	registry.CacheTTL = 50 * time.Millisecond
+	registry.Register(Check{Name: "mongo", Check: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+
+	registry.Run(context.Background())
+	registry.Run(context.Background())
+	if runs.Load() != 1 {
+		t.Errorf("check ran %d times within the cache TTL, want once", runs.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	registry.Run(context.Background())
+	if runs.Load() != 2 {
+		t.Errorf("check ran %d times after the cache TTL, want twice", runs.Load())
+	}
+}
+
+func TestRunOutlivesCancelledProbe(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(Check{Name: "mongo", Critical: true, Check: func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		}
+	}})
+
+	// The probe gives up before the check finishes; the cached result must still be the check's own
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	registry.Run(ctx)
+
+	if report := registry.Run(context.Background()); report.Status != StatusOK {
+		t.Errorf("status after a cancelled probe = %s %v, want ok", report.Status, report.Checks)
+	}
+}
+
+func TestRunTimesOutSlowChecks(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register(Check{Name: "mongo", Critical: true, Timeout: 10 * time.Millisecond, Check: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	start := time.Now()
+	report := registry.Run(context.Background())
+	if report.Status != StatusFailing || time.Since(start) > time.Second {
+		t.Errorf("slow check = %s after %s, want failing at its timeout", report.Status, time.Since(start))
+	}
+}
+
+func TestHeartbeatCheck(t *testing.T) {
+	var heartbeat Heartbeat
+	check := heartbeat.Check(time.Minute)
+	if err := check(context.Background()); err == nil {
+		t.Error("check before the first beat succeeded")
+	}
+	heartbeat.Beat()
+	if err := check(context.Background()); err != nil {
+		t.Errorf("check after a beat = %v", err)
+	}
+}
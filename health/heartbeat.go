@@ -0,0 +1,45 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var errNoHeartbeat = errors.New("no heartbeat yet")
+
+// Heartbeat records when a background loop last made progress
+type Heartbeat struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// Beat records that the loop is alive
+func (h *Heartbeat) Beat() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.last = time.Now()
+}
+
+// Last returns the time of the latest beat
+func (h *Heartbeat) Last() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.last
+}
+
+// Check returns a check that fails when no beat has been recorded within maxAge
+func (h *Heartbeat) Check(maxAge time.Duration) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		last := h.Last()
+		if last.IsZero() {
+			return errNoHeartbeat
+		}
+		if age := time.Since(last); age > maxAge {
+			return fmt.Errorf("last heartbeat %s ago", age.Round(time.Second))
+		}
+		return nil
+	}
+}
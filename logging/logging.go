@@ -0,0 +1,55 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	"server/config"
+)
+
+// Redacted replaces the values of sensitive attributes
+const Redacted = "REDACTED"
+
+// sensitiveKeys are attribute key fragments whose values are never written to the log
+var sensitiveKeys = []string{"authorization", "cookie", "password", "secret", "token"}
+
+type contextKey struct{}
+
+// New builds a logger writing to w in the configured format, dropping entries below the configured level
+func New(w io.Writer, cfg config.Logging) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	if cfg.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// WithContext returns a copy of ctx carrying logger
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger attached to ctx, which carries request details such as the
+// request and user IDs, or the default logger outside a request
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// redact hides the values of attributes whose keys suggest credentials
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, Redacted)
+		}
+	}
+	return attr
+}
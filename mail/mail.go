@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"server/config"
+	"server/logging"
+	"strings"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+	// Ping checks that the mail transport is reachable
+	Ping(ctx context.Context) error
+}
+
+// LogMailer writes messages to the log instead of sending them, for local development
+type LogMailer struct{}
+
+// Send logs the message's recipient and subject. The body isn't logged, since it can carry
+// verification and password reset tokens.
+func (LogMailer) Send(ctx context.Context, msg Message) error {
+	logging.FromContext(ctx).Info("Email not sent, logged instead", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
+
+// Ping always succeeds since the log is always available
+func (LogMailer) Ping(ctx context.Context) error {
+	return nil
+}
+
+// SMTPMailer sends messages through an SMTP server
+type SMTPMailer struct {
+	Addr     string // host:port
+	From     string
+	Username string
+	Password string
+}
+
+// Send delivers the message over SMTP
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	// Reject header injection through the recipient or subject
+	if strings.ContainsAny(msg.To+msg.Subject, "\r\n") {
+		return fmt.Errorf("invalid email header")
+	}
+
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		m.From, msg.To, msg.Subject, msg.Body)
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, []byte(body))
+}
+
+// Ping connects to the SMTP server and waits for its greeting
+func (m *SMTPMailer) Ping(ctx context.Context) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.Addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	host, _, err := net.SplitHostPort(m.Addr)
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// New returns an SMTPMailer when an SMTP address is configured, or a LogMailer otherwise
+func New(cfg config.Mail) Mailer {
+	if cfg.SMTPAddr == "" {
+		return LogMailer{}
+	}
+	return &SMTPMailer{
+		Addr:     cfg.SMTPAddr,
+		From:     cfg.From,
+		Username: cfg.Username,
+		Password: cfg.Password,
+	}
+}
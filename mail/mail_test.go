@@ -0,0 +1,23 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"server/logging"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerOmitsBody(t *testing.T) {
+	var logged bytes.Buffer
+	ctx := logging.WithContext(context.Background(), slog.New(slog.NewTextHandler(&logged, nil)))
+
+	err := LogMailer{}.Send(ctx, Message{To: "ada@example.com", Subject: "Reset your password", Body: "Use the token s3cr3t-token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(logged.String(), "ada@example.com") || strings.Contains(logged.String(), "s3cr3t-token") {
+		t.Errorf("logged %q, want the recipient without the body", logged.String())
+	}
+}
@@ -2,73 +2,102 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
-	"server/db"
-	"server/db/repository"
+	"server/config"
 	"server/handlers"
-	"server/middleware"
-
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"server/logging"
 )
 
-func main() {
-	// Load environment variables
-	err := godotenv.Load()
-	if err != nil {
-		log.Println("Warning: Error loading .env file. Using existing environment variables.")
-	}
+const usage = `Usage: server [-config path] <command> [arguments]
 
-	// Check for required environment variables
-	if os.Getenv("JWT_SECRET") == "" {
-		log.Fatal("JWT_SECRET environment variable is required")
-	}
+Commands:
+  serve                               run the API server (the default)
+  migrate [up | down [steps] | status]
+                                      apply, revert or list database migrations
+  user create -email address -username name [-password password] [-admin]
+  user disable <email>
+  user reset-password <email>         manage user accounts
+  seed [-users n] [-breakdowns n] [-password password] [-force]
+                                      fill the database with fake data for development
+  export [-o file] <email>            write a user's breakdowns as JSON
+  import [-f file] <email>            add breakdowns from an export to a user's personal workspace
+  config check                        validate the configuration and print it with secrets redacted
+`
 
-	// Initialize MongoDB connection
-	client := db.Connect()
-	// Ensure the connection is closed when the app exits
-	defer client.Disconnect(context.TODO())
+// commands run against the database with the shared repositories
+var commands = map[string]func(ctx context.Context, a *app, args []string) error{
+	"migrate": runMigrate,
+	"user":    runUser,
+	"seed":    runSeed,
+	"export":  runExport,
+	"import":  runImport,
+}
 
-	// Initialize repositories
-	breakdownRepo := repository.NewBreakdownRepository(client)
-	userRepo := repository.NewUserRepository(client)
+func main() {
+	configPath := flag.String("config", "", "path to a YAML or TOML config file (defaults to CONFIG_FILE)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage, "\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
 
-	// Initialize handlers
-	breakdownHandler := handlers.NewBreakdownHandler(breakdownRepo)
-	authHandler := handlers.NewAuthHandler(userRepo)
+	command, args := "serve", []string(nil)
+	if flag.NArg() > 0 {
+		command, args = flag.Arg(0), flag.Args()[1:]
+	}
 
-	// Create a Gin router instance
-	router := gin.Default()
+	run, ok := commands[command]
+	if !ok && command != "serve" && command != "config" {
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	// Add a middleware (e.g., logging)
-	router.Use(gin.Logger())
-	router.Use(gin.Recovery())
+	// Load and validate the configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	slog.SetDefault(logging.New(os.Stderr, cfg.Logging))
+	handlers.SetAppURL(cfg.Server.AppURL)
 
-	// Public routes
-	router.GET("/health", handlers.HealthCheckHandler)
-	router.POST("/auth/register", authHandler.Register)
-	router.POST("/auth/login", authHandler.Login)
+	switch command {
+	case "serve":
+		err = runServe(cfg, args)
 
-	// Create an authenticated group
-	authenticated := router.Group("/")
-	authenticated.Use(middleware.AuthMiddleware())
-	{
-		// User routes
-		authenticated.GET("/profile", authHandler.GetProfile)
+	case "config":
+		if len(args) != 1 || args[0] != "check" {
+			err = errors.New("usage: config check")
+			break
+		}
+		fmt.Println("Configuration is valid")
+		err = cfg.Print(os.Stdout)
 
-		// Breakdown routes
-		authenticated.GET("/breakdowns", breakdownHandler.GetBreakdowns)
-		authenticated.GET("/breakdowns/:id", breakdownHandler.GetBreakdownByID)
-		authenticated.POST("/breakdowns", breakdownHandler.CreateBreakdown)
-		authenticated.PUT("/breakdowns/:id", breakdownHandler.UpdateBreakdown)
-		authenticated.DELETE("/breakdowns/:id", breakdownHandler.DeleteBreakdown)
+	default:
+		err = runCommand(cfg, run, args)
+	}
+	if err != nil {
+		fatal("Command failed", err)
 	}
+}
 
-	// Start the server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+// runCommand connects to the database, runs a command and disconnects
+func runCommand(cfg *config.Config, run func(ctx context.Context, a *app, args []string) error, args []string) error {
+	ctx := context.Background()
+	a, err := newApp(ctx, cfg)
+	if err != nil {
+		return err
 	}
-	router.Run(":" + port)
+	defer a.close(ctx)
+	return run(ctx, a, args)
+}
+
+// fatal logs a failure and exits
+func fatal(message string, err error) {
+	slog.Error(message, "error", err)
+	os.Exit(1)
 }
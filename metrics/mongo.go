@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MongoMonitor observes every command the driver sends, so all repositories are measured
+// whether they go through BaseRepository or use their collection directly
+func MongoMonitor() *event.CommandMonitor {
+	// Collections are only named in the started event, so remember them until the command finishes
+	var pending sync.Map
+
+	finish := func(requestID int64, operation string, duration time.Duration, failed bool) {
+		collection, ok := pending.LoadAndDelete(requestID)
+		if !ok {
+			return
+		}
+		mongoOperationDuration.WithLabelValues(collection.(string), operation).Observe(duration.Seconds())
+		if failed {
+			mongoOperationErrors.WithLabelValues(collection.(string), operation).Inc()
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			if collection := commandCollection(e.CommandName, e.Command); collection != "" {
+				pending.Store(e.RequestID, collection)
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, e.CommandName, e.Duration, false)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, e.CommandName, e.Duration, true)
+		},
+	}
+}
+
+// commandCollection returns the collection a command operates on, or "" for commands
+// such as ping and hello that don't target one
+func commandCollection(name string, command bson.Raw) string {
+	if name == "getMore" {
+		collection, _ := command.Lookup("collection").StringValueOK()
+		return collection
+	}
+	collection, _ := command.Lookup(name).StringValueOK()
+	return collection
+}
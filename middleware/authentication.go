@@ -5,13 +5,17 @@ import (
 	"net/http"
 	"strings"
 
+	"server/db/repository"
+	"server/logging"
 	"server/utils"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// AuthMiddleware checks for a valid JWT token in the Authorization header
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware checks for a valid JWT token or personal access token in the Authorization header
+func AuthMiddleware(tokenRepo *repository.AccessTokenRepository, sessionRepo *repository.SessionRepository, userRepo *repository.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -29,15 +33,122 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		token := parts[1]
-		claims, err := utils.ValidateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		var userID string
+
+		if utils.IsAccessToken(token) {
+			// Personal access tokens carry their own scopes
+			accessToken, err := tokenRepo.FindActiveToken(c.Request.Context(), token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid, expired or revoked access token"})
+				c.Abort()
+				return
+			}
+
+			userID = accessToken.UserID.Hex()
+			c.Set("scopes", accessToken.Scopes)
+		} else {
+			claims, err := utils.ValidateToken(token)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+
+			// Reject tokens whose session has been signed out
+			session, err := sessionRepo.FindActiveSession(c.Request.Context(), claims.SessionID)
+			if err != nil || session.UserID.Hex() != claims.UserID {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been signed out"})
+				c.Abort()
+				return
+			}
+
+			userID = claims.UserID
+			c.Set("sessionID", claims.SessionID)
+			if session.ImpersonatorID != nil {
+				c.Set("impersonatorID", session.ImpersonatorID.Hex())
+			}
+		}
+
+		// Disabled accounts lose access immediately
+		user, err := userRepo.FindUserByID(c.Request.Context(), userID)
+		if err != nil || user.Disabled {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled or no longer exists"})
 			c.Abort()
 			return
 		}
 
-		// Store user ID from the token claims in the context
-		c.Set("userID", claims.UserID)
+		// Store user ID and role in the context
+		c.Set("userID", userID)
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("enduser.id", userID))
+		logger := logging.FromContext(c.Request.Context()).With("user_id", userID)
+		c.Request = c.Request.WithContext(logging.WithContext(c.Request.Context(), logger))
+		c.Set("role", user.Role)
+		c.Next()
+	}
+}
+
+// RequireRole rejects users without the given role
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != role {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScope rejects personal access tokens that lack the given scope.
+// Requests authenticated with a JWT have full access.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, ok := c.Get("scopes")
+		if !ok {
+			c.Next()
+			return
+		}
+
+		for _, granted := range scopes.([]string) {
+			if granted == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access token is missing the " + scope + " scope"})
+		c.Abort()
+	}
+}
+
+// DenyAccessTokens rejects requests authenticated with a personal access token,
+// for routes that must only be used interactively (e.g. managing tokens or two-factor settings)
+func DenyAccessTokens() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if UsesAccessToken(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used with an access token"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// UsesAccessToken reports whether the request is authenticated with a personal access token
+func UsesAccessToken(c *gin.Context) bool {
+	_, ok := c.Get("scopes")
+	return ok
+}
+
+// DenyImpersonation rejects requests from admin support sessions, for account security settings
+// that only the user themselves should change
+func DenyImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if GetImpersonatorID(c) != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint cannot be used while impersonating a user"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -50,3 +161,13 @@ func GetUserID(c *gin.Context) (string, error) {
 	}
 	return userID.(string), nil
 }
+
+// GetSessionID extracts the session ID from the context; it is empty for personal access tokens
+func GetSessionID(c *gin.Context) string {
+	return c.GetString("sessionID")
+}
+
+// GetImpersonatorID returns the ID of the admin impersonating the user, or "" for a normal session
+func GetImpersonatorID(c *gin.Context) string {
+	return c.GetString("impersonatorID")
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"server/db/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client's idempotency key
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyTTL is how long a stored response is replayed for
+	IdempotencyTTL = 24 * time.Hour
+	// IdempotencyLease is how long a request holds its key before a retry may take over, in case
+	// the server stops before settling it. It is longer than any request should take.
+	IdempotencyLease = 2 * time.Minute
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored with a response. Others, such as
+// X-Request-ID, describe the first request rather than the response and are set afresh.
+var replayedHeaders = []string{"Content-Type", "Content-Language", "Content-Encoding", "Location", "ETag", "Last-Modified"}
+
+// responseRecorder captures the response body while still writing it to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware replays the first response for requests that repeat an Idempotency-Key header.
+// Requests without the header pass straight through. It must not be used on routes whose
+// responses carry credentials, since stored responses are kept for IdempotencyTTL.
+func IdempotencyMiddleware(repo *repository.IdempotencyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			c.Abort()
+			return
+		}
+
+		// Read the body so it can be hashed, then restore it for the handler
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// Keys are scoped to the authenticated user, if any
+		scope := "anonymous"
+		if userID, err := GetUserID(c); err == nil {
+			scope = userID
+		}
+		id := scope + ":" + key
+		requestHash := hashRequest(c.Request.Method, c.FullPath(), body)
+
+		record, acquired, err := repo.Acquire(c.Request.Context(), id, requestHash, IdempotencyLease)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		if !acquired {
+			switch {
+			case record.RequestHash != requestHash:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+			case !record.Completed:
+				c.Header("Retry-After", "1")
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is already in progress"})
+			default:
+				for name, values := range record.Header {
+					for _, value := range values {
+						c.Writer.Header().Add(name, value)
+					}
+				}
+				c.Header("Idempotent-Replayed", "true")
+				c.Writer.WriteHeader(record.Status)
+				c.Writer.Write(record.Body)
+			}
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// Settle the record even if the handler panics
+		stored := false
+		defer func() {
+			// Use a fresh context so the record is settled even if the client went away
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if !stored {
+				repo.Release(ctx, id)
+			}
+		}()
+
+		c.Next()
+
+		// Server errors are not stored so the client can retry them
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stored = repo.Complete(ctx, id, status, storedHeader(recorder.Header()), recorder.body.Bytes(), IdempotencyTTL) == nil
+	}
+}
+
+// storedHeader returns the headers of a response that are replayed with it
+func storedHeader(header http.Header) http.Header {
+	stored := http.Header{}
+	for _, name := range replayedHeaders {
+		if values := header.Values(name); len(values) > 0 {
+			stored[name] = values
+		}
+	}
+	return stored
+}
+
+// hashRequest fingerprints a request so reused keys with a different payload can be detected
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitStore keeps token buckets for rate limiting.
+// Buckets hold up to limit tokens and refill completely over window.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, limit int, window time.Duration) (allowed bool, remaining int, retryAfter, reset time.Duration, err error)
+}
+
+// RateLimit configures a rate limit for a route group
+type RateLimit struct {
+	Name   string                      // Prefix for bucket keys, so groups don't share buckets
+	Limit  int                         // Bucket capacity (burst size)
+	Window time.Duration               // Time for an empty bucket to refill
+	Key    func(c *gin.Context) string // Returns the bucket key for a request, or "" to skip the limit
+}
+
+// ByIP keys a rate limit by client IP
+func ByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// ByAccount keys a rate limit by the email in the JSON request body
+func ByAccount(c *gin.Context) string {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(payload.Email))
+}
+
+// RateLimitMiddleware rejects requests with 429 once any of the given limits is exhausted
+func RateLimitMiddleware(store RateLimitStore, limits ...RateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, limit := range limits {
+			key := limit.Key(c)
+			if key == "" {
+				continue
+			}
+
+			allowed, remaining, retryAfter, reset, err := store.Take(c.Request.Context(), limit.Name+":"+key, limit.Limit, limit.Window)
+			if err != nil {
+				// Fail open so a store outage doesn't take down authentication
+				continue
+			}
+
+			c.Header("RateLimit-Limit", strconv.Itoa(limit.Limit))
+			c.Header("RateLimit-Remaining", strconv.Itoa(remaining))
+			c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+			if !allowed {
+				c.Set("rateLimited", true)
+				c.Header("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests, please try again later"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RateLimited reports whether the request was rejected by a rate limit
+func RateLimited(c *gin.Context) bool {
+	return c.GetBool("rateLimited")
+}
+
+// ceilSeconds rounds a duration up to whole seconds
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+type bucket struct {
+	tokens    float64
+	capacity  float64
+	rate      float64 // tokens per second
+	updatedAt time.Time
+}
+
+// refill adds the tokens accrued since the last update
+func (b *bucket) refill(now time.Time) float64 {
+	return math.Min(b.capacity, b.tokens+now.Sub(b.updatedAt).Seconds()*b.rate)
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory.
+// It is only suitable for a single instance; use a shared store when running several.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Take removes a token from the bucket for key if one is available
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit int, window time.Duration) (bool, int, time.Duration, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	capacity := float64(limit)
+	rate := capacity / window.Seconds() // tokens per second
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updatedAt: now}
+		s.buckets[key] = b
+	}
+	b.capacity = capacity
+	b.rate = rate
+	b.tokens = b.refill(now)
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	retryAfter := time.Duration(0)
+	if b.tokens < 1 {
+		retryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	reset := time.Duration((capacity - b.tokens) / rate * float64(time.Second))
+
+	// Drop buckets that have refilled completely
+	if now.Sub(s.lastSweep) > window {
+		for k, v := range s.buckets {
+			if v.refill(now) >= v.capacity {
+				delete(s.buckets, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	return allowed, int(b.tokens), retryAfter, reset, nil
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"server/db/models"
+	"server/db/repository"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WorkspaceMiddleware resolves the :workspace_id route parameter, checking the user is a member,
+// and stores the workspace ID and the user's role in the context
+func WorkspaceMiddleware(repo *repository.WorkspaceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("userID"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized access"})
+			c.Abort()
+			return
+		}
+
+		// Non-members can't tell a workspace exists
+		workspaceID, err := primitive.ObjectIDFromHex(c.Param("workspace_id"))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+			c.Abort()
+			return
+		}
+		membership, err := repo.FindMembership(c.Request.Context(), workspaceID, userID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+			c.Abort()
+			return
+		}
+
+		c.Set("workspaceID", workspaceID.Hex())
+		c.Set("workspaceRole", membership.Role)
+		c.Next()
+	}
+}
+
+// PersonalWorkspaceMiddleware scopes unprefixed routes such as /breakdowns to the user's personal workspace
+func PersonalWorkspaceMiddleware(repo *repository.WorkspaceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := primitive.ObjectIDFromHex(c.GetString("userID"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized access"})
+			c.Abort()
+			return
+		}
+
+		workspace, err := repo.EnsurePersonalWorkspace(c.Request.Context(), userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		c.Set("workspaceID", workspace.ID.Hex())
+		c.Set("workspaceRole", models.WorkspaceOwner)
+		c.Next()
+	}
+}
+
+// RequireWorkspaceRole rejects workspace members whose role is below minimum
+func RequireWorkspaceRole(minimum string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !models.WorkspaceRoleAtLeast(GetWorkspaceRole(c), minimum) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient workspace permissions"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetWorkspaceID extracts the current workspace ID from the context
+func GetWorkspaceID(c *gin.Context) (string, error) {
+	workspaceID, exists := c.Get("workspaceID")
+	if !exists {
+		return "", errors.New("workspace ID not found in context")
+	}
+	return workspaceID.(string), nil
+}
+
+// GetWorkspaceRole returns the user's role in the current workspace, or "" outside a workspace
+func GetWorkspaceRole(c *gin.Context) string {
+	return c.GetString("workspaceRole")
+}
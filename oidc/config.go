@@ -0,0 +1,19 @@
+package oidc
+
+import "server/config"
+
+// LoadProviders builds the configured providers, keyed by name
+func LoadProviders(providers map[string]config.OIDCProvider) map[string]*Provider {
+	loaded := make(map[string]*Provider, len(providers))
+	for name, provider := range providers {
+		loaded[name] = NewProvider(Config{
+			Name:         name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+			Scopes:       provider.Scopes,
+		})
+	}
+	return loaded
+}
@@ -0,0 +1,160 @@
+package oidc_test
+
+import (
+	"context"
+	"server/oidc"
+	"server/oidc/oidctest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const clientID = "flow"
+
+// login runs the authorization code flow against mock as far as the provider's redirect, and
+// returns the code with the verifier and nonce the exchange needs
+func login(t *testing.T, provider *oidc.Provider, mock *oidctest.Provider) (code, verifier, nonce string) {
+	t.Helper()
+	state, _ := oidc.RandomString()
+	nonce, _ = oidc.RandomString()
+	verifier, _ = oidc.RandomString()
+
+	authURL, err := provider.AuthCodeURL(context.Background(), state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, returnedState, err := mock.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+	if returnedState != state {
+		t.Fatalf("state = %q, want %q", returnedState, state)
+	}
+	return code, verifier, nonce
+}
+
+func newProvider(mock *oidctest.Provider) *oidc.Provider {
+	return oidc.NewProvider(oidc.Config{
+		Name:        "test",
+		Issuer:      mock.Issuer(),
+		ClientID:    clientID,
+		RedirectURL: "http://localhost/auth/oidc/test/callback",
+	})
+}
+
+func TestExchange(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+	provider := newProvider(mock)
+
+	code, verifier, nonce := login(t, provider, mock)
+	claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "ada@example.com" || !claims.EmailVerified {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestExchangeSendsPKCEVerifier(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+	provider := newProvider(mock)
+
+	code, _, nonce := login(t, provider, mock)
+	other, _ := oidc.RandomString()
+	_, err := provider.Exchange(context.Background(), code, other, nonce)
+	if err == nil || !strings.Contains(err.Error(), "code_verifier does not match") {
+		t.Errorf("Exchange with another verifier = %v, want the provider to reject it", err)
+	}
+}
+
+func TestExchangeRejectsInvalidIDTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		sign   func(mock *oidctest.Provider) func(jwt.MapClaims) (string, error)
+		nonce  string
+		want   string
+	}{
+		{
+			name:  "nonce mismatch",
+			nonce: "another-login",
+			want:  "nonce mismatch",
+		},
+		{
+			name:   "wrong audience",
+			claims: jwt.MapClaims{"aud": "another-client"},
+			want:   "audience",
+		},
+		{
+			name:   "wrong issuer",
+			claims: jwt.MapClaims{"iss": "https://attacker.example.com"},
+			want:   "issuer",
+		},
+		{
+			name:   "expired",
+			claims: jwt.MapClaims{"exp": 1},
+			want:   "expired",
+		},
+		{
+			name:   "missing subject",
+			claims: jwt.MapClaims{"sub": ""},
+			want:   "missing subject",
+		},
+		{
+			name: "HS256 signed with the public key",
+			sign: func(mock *oidctest.Provider) func(jwt.MapClaims) (string, error) {
+				return func(claims jwt.MapClaims) (string, error) {
+					token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+					token.Header["kid"] = oidctest.KeyID
+					return token.SignedString(mock.Key.PublicKey.N.Bytes())
+				}
+			},
+			want: "signing method HS256 is invalid",
+		},
+		{
+			name: "unsigned",
+			sign: func(*oidctest.Provider) func(jwt.MapClaims) (string, error) {
+				return func(claims jwt.MapClaims) (string, error) {
+					return jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				}
+			},
+			want: "signing method none is invalid",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := oidctest.NewProvider(t, clientID)
+			mock.Claims = tt.claims
+			if tt.sign != nil {
+				mock.Sign = tt.sign(mock)
+			}
+			provider := newProvider(mock)
+
+			code, verifier, nonce := login(t, provider, mock)
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Exchange = %+v, %v; want an error containing %q", claims, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchangeReportsUnverifiedEmail(t *testing.T) {
+	mock := oidctest.NewProvider(t, clientID)
+	mock.Claims = jwt.MapClaims{"email_verified": false}
+	provider := newProvider(mock)
+
+	code, verifier, nonce := login(t, provider, mock)
+	claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.EmailVerified {
+		t.Error("EmailVerified = true, want false")
+	}
+}
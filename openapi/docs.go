@@ -0,0 +1,39 @@
+package openapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+)
+
+// docsAssets is where the docs page loads the viewer from, pinned to one release
+const docsAssets = "https://unpkg.com/swagger-ui-dist@5.17.14/"
+
+// DocsPage is an interactive documentation page for the document served at openapi.json
+// alongside it. The viewer is loaded from a CDN. Tokens entered to try requests are kept in the page
+// rather than persisted to localStorage, where any script on the origin could read them.
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// DocsPolicy is the Content-Security-Policy for DocsPage. Scripts and styles may only come from
+// the pinned viewer release and the page's own inline script, so a page that is changed to load
+// anything else, or an injected script, is blocked by the browser. The viewer's own files have no
+// integrity hashes yet, so the policy doesn't protect against the CDN serving altered copies.
+var DocsPolicy = "default-src 'self'; script-src " + docsAssets + " 'sha256-" + inlineScriptHash(DocsPage) + "'; " +
+	"style-src " + docsAssets + " 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; " +
+	"object-src 'none'; base-uri 'none'; frame-ancestors 'none'"
+
+// inlineScriptHash returns the base64 SHA-256 of the first inline script in a page, as CSP expects
+func inlineScriptHash(page []byte) string {
+	const openTag, closeTag = "<script>", "</script>"
+	start := bytes.Index(page, []byte(openTag))
+	if start < 0 {
+		return ""
+	}
+	script := page[start+len(openTag):]
+	script = script[:bytes.Index(script, []byte(closeTag))]
+	sum := sha256.Sum256(script)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
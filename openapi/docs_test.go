@@ -0,0 +1,25 @@
+package openapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDocsPolicyAllowsOnlyThePinnedViewer(t *testing.T) {
+	page := string(DocsPage)
+	for _, tag := range strings.Split(page, "<")[1:] {
+		for _, attribute := range []string{"src=", "href="} {
+			if i := strings.Index(tag, attribute); i >= 0 && !strings.HasPrefix(tag[i+len(attribute)+1:], docsAssets) {
+				t.Errorf("docs page loads <%s from outside %s", tag[:strings.IndexAny(tag, ">\n")], docsAssets)
+			}
+		}
+	}
+
+	script := page[strings.Index(page, "<script>")+len("<script>") : strings.Index(page, "</script>\n</body>")]
+	sum := sha256.Sum256([]byte(script))
+	if !strings.Contains(DocsPolicy, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'") {
+		t.Errorf("DocsPolicy %q doesn't allow the page's inline script", DocsPolicy)
+	}
+}
@@ -0,0 +1,242 @@
+// Package openapi builds the API's OpenAPI 3.1 description from a table of operations. Request
+// and response bodies are described by the Go types the handlers bind and return.
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation describes one route
+type Operation struct {
+	Method  string
+	Path    string // In gin syntax, e.g. /breakdowns/:id
+	Tag     string
+	Summary string
+	Public  bool     // Served without authentication
+	Query   []string // Optional query parameters
+	Status  int      // Success status; defaults to 200
+
+	// Request and Response are values of the types bound from and written to the body, or nil
+	// for none. A nil Response is described as a JSON object; use Binary and Text for other
+	// content types, or Empty for no body.
+	Request  interface{}
+	Response interface{}
+}
+
+// Message is the body of responses that only confirm an action
+type Message struct {
+	Message string `json:"message"`
+}
+
+// Error is the body of every error response
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Binary is a file: uploaded as the multipart form field "file", or downloaded as raw bytes
+type Binary struct{}
+
+// Text is a plain text response
+type Text struct{}
+
+// HTML is an HTML page
+type HTML struct{}
+
+// Empty is a response without a body, such as a redirect
+type Empty struct{}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Document is an OpenAPI 3.1 document
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Tags       []tag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components components                       `json:"components"`
+}
+
+type tag struct {
+	Name string `json:"name"`
+}
+
+type components struct {
+	Schemas         map[string]Schema `json:"schemas"`
+	SecuritySchemes map[string]Schema `json:"securitySchemes"`
+}
+
+type operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *body                 `json:"requestBody,omitempty"`
+	Responses   map[string]*body      `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required,omitempty"`
+	Schema   Schema `json:"schema"`
+}
+
+// body is a request body or a response
+type body struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// bearerAuth names the security scheme for authenticated operations
+const bearerAuth = "bearerAuth"
+
+// Build returns the document describing operations
+func Build(info Info, operations []Operation) *Document {
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]map[string]*operation{},
+		Components: components{
+			SecuritySchemes: map[string]Schema{
+				bearerAuth: {
+					"type":         "http",
+					"scheme":       "bearer",
+					"bearerFormat": "JWT",
+					"description":  "A token from /auth/login or /auth/register, or a personal access token",
+				},
+			},
+		},
+	}
+	errorSchema := s.of(reflect.TypeOf(Error{}))
+
+	tags := map[string]bool{}
+	for _, op := range operations {
+		path := PathOf(op.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*operation{}
+		}
+
+		described := &operation{
+			Summary:     op.Summary,
+			OperationID: operationID(op.Method, path),
+			Responses: map[string]*body{
+				"default": {Description: "Error", Content: jsonContent(errorSchema)},
+			},
+		}
+		if op.Tag != "" {
+			described.Tags = []string{op.Tag}
+			tags[op.Tag] = true
+		}
+		for _, segment := range strings.Split(op.Path, "/") {
+			if strings.HasPrefix(segment, ":") {
+				described.Parameters = append(described.Parameters, parameter{
+					Name: segment[1:], In: "path", Required: true, Schema: Schema{"type": "string"},
+				})
+			}
+		}
+		for _, name := range op.Query {
+			described.Parameters = append(described.Parameters, parameter{Name: name, In: "query", Schema: Schema{"type": "string"}})
+		}
+		if !op.Public {
+			described.Security = []map[string][]string{{bearerAuth: {}}}
+		}
+
+		if op.Request != nil {
+			described.RequestBody = &body{Required: true, Content: requestContent(s, op.Request)}
+		}
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		described.Responses[strconv.Itoa(status)] = &body{
+			Description: http.StatusText(status),
+			Content:     responseContent(s, op.Response),
+		}
+
+		doc.Paths[path][strings.ToLower(op.Method)] = described
+	}
+
+	for name := range tags {
+		doc.Tags = append(doc.Tags, tag{Name: name})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+	doc.Components.Schemas = s.components
+	return doc
+}
+
+// PathOf converts a gin route path to OpenAPI syntax, e.g. /breakdowns/:id to /breakdowns/{id}
+func PathOf(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Has reports whether the document describes the method on the gin route path
+func (d *Document) Has(method, ginPath string) bool {
+	_, ok := d.Paths[PathOf(ginPath)][strings.ToLower(method)]
+	return ok
+}
+
+func requestContent(s *schemas, request interface{}) map[string]mediaType {
+	if _, ok := request.(Binary); ok {
+		return map[string]mediaType{"multipart/form-data": {Schema: Schema{
+			"type":       "object",
+			"properties": map[string]Schema{"file": {"type": "string", "contentMediaType": "application/octet-stream"}},
+			"required":   []string{"file"},
+		}}}
+	}
+	return jsonContent(s.of(reflect.TypeOf(request)))
+}
+
+func responseContent(s *schemas, response interface{}) map[string]mediaType {
+	switch response.(type) {
+	case nil:
+		return jsonContent(Schema{"type": "object"})
+	case Binary:
+		return map[string]mediaType{"application/octet-stream": {Schema: Schema{"type": "string", "contentMediaType": "application/octet-stream"}}}
+	case Text:
+		return map[string]mediaType{"text/plain": {Schema: Schema{"type": "string"}}}
+	case HTML:
+		return map[string]mediaType{"text/html": {Schema: Schema{"type": "string"}}}
+	case Empty:
+		return nil
+	}
+	return jsonContent(s.of(reflect.TypeOf(response)))
+}
+
+func jsonContent(schema Schema) map[string]mediaType {
+	return map[string]mediaType{"application/json": {Schema: schema}}
+}
+
+// operationID derives a unique ID from the method and path, e.g. get_breakdowns_id
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(path, "/") {
+		segment = strings.Trim(segment, "{}")
+		segment = strings.NewReplacer(".", "", "-", "_").Replace(segment)
+		if segment != "" {
+			id += "_" + segment
+		}
+	}
+	return id
+}
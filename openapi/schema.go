@@ -0,0 +1,171 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Schema is a JSON Schema (draft 2020-12, as used by OpenAPI 3.1)
+type Schema map[string]interface{}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// schemas generates JSON Schemas for Go types from their json and binding struct tags. Named
+// structs become shared components referenced by $ref.
+type schemas struct {
+	components map[string]Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]Schema{}, names: map[reflect.Type]string{}}
+}
+
+// of returns the schema for t
+func (s *schemas) of(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case objectIDType:
+		return Schema{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return Schema{"anyOf": []Schema{s.of(t.Elem()), {"type": "null"}}}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "contentEncoding": "base64"}
+		}
+		return Schema{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + s.component(t)}
+	}
+	// interface{} and anything else accepts any JSON value
+	return Schema{}
+}
+
+// component registers a named struct as a component and returns its name. Types with the same
+// name in different packages are qualified with the package name.
+func (s *schemas) component(t reflect.Type) string {
+	if name, ok := s.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := s.components[name]; taken {
+		name = pathBase(t.PkgPath()) + "." + name
+	}
+	s.names[t] = name
+	// Reserve the name before generating fields, so self-referencing types terminate
+	s.components[name] = Schema{}
+	s.components[name] = s.object(t)
+	return name
+}
+
+// object returns the schema for a struct's JSON fields
+func (s *schemas) object(t reflect.Type) Schema {
+	properties := map[string]Schema{}
+	var required []string
+	s.fields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (s *schemas) fields(t reflect.Type, properties map[string]Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+		// Embedded structs without a json name are flattened, as encoding/json does
+		if field.Anonymous && field.Tag.Get("json") == "" && field.Type.Kind() == reflect.Struct {
+			s.fields(field.Type, properties, required)
+			continue
+		}
+
+		schema := s.of(field.Type)
+		if applyBinding(schema, field.Tag.Get("binding")) {
+			*required = append(*required, name)
+		}
+		properties[name] = schema
+	}
+}
+
+// jsonName returns the name a field is encoded under, and whether it is encoded at all
+func jsonName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() && !field.Anonymous {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// applyBinding adds the constraints from a gin binding tag to the schema and reports whether
+// the field is required
+func applyBinding(schema Schema, binding string) (required bool) {
+	if binding == "" {
+		return false
+	}
+	for _, rule := range strings.Split(binding, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "email":
+			schema["format"] = "email"
+		case "min", "max":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				continue
+			}
+			switch schema["type"] {
+			case "string":
+				schema[key+"Length"] = n
+			case "array":
+				schema[key+"Items"] = n
+			default:
+				schema[map[string]string{"min": "minimum", "max": "maximum"}[key]] = n
+			}
+		case "oneof":
+			schema["enum"] = strings.Fields(value)
+		}
+	}
+	return required
+}
+
+func pathBase(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/db/models"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// userID reads the user's ID from a registration response
+func userID(t *testing.T, registered *httptest.ResponseRecorder) primitive.ObjectID {
+	t.Helper()
+	var response struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	decode(t, registered, &response)
+	id, err := primitive.ObjectIDFromHex(response.User.ID)
+	if err != nil {
+		t.Fatalf("registration response has no user ID: %v", err)
+	}
+	return id
+}
+
+func TestForcePasswordResetSparesAdmins(t *testing.T) {
+	router, database := newDatabaseRouter(t, config.Default())
+	admin, otherAdmin, user := registerUser(t, router, "ada"), registerUser(t, router, "grace"), registerUser(t, router, "alan")
+	_, err := database.Collection("users").UpdateMany(context.Background(),
+		bson.M{"_id": bson.M{"$in": []primitive.ObjectID{userID(t, admin), userID(t, otherAdmin)}}},
+		bson.M{"$set": bson.M{"role": models.RoleAdmin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target *httptest.ResponseRecorder
+		want   int
+	}{
+		{"self", admin, http.StatusBadRequest},
+		{"another admin", otherAdmin, http.StatusForbidden},
+		{"user", user, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, authorizedRequest(t, admin, http.MethodPost, "/admin/users/"+userID(t, tt.target).Hex()+"/reset-password", ""))
+			if recorder.Code != tt.want {
+				t.Errorf("resetting %s = %d %s, want %d", tt.name, recorder.Code, recorder.Body.String(), tt.want)
+			}
+		})
+	}
+
+	// Only the user's password was invalidated; both admins can still sign in
+	for email, want := range map[string]int{"ada@example.com": http.StatusOK, "grace@example.com": http.StatusOK, "alan@example.com": http.StatusUnauthorized} {
+		request := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(`{"email":"`+email+`","password":"correct horse"}`))
+		request.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if recorder.Code != want {
+			t.Errorf("login as %s = %d, want %d", email, recorder.Code, want)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIdempotentCreateReplaysResponse(t *testing.T) {
+	router, database := newDatabaseRouter(t, config.Default())
+
+	register := httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email":"ada@example.com","username":"ada","password":"correct horse"}`))
+	register.Header.Set("Content-Type", "application/json")
+	register.Header.Set("Idempotency-Key", "register-once")
+	registered := httptest.NewRecorder()
+	router.ServeHTTP(registered, register)
+	if registered.Code != http.StatusCreated {
+		t.Fatalf("POST /auth/register = %d %s", registered.Code, registered.Body.String())
+	}
+	// Registration returns a token, which must never be stored for replay
+	if count, err := database.Collection("idempotency_keys").CountDocuments(context.Background(), bson.M{}); err != nil || count != 0 {
+		t.Errorf("stored idempotency records after registering = %d, %v; want none", count, err)
+	}
+
+	create := func(requestID string) *httptest.ResponseRecorder {
+		request := authorizedRequest(t, registered, http.MethodPost, "/breakdowns", `{"name":"Launch"}`)
+		request.Header.Set("Idempotency-Key", "create-launch")
+		request.Header.Set("X-Request-ID", requestID)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	first, replay := create("first-attempt"), create("second-attempt")
+	if first.Code != http.StatusCreated || replay.Code != http.StatusCreated || replay.Body.String() != first.Body.String() {
+		t.Fatalf("create = %d %s, retry = %d %s; want the first response replayed", first.Code, first.Body.String(), replay.Code, replay.Body.String())
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("replayed headers = %v", replay.Header())
+	}
+	if ids := replay.Header().Values("X-Request-ID"); len(ids) != 1 || ids[0] != "second-attempt" {
+		t.Errorf("replayed X-Request-ID = %q, want only the retry's own ID", ids)
+	}
+}
@@ -0,0 +1,181 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"server/config"
+	"server/db"
+	"server/db/migrations"
+	"server/db/models"
+	"server/db/repository"
+	"server/health"
+	"server/mail"
+	"server/oidc/oidctest"
+	"server/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newDatabaseRouter builds the router on a throwaway database on the MongoDB server at
+// TEST_MONGO_URI. Tests using it are skipped when the variable isn't set.
+func newDatabaseRouter(t *testing.T, cfg *config.Config) (*gin.Engine, *mongo.Database) {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+
+	cfg.Mongo.URI = uri
+	cfg.Mongo.Database = "flow_router_test_" + primitive.NewObjectID().Hex()
+	cfg.Auth.JWTAlgorithm = "HS256"
+	cfg.Auth.JWTSecret = "router-test-secret-router-test-secret"
+	utils.ConfigureTokens(nil, cfg.Auth.JWTSecret, cfg.Auth.Issuer, cfg.Auth.Audience)
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	client, err := db.Connect(ctx, cfg.Mongo)
+	if err != nil {
+		t.Fatalf("connecting to MongoDB: %v", err)
+	}
+	database := client.Database(cfg.Mongo.Database)
+	t.Cleanup(func() {
+		database.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	if _, err := migrations.New(database).Up(ctx); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	return New(Dependencies{
+		Config:   cfg,
+		Database: database,
+		Mailer:   mail.LogMailer{},
+		Health:   health.NewRegistry(),
+	}), database
+}
+
+// oidcLogin signs in through the mock provider as a browser would, and returns the callback response
+func oidcLogin(t *testing.T, router *gin.Engine, provider *oidctest.Provider) *httptest.ResponseRecorder {
+	t.Helper()
+	start := httptest.NewRecorder()
+	router.ServeHTTP(start, httptest.NewRequest(http.MethodGet, "/auth/oidc/test/login", nil))
+	if start.Code != http.StatusFound {
+		t.Fatalf("GET /auth/oidc/test/login = %d %s", start.Code, start.Body.String())
+	}
+
+	code, state, err := provider.Authorize(start.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorizing: %v", err)
+	}
+
+	callback := httptest.NewRequest(http.MethodGet, "/auth/oidc/test/callback?code="+code+"&state="+state, nil)
+	for _, cookie := range start.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, callback)
+	return recorder
+}
+
+func TestOIDCLinksOnlyVerifiedEmails(t *testing.T) {
+	provider := oidctest.NewProvider(t, "flow")
+	cfg := config.Default()
+	cfg.OIDC["test"] = config.OIDCProvider{Issuer: provider.Issuer(), ClientID: "flow", RedirectURL: "http://localhost/auth/oidc/test/callback"}
+	router, database := newDatabaseRouter(t, cfg)
+
+	users := repository.NewUserRepository(database)
+	existing := &models.User{Username: "ada", Email: "ada@example.com", Password: "correct horse battery staple"}
+	if err := users.CreateUser(context.Background(), existing); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	provider.Claims = jwt.MapClaims{"email": existing.Email, "email_verified": false}
+	if recorder := oidcLogin(t, router, provider); recorder.Code != http.StatusForbidden {
+		t.Errorf("login with an unverified email = %d %s, want 403", recorder.Code, recorder.Body.String())
+	}
+	if _, err := users.FindUserByIdentity(context.Background(), "test", "subject-1"); err == nil {
+		t.Error("identity was linked to the account with an unverified email")
+	}
+
+	provider.Claims = jwt.MapClaims{"email": existing.Email, "email_verified": true}
+	if recorder := oidcLogin(t, router, provider); recorder.Code != http.StatusOK {
+		t.Errorf("login with a verified email = %d %s, want 200", recorder.Code, recorder.Body.String())
+	}
+	linked, err := users.FindUserByIdentity(context.Background(), "test", "subject-1")
+	if err != nil || linked.ID != existing.ID {
+		t.Errorf("FindUserByIdentity = %v, %v; want the existing account", linked, err)
+	}
+}
+
+// authorizedRequest returns a JSON request carrying the token from a login response
+func authorizedRequest(t *testing.T, login *httptest.ResponseRecorder, method, path, body string) *http.Request {
+	t.Helper()
+	var response struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(login.Body.Bytes(), &response); err != nil || response.Token == "" {
+		t.Fatalf("login response %s has no token", login.Body.String())
+	}
+	request := httptest.NewRequest(method, path, strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+response.Token)
+	request.Header.Set("Content-Type", "application/json")
+	return request
+}
+
+func TestOIDCAccountsReauthenticateWithRecentLogin(t *testing.T) {
+	provider := oidctest.NewProvider(t, "flow")
+	cfg := config.Default()
+	cfg.OIDC["test"] = config.OIDCProvider{Issuer: provider.Issuer(), ClientID: "flow", RedirectURL: "http://localhost/auth/oidc/test/callback"}
+	router, database := newDatabaseRouter(t, cfg)
+	provider.Claims = jwt.MapClaims{"email": "grace@example.com", "email_verified": true}
+
+	login := oidcLogin(t, router, provider)
+	if login.Code != http.StatusOK {
+		t.Fatalf("OIDC login = %d %s", login.Code, login.Body.String())
+	}
+
+	// A wrong password is still rejected rather than falling back to the session
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, authorizedRequest(t, login, http.MethodPost, "/profile/password", `{"current_password":"guess","new_password":"a new password"}`))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("changing the password with a wrong one = %d, want 401", recorder.Code)
+	}
+
+	// Without one, the recent provider sign in lets the account set a password and delete itself
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, authorizedRequest(t, login, http.MethodPost, "/profile/password", `{"new_password":"a new password"}`))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("setting a password after an OIDC login = %d %s, want 200", recorder.Code, recorder.Body.String())
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, authorizedRequest(t, login, http.MethodDelete, "/profile", `{}`))
+	if recorder.Code != http.StatusAccepted {
+		t.Errorf("deleting the account after an OIDC login = %d %s, want 202", recorder.Code, recorder.Body.String())
+	}
+
+	// Once the sign in is no longer recent the password is needed again
+	sessions := database.Collection("sessions")
+	if _, err := sessions.UpdateMany(context.Background(), bson.M{}, bson.M{"$set": bson.M{"created_at": time.Now().Add(-time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, authorizedRequest(t, login, http.MethodDelete, "/profile", `{}`))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("deleting the account an hour after an OIDC login = %d, want 401", recorder.Code)
+	}
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, authorizedRequest(t, login, http.MethodDelete, "/profile", `{"password":"a new password"}`))
+	if recorder.Code != http.StatusAccepted {
+		t.Errorf("deleting the account with the new password = %d %s, want 202", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,120 @@
+package router
+
+import (
+	"net/http"
+	"server/db/models"
+	"server/handlers"
+	"server/health"
+	"server/openapi"
+)
+
+// operations describes every route registered in New. TestEveryRouteIsDescribed fails when the
+// two drift apart.
+func operations() []openapi.Operation {
+	ops := []openapi.Operation{
+		// Operations
+		{Method: http.MethodGet, Path: "/health", Tag: "Operations", Summary: "Original health check; like /health/live it doesn't check dependencies", Public: true},
+		{Method: http.MethodGet, Path: "/health/live", Tag: "Operations", Summary: "Liveness probe with build info", Public: true},
+		{Method: http.MethodGet, Path: "/health/ready", Tag: "Operations", Summary: "Readiness probe with dependency checks; 503 when failing or shutting down", Public: true, Response: health.Report{}},
+		{Method: http.MethodGet, Path: "/metrics", Tag: "Operations", Summary: "Prometheus metrics (served on METRICS_ADDR instead when set)", Public: true, Response: openapi.Text{}},
+		{Method: http.MethodGet, Path: "/.well-known/jwks.json", Tag: "Operations", Summary: "Public keys for verifying tokens", Public: true},
+		{Method: http.MethodGet, Path: "/openapi.json", Tag: "Operations", Summary: "This document", Public: true},
+		{Method: http.MethodGet, Path: "/docs", Tag: "Operations", Summary: "Interactive API documentation", Public: true, Response: openapi.HTML{}},
+
+		// Authentication
+		{Method: http.MethodPost, Path: "/auth/register", Tag: "Authentication", Summary: "Create an account and sign in", Public: true, Request: handlers.RegisterRequest{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/auth/login", Tag: "Authentication", Summary: "Sign in; returns a token, or a challenge token when two-factor authentication is on", Public: true, Request: handlers.LoginRequest{}},
+		{Method: http.MethodPost, Path: "/auth/login/2fa", Tag: "Authentication", Summary: "Complete a two-factor login", Public: true, Request: handlers.TwoFactorLoginRequest{}},
+		{Method: http.MethodPost, Path: "/auth/verify-email", Tag: "Authentication", Summary: "Confirm an email change", Public: true, Request: handlers.VerifyEmailRequest{}},
+		{Method: http.MethodPost, Path: "/auth/reset-password", Tag: "Authentication", Summary: "Choose a new password with a reset token", Public: true, Request: handlers.ResetPasswordRequest{}, Response: openapi.Message{}},
+		{Method: http.MethodGet, Path: "/auth/oidc", Tag: "Authentication", Summary: "List the configured identity providers", Public: true},
+		{Method: http.MethodGet, Path: "/auth/oidc/:provider/login", Tag: "Authentication", Summary: "Redirect to an identity provider", Public: true, Status: http.StatusFound, Response: openapi.Empty{}},
+		{Method: http.MethodGet, Path: "/auth/oidc/:provider/callback", Tag: "Authentication", Summary: "Complete a login with an identity provider", Public: true, Query: []string{"code", "state", "error", "error_description"}},
+
+		// Profile
+		{Method: http.MethodGet, Path: "/profile", Tag: "Profile", Summary: "Get the signed-in user's profile"},
+		{Method: http.MethodPatch, Path: "/profile", Tag: "Profile", Summary: "Update the username, display name or time zone", Request: handlers.UpdateProfileRequest{}},
+		{Method: http.MethodDelete, Path: "/profile", Tag: "Profile", Summary: "Schedule the account for deletion", Request: handlers.DeleteAccountRequest{}, Status: http.StatusAccepted},
+		{Method: http.MethodPost, Path: "/profile/deletion/cancel", Tag: "Profile", Summary: "Cancel a scheduled account deletion", Response: openapi.Message{}},
+		{Method: http.MethodPost, Path: "/profile/password", Tag: "Profile", Summary: "Change the password", Request: handlers.ChangePasswordRequest{}, Response: openapi.Message{}},
+		{Method: http.MethodPost, Path: "/profile/email", Tag: "Profile", Summary: "Start an email change by sending a verification link", Request: handlers.ChangeEmailRequest{}, Status: http.StatusAccepted, Response: openapi.Message{}},
+		{Method: http.MethodPost, Path: "/profile/2fa/enroll", Tag: "Profile", Summary: "Start two-factor enrollment"},
+		{Method: http.MethodPost, Path: "/profile/2fa/confirm", Tag: "Profile", Summary: "Confirm two-factor enrollment and get recovery codes", Request: handlers.TwoFactorCodeRequest{}},
+		{Method: http.MethodPost, Path: "/profile/2fa/disable", Tag: "Profile", Summary: "Turn off two-factor authentication", Request: handlers.DisableTwoFactorRequest{}},
+		{Method: http.MethodGet, Path: "/profile/audit", Tag: "Profile", Summary: "Audit events for the signed-in user's account", Query: []string{"action", "target_id", "from", "to", "limit", "cursor"}},
+
+		// Sessions and tokens
+		{Method: http.MethodGet, Path: "/sessions", Tag: "Sessions", Summary: "List signed-in devices"},
+		{Method: http.MethodDelete, Path: "/sessions/:id", Tag: "Sessions", Summary: "Sign out a device", Response: openapi.Message{}},
+		{Method: http.MethodGet, Path: "/tokens", Tag: "Sessions", Summary: "List personal access tokens", Response: []models.AccessToken{}},
+		{Method: http.MethodPost, Path: "/tokens", Tag: "Sessions", Summary: "Create a personal access token; the token is only returned once", Request: handlers.AccessTokenRequest{}, Status: http.StatusCreated},
+		{Method: http.MethodDelete, Path: "/tokens/:id", Tag: "Sessions", Summary: "Revoke a personal access token", Response: openapi.Message{}},
+
+		// Activity and notifications
+		{Method: http.MethodGet, Path: "/activity", Tag: "Activity", Summary: "Activity across the user's workspaces, newest first by when each entry started", Query: []string{"type", "actor_id", "cursor", "limit"}},
+		{Method: http.MethodGet, Path: "/notifications", Tag: "Notifications", Summary: "List notifications", Query: []string{"unread", "limit", "offset"}, Response: []models.Notification{}},
+		{Method: http.MethodPost, Path: "/notifications/read", Tag: "Notifications", Summary: "Mark all notifications read", Response: openapi.Message{}},
+		{Method: http.MethodPost, Path: "/notifications/:id/read", Tag: "Notifications", Summary: "Mark a notification read", Response: openapi.Message{}},
+
+		// Workspaces
+		{Method: http.MethodGet, Path: "/workspaces", Tag: "Workspaces", Summary: "List the user's workspaces with their role"},
+		{Method: http.MethodPost, Path: "/workspaces", Tag: "Workspaces", Summary: "Create a shared workspace", Request: handlers.WorkspaceRequest{}, Status: http.StatusCreated},
+		{Method: http.MethodPost, Path: "/invitations/accept", Tag: "Workspaces", Summary: "Join a workspace with an invitation token", Request: handlers.AcceptInvitationRequest{}},
+		{Method: http.MethodGet, Path: "/workspaces/:workspace_id", Tag: "Workspaces", Summary: "Get a workspace"},
+		{Method: http.MethodPatch, Path: "/workspaces/:workspace_id", Tag: "Workspaces", Summary: "Rename a workspace (admins)", Request: handlers.WorkspaceRequest{}},
+		{Method: http.MethodDelete, Path: "/workspaces/:workspace_id", Tag: "Workspaces", Summary: "Delete a workspace and its breakdowns (owner)", Response: openapi.Message{}},
+		{Method: http.MethodGet, Path: "/workspaces/:workspace_id/members", Tag: "Workspaces", Summary: "List members; emails are only included for members and above, and not for access tokens"},
+		{Method: http.MethodPatch, Path: "/workspaces/:workspace_id/members/:user_id", Tag: "Workspaces", Summary: "Change a member's role (admins)", Request: handlers.MemberRoleRequest{}},
+		{Method: http.MethodDelete, Path: "/workspaces/:workspace_id/members/:user_id", Tag: "Workspaces", Summary: "Remove a member, or leave the workspace", Response: openapi.Message{}},
+		{Method: http.MethodGet, Path: "/workspaces/:workspace_id/invitations", Tag: "Workspaces", Summary: "List pending invitations (admins)", Response: []models.Invitation{}},
+		{Method: http.MethodPost, Path: "/workspaces/:workspace_id/invitations", Tag: "Workspaces", Summary: "Invite someone by email (admins)", Request: handlers.InvitationRequest{}, Status: http.StatusCreated, Response: models.Invitation{}},
+		{Method: http.MethodDelete, Path: "/workspaces/:workspace_id/invitations/:invitation_id", Tag: "Workspaces", Summary: "Revoke an invitation (admins)", Response: openapi.Message{}},
+
+		// Transfers
+		{Method: http.MethodGet, Path: "/transfers", Tag: "Transfers", Summary: "List transfers offered to the user", Response: []models.Transfer{}},
+		{Method: http.MethodPost, Path: "/transfers/:id/accept", Tag: "Transfers", Summary: "Accept a transfer; breakdowns from a shared workspace stay there", Response: models.Transfer{}},
+		{Method: http.MethodPost, Path: "/transfers/:id/decline", Tag: "Transfers", Summary: "Decline a transfer", Response: models.Transfer{}},
+
+		// Administration
+		{Method: http.MethodGet, Path: "/audit", Tag: "Administration", Summary: "Search all audit events", Query: []string{"user_id", "actor_id", "action", "target_id", "from", "to", "limit", "cursor"}},
+		{Method: http.MethodGet, Path: "/admin/users", Tag: "Administration", Summary: "Search users", Query: []string{"q", "limit", "offset"}},
+		{Method: http.MethodGet, Path: "/admin/users/:id", Tag: "Administration", Summary: "Get a user"},
+		{Method: http.MethodPost, Path: "/admin/users/:id/disable", Tag: "Administration", Summary: "Disable a user and sign them out everywhere"},
+		{Method: http.MethodPost, Path: "/admin/users/:id/enable", Tag: "Administration", Summary: "Re-enable a user"},
+		{Method: http.MethodPost, Path: "/admin/users/:id/reset-password", Tag: "Administration", Summary: "Invalidate a user's password and email them a reset link; not for admins or yourself", Response: openapi.Message{}},
+		{Method: http.MethodPost, Path: "/admin/users/:id/impersonate", Tag: "Administration", Summary: "Get a short-lived token to act as a user", Request: handlers.ImpersonateRequest{}},
+		{Method: http.MethodGet, Path: "/admin/stats", Tag: "Administration", Summary: "Counts of users, breakdowns and sessions"},
+	}
+
+	// Breakdown routes are served for the personal workspace and for each shared workspace
+	ops = append(ops, breakdownOperations("")...)
+	ops = append(ops, breakdownOperations("/workspaces/:workspace_id")...)
+	return ops
+}
+
+func breakdownOperations(prefix string) []openapi.Operation {
+	where := "in the personal workspace"
+	if prefix != "" {
+		where = "in a workspace"
+	}
+	return []openapi.Operation{
+		{Method: http.MethodGet, Path: prefix + "/breakdowns", Tag: "Breakdowns", Summary: "List breakdowns " + where, Response: []models.Breakdown{}},
+		{Method: http.MethodPost, Path: prefix + "/breakdowns", Tag: "Breakdowns", Summary: "Create a breakdown " + where + "; accepts an Idempotency-Key header", Request: handlers.BreakdownRequest{}, Status: http.StatusCreated, Response: models.Breakdown{}},
+		{Method: http.MethodGet, Path: prefix + "/breakdowns/:id", Tag: "Breakdowns", Summary: "Get a breakdown " + where, Response: models.Breakdown{}},
+		{Method: http.MethodPut, Path: prefix + "/breakdowns/:id", Tag: "Breakdowns", Summary: "Update a breakdown " + where, Request: handlers.BreakdownRequest{}, Response: models.Breakdown{}},
+		{Method: http.MethodDelete, Path: prefix + "/breakdowns/:id", Tag: "Breakdowns", Summary: "Delete a breakdown " + where, Response: openapi.Message{}},
+		{Method: http.MethodPost, Path: prefix + "/breakdowns/:id/duplicate", Tag: "Breakdowns", Summary: "Copy a breakdown " + where, Request: handlers.DuplicateRequest{}, Status: http.StatusCreated, Response: models.Breakdown{}},
+		{Method: http.MethodPost, Path: prefix + "/breakdowns/:id/transfer", Tag: "Transfers", Summary: "Offer a breakdown " + where + " to another user", Request: handlers.TransferRequest{}, Status: http.StatusCreated, Response: models.Transfer{}},
+		{Method: http.MethodGet, Path: prefix + "/breakdowns/:id/activity", Tag: "Activity", Summary: "Activity on a breakdown " + where + ", newest first by when each entry started", Query: []string{"type", "actor_id", "cursor", "limit"}},
+
+		{Method: http.MethodGet, Path: prefix + "/breakdowns/:id/comments", Tag: "Comments", Summary: "List comment threads on a breakdown " + where},
+		{Method: http.MethodPost, Path: prefix + "/breakdowns/:id/comments", Tag: "Comments", Summary: "Comment on a breakdown " + where, Request: handlers.CommentRequest{}, Status: http.StatusCreated},
+		{Method: http.MethodPatch, Path: prefix + "/breakdowns/:id/comments/:comment_id", Tag: "Comments", Summary: "Edit your comment on a breakdown " + where, Request: handlers.UpdateCommentRequest{}},
+		{Method: http.MethodDelete, Path: prefix + "/breakdowns/:id/comments/:comment_id", Tag: "Comments", Summary: "Delete a comment on a breakdown " + where, Response: openapi.Message{}},
+
+		{Method: http.MethodGet, Path: prefix + "/breakdowns/:id/attachments", Tag: "Attachments", Summary: "List attachments on a breakdown " + where, Response: []models.Attachment{}},
+		{Method: http.MethodPost, Path: prefix + "/breakdowns/:id/attachments", Tag: "Attachments", Summary: "Upload an attachment to a breakdown " + where, Request: openapi.Binary{}, Status: http.StatusCreated, Response: models.Attachment{}},
+		{Method: http.MethodGet, Path: prefix + "/breakdowns/:id/attachments/:attachment_id", Tag: "Attachments", Summary: "Download an attachment from a breakdown " + where, Response: openapi.Binary{}},
+		{Method: http.MethodDelete, Path: prefix + "/breakdowns/:id/attachments/:attachment_id", Tag: "Attachments", Summary: "Delete an attachment from a breakdown " + where, Response: openapi.Message{}},
+	}
+}
@@ -0,0 +1,262 @@
+package router
+
+import (
+	"log/slog"
+	"net/http"
+	"server/config"
+	"server/db/models"
+	"server/db/repository"
+	"server/handlers"
+	"server/health"
+	"server/mail"
+	"server/metrics"
+	"server/middleware"
+	"server/oidc"
+	"server/openapi"
+	"server/storage"
+	"server/utils"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Dependencies are the services the API is built on
+type Dependencies struct {
+	Config     *config.Config
+	Database   *mongo.Database
+	Mailer     mail.Mailer
+	BlobStore  storage.BlobStore
+	KeyManager *utils.KeyManager // nil when tokens are signed with a shared secret
+	Health     *health.Registry
+}
+
+// New returns the API router with every route registered
+func New(deps Dependencies) *gin.Engine {
+	// Initialize repositories
+	breakdownRepo := repository.NewBreakdownRepository(deps.Database)
+	userRepo := repository.NewUserRepository(deps.Database)
+	transferRepo := repository.NewTransferRepository(deps.Database)
+	activityRepo := repository.NewActivityRepository(deps.Database)
+	idempotencyRepo := repository.NewIdempotencyRepository(deps.Database)
+	accessTokenRepo := repository.NewAccessTokenRepository(deps.Database)
+	oidcStateRepo := repository.NewOIDCStateRepository(deps.Database)
+	sessionRepo := repository.NewSessionRepository(deps.Database)
+	auditRepo := repository.NewAuditRepository(deps.Database)
+	workspaceRepo := repository.NewWorkspaceRepository(deps.Database)
+	invitationRepo := repository.NewInvitationRepository(deps.Database)
+	commentRepo := repository.NewCommentRepository(deps.Database)
+	notificationRepo := repository.NewNotificationRepository(deps.Database)
+	attachmentRepo := repository.NewAttachmentRepository(deps.Database)
+
+	// Initialize handlers
+	breakdownHandler := handlers.NewBreakdownHandler(breakdownRepo, commentRepo, activityRepo, auditRepo)
+	authHandler := handlers.NewAuthHandler(userRepo, sessionRepo, deps.Mailer, auditRepo)
+	transferHandler := handlers.NewTransferHandler(transferRepo, breakdownRepo, userRepo, activityRepo, workspaceRepo, auditRepo)
+	accessTokenHandler := handlers.NewAccessTokenHandler(accessTokenRepo, auditRepo)
+	oidcHandler := handlers.NewOIDCHandler(authHandler, oidc.LoadProviders(deps.Config.OIDC), oidcStateRepo)
+	sessionHandler := handlers.NewSessionHandler(sessionRepo, auditRepo)
+	adminHandler := handlers.NewAdminHandler(userRepo, breakdownRepo, sessionRepo, deps.Mailer, auditRepo)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	commentHandler := handlers.NewCommentHandler(commentRepo, breakdownRepo, userRepo, workspaceRepo, notificationRepo)
+	notificationHandler := handlers.NewNotificationHandler(notificationRepo)
+	activityHandler := handlers.NewActivityHandler(activityRepo, breakdownRepo, workspaceRepo, userRepo)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentRepo, breakdownRepo, deps.BlobStore, deps.Config.Attachments.MaxBytes, auditRepo)
+	workspaceHandler := handlers.NewWorkspaceHandler(workspaceRepo, invitationRepo, userRepo, breakdownRepo, commentRepo, deps.Mailer, auditRepo)
+	healthHandler := handlers.NewHealthHandler(deps.Health)
+
+	// Rate limits are kept in memory unless a shared store is requested for multi-instance deployments
+	var rateLimitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if deps.Config.RateLimit.Store == "mongo" {
+		rateLimitStore = repository.NewRateLimitRepository(deps.Database)
+	}
+	limits := deps.Config.RateLimit
+	authRateLimit := middleware.RateLimitMiddleware(rateLimitStore,
+		middleware.RateLimit{Name: "auth-ip", Limit: limits.AuthIPLimit, Window: limits.AuthWindow.Duration, Key: middleware.ByIP},
+		middleware.RateLimit{Name: "auth-account", Limit: limits.AuthAccountLimit, Window: limits.AuthWindow.Duration, Key: middleware.ByAccount},
+	)
+	apiRateLimit := middleware.RateLimitMiddleware(rateLimitStore,
+		middleware.RateLimit{Name: "api-ip", Limit: limits.APIIPLimit, Window: limits.APIWindow.Duration, Key: middleware.ByIP},
+	)
+
+	// Create a Gin router instance. Forwarding headers are only believed from the configured proxies,
+	// so clients can't pick their own IP to get around per-IP rate limits.
+	router := gin.New()
+	if err := router.SetTrustedProxies(deps.Config.Server.TrustedProxies); err != nil {
+		// Validation rejects malformed entries; trust no proxy rather than part of the list
+		slog.Error("Ignoring trusted proxies", "error", err)
+		router.SetTrustedProxies(nil)
+	}
+
+	// Requests are measured and traced first, then logged with their request ID; panics become 500 responses
+	router.Use(metrics.Middleware())
+	router.Use(otelgin.Middleware(deps.Config.Tracing.ServiceName))
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.LoggingMiddleware())
+	router.Use(middleware.RecoveryMiddleware())
+
+	// Public routes
+	router.GET("/health", healthHandler.Health)
+	router.GET("/health/live", healthHandler.Live)
+	router.GET("/health/ready", healthHandler.Ready)
+	if deps.Config.Metrics.Addr == "" {
+		router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+	router.GET("/.well-known/jwks.json", handlers.JWKSHandler(deps.KeyManager))
+
+	// API description and its interactive docs page
+	spec := openapi.Build(openapi.Info{
+		Title:       "flow API",
+		Version:     health.Build().Version,
+		Description: "Break goals down into steps, alone or in shared workspaces.",
+	}, operations())
+	router.GET("/openapi.json", func(c *gin.Context) { c.JSON(http.StatusOK, spec) })
+	router.GET("/docs", func(c *gin.Context) {
+		c.Header("Content-Security-Policy", openapi.DocsPolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+	})
+
+	// Authentication routes are rate limited per IP and per account
+	auth := router.Group("/auth")
+	auth.Use(countRateLimitedLogins, authRateLimit)
+	{
+		auth.POST("/register", authHandler.Register)
+		auth.POST("/login", authHandler.Login)
+		auth.POST("/login/2fa", authHandler.LoginTwoFactor)
+		auth.POST("/verify-email", authHandler.VerifyEmail)
+		auth.POST("/reset-password", authHandler.ResetPassword)
+
+		// OpenID Connect login
+		auth.GET("/oidc", oidcHandler.GetProviders)
+		auth.GET("/oidc/:provider/login", oidcHandler.StartLogin)
+		auth.GET("/oidc/:provider/callback", oidcHandler.Callback)
+	}
+
+	// Create an authenticated group
+	authenticated := router.Group("/")
+	authenticated.Use(apiRateLimit, middleware.AuthMiddleware(accessTokenRepo, sessionRepo, userRepo))
+	{
+		// Scopes required when authenticating with a personal access token
+		readProfile := middleware.RequireScope(models.ScopeProfileRead)
+		readBreakdowns := middleware.RequireScope(models.ScopeBreakdownsRead)
+		writeBreakdowns := middleware.RequireScope(models.ScopeBreakdownsWrite)
+		interactiveOnly := middleware.DenyAccessTokens()
+		ownerOnly := middleware.DenyImpersonation()
+
+		// User routes
+		authenticated.GET("/profile", readProfile, authHandler.GetProfile)
+		authenticated.PATCH("/profile", interactiveOnly, ownerOnly, authHandler.UpdateProfile)
+		authenticated.DELETE("/profile", interactiveOnly, ownerOnly, authHandler.DeleteAccount)
+		authenticated.POST("/profile/deletion/cancel", interactiveOnly, ownerOnly, authHandler.CancelAccountDeletion)
+		authenticated.POST("/profile/password", interactiveOnly, ownerOnly, authHandler.ChangePassword)
+		authenticated.POST("/profile/email", interactiveOnly, ownerOnly, authHandler.ChangeEmail)
+		authenticated.POST("/profile/2fa/enroll", interactiveOnly, ownerOnly, authHandler.EnrollTwoFactor)
+		authenticated.POST("/profile/2fa/confirm", interactiveOnly, ownerOnly, authHandler.ConfirmTwoFactor)
+		authenticated.POST("/profile/2fa/disable", interactiveOnly, ownerOnly, authHandler.DisableTwoFactor)
+
+		// Audit routes: admins see everything, users see their own account's events
+		authenticated.GET("/audit", interactiveOnly, middleware.RequireRole(models.RoleAdmin), auditHandler.GetAuditEvents)
+		authenticated.GET("/profile/audit", interactiveOnly, auditHandler.GetMyAuditEvents)
+
+		// Session routes
+		authenticated.GET("/sessions", interactiveOnly, sessionHandler.GetSessions)
+		authenticated.DELETE("/sessions/:id", interactiveOnly, ownerOnly, sessionHandler.RevokeSession)
+
+		// Personal access token routes
+		authenticated.GET("/tokens", interactiveOnly, accessTokenHandler.GetAccessTokens)
+		authenticated.POST("/tokens", interactiveOnly, ownerOnly, accessTokenHandler.CreateAccessToken)
+		authenticated.DELETE("/tokens/:id", interactiveOnly, accessTokenHandler.RevokeAccessToken)
+
+		// Breakdown routes are served both for the personal workspace and scoped to a workspace.
+		// Guests can read, members and above can make changes.
+		breakdownRoutes := func(group *gin.RouterGroup) {
+			canEdit := middleware.RequireWorkspaceRole(models.WorkspaceMember)
+			group.GET("/breakdowns", readBreakdowns, breakdownHandler.GetBreakdowns)
+			group.GET("/breakdowns/:id", readBreakdowns, breakdownHandler.GetBreakdownByID)
+			group.POST("/breakdowns", writeBreakdowns, canEdit, middleware.IdempotencyMiddleware(idempotencyRepo), breakdownHandler.CreateBreakdown)
+			group.PUT("/breakdowns/:id", writeBreakdowns, canEdit, breakdownHandler.UpdateBreakdown)
+			group.DELETE("/breakdowns/:id", writeBreakdowns, canEdit, breakdownHandler.DeleteBreakdown)
+			group.POST("/breakdowns/:id/duplicate", writeBreakdowns, canEdit, breakdownHandler.DuplicateBreakdown)
+			group.POST("/breakdowns/:id/transfer", writeBreakdowns, canEdit, transferHandler.CreateTransfer)
+
+			group.GET("/breakdowns/:id/activity", readBreakdowns, activityHandler.GetBreakdownActivity)
+
+			// Anyone who can see a breakdown can join the discussion
+			group.GET("/breakdowns/:id/comments", readBreakdowns, commentHandler.GetComments)
+			group.POST("/breakdowns/:id/comments", writeBreakdowns, commentHandler.CreateComment)
+			group.PATCH("/breakdowns/:id/comments/:comment_id", writeBreakdowns, commentHandler.UpdateComment)
+			group.DELETE("/breakdowns/:id/comments/:comment_id", writeBreakdowns, commentHandler.DeleteComment)
+
+			// Attachments
+			group.GET("/breakdowns/:id/attachments", readBreakdowns, attachmentHandler.GetAttachments)
+			group.GET("/breakdowns/:id/attachments/:attachment_id", readBreakdowns, attachmentHandler.DownloadAttachment)
+			group.POST("/breakdowns/:id/attachments", writeBreakdowns, canEdit, attachmentHandler.UploadAttachment)
+			group.DELETE("/breakdowns/:id/attachments/:attachment_id", writeBreakdowns, canEdit, attachmentHandler.DeleteAttachment)
+		}
+		breakdownRoutes(authenticated.Group("/", middleware.PersonalWorkspaceMiddleware(workspaceRepo)))
+
+		// Activity across all of the user's workspaces
+		authenticated.GET("/activity", readBreakdowns, activityHandler.GetActivity)
+
+		// Notification routes
+		authenticated.GET("/notifications", readProfile, notificationHandler.GetNotifications)
+		authenticated.POST("/notifications/read", interactiveOnly, notificationHandler.MarkAllNotificationsRead)
+		authenticated.POST("/notifications/:id/read", interactiveOnly, notificationHandler.MarkNotificationRead)
+
+		// Workspace routes
+		authenticated.GET("/workspaces", readBreakdowns, workspaceHandler.GetWorkspaces)
+		authenticated.POST("/workspaces", interactiveOnly, workspaceHandler.CreateWorkspace)
+		authenticated.POST("/invitations/accept", interactiveOnly, ownerOnly, workspaceHandler.AcceptInvitation)
+
+		workspace := authenticated.Group("/workspaces/:workspace_id", middleware.WorkspaceMiddleware(workspaceRepo))
+		{
+			workspaceAdmin := middleware.RequireWorkspaceRole(models.WorkspaceAdmin)
+			workspaceOwner := middleware.RequireWorkspaceRole(models.WorkspaceOwner)
+
+			workspace.GET("", readBreakdowns, workspaceHandler.GetWorkspace)
+			workspace.PATCH("", interactiveOnly, workspaceAdmin, workspaceHandler.UpdateWorkspace)
+			workspace.DELETE("", interactiveOnly, ownerOnly, workspaceOwner, workspaceHandler.DeleteWorkspace)
+			workspace.GET("/members", readBreakdowns, workspaceHandler.GetMembers)
+			workspace.PATCH("/members/:user_id", interactiveOnly, workspaceAdmin, workspaceHandler.UpdateMember)
+			workspace.DELETE("/members/:user_id", interactiveOnly, workspaceHandler.RemoveMember)
+			workspace.GET("/invitations", interactiveOnly, workspaceAdmin, workspaceHandler.GetInvitations)
+			workspace.POST("/invitations", interactiveOnly, workspaceAdmin, workspaceHandler.CreateInvitation)
+			workspace.DELETE("/invitations/:invitation_id", interactiveOnly, workspaceAdmin, workspaceHandler.RevokeInvitation)
+			breakdownRoutes(workspace)
+		}
+
+		// Transfer routes
+		authenticated.GET("/transfers", readBreakdowns, transferHandler.GetTransfers)
+		authenticated.POST("/transfers/:id/accept", writeBreakdowns, transferHandler.AcceptTransfer)
+		authenticated.POST("/transfers/:id/decline", writeBreakdowns, transferHandler.DeclineTransfer)
+	}
+
+	// Admin routes
+	admin := authenticated.Group("/admin")
+	admin.Use(middleware.DenyAccessTokens(), middleware.RequireRole(models.RoleAdmin))
+	{
+		admin.GET("/users", adminHandler.GetUsers)
+		admin.GET("/users/:id", adminHandler.GetUser)
+		admin.POST("/users/:id/disable", adminHandler.DisableUser)
+		admin.POST("/users/:id/enable", adminHandler.EnableUser)
+		admin.POST("/users/:id/reset-password", adminHandler.ForcePasswordReset)
+		admin.POST("/users/:id/impersonate", adminHandler.ImpersonateUser)
+		admin.GET("/stats", adminHandler.GetStats)
+	}
+
+	return router
+}
+
+// loginMethods are the login routes and the methods their attempts are counted under
+var loginMethods = map[string]string{
+	"/auth/login":     "password",
+	"/auth/login/2fa": "2fa",
+}
+
+// countRateLimitedLogins counts login attempts the rate limit rejects, which never reach a handler
+func countRateLimitedLogins(c *gin.Context) {
+	c.Next()
+	if method, ok := loginMethods[c.FullPath()]; ok && middleware.RateLimited(c) {
+		metrics.LoginAttempt(method, metrics.LoginRateLimited)
+	}
+}
@@ -0,0 +1,219 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/health"
+	"server/mail"
+	"server/metrics"
+	"server/openapi"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newTestRouter builds the router with every route registered. The database is never reached:
+// the client connects lazily and these tests only make requests that don't query it.
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	return newTestRouterWithConfig(t, config.Default())
+}
+
+func newTestRouterWithConfig(t *testing.T, cfg *config.Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	return New(Dependencies{
+		Config:   cfg,
+		Database: client.Database("flow_router_test"),
+		Mailer:   mail.LogMailer{},
+		Health:   health.NewRegistry(),
+	})
+}
+
+func TestEveryRouteIsDescribed(t *testing.T) {
+	router := newTestRouter(t)
+	spec := openapi.Build(openapi.Info{}, operations())
+
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+openapi.PathOf(route.Path)] = true
+		if !spec.Has(route.Method, route.Path) {
+			t.Errorf("%s %s is registered but not described in operations()", route.Method, route.Path)
+		}
+	}
+	for _, op := range operations() {
+		if !registered[op.Method+" "+openapi.PathOf(op.Path)] {
+			t.Errorf("%s %s is described in operations() but not registered", op.Method, op.Path)
+		}
+	}
+}
+
+func TestServesOpenAPIDocument(t *testing.T) {
+	router := newTestRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json = %d", recorder.Code)
+	}
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			RequestBody struct {
+				Content map[string]struct {
+					Schema map[string]interface{} `json:"schema"`
+				} `json:"content"`
+			} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding document: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q, want 3.1.0", doc.OpenAPI)
+	}
+
+	login := doc.Paths["/auth/login"]["post"].RequestBody.Content["application/json"].Schema
+	if login["$ref"] != "#/components/schemas/LoginRequest" {
+		t.Errorf("POST /auth/login request schema = %v, want a LoginRequest reference", login)
+	}
+	if required := doc.Components.Schemas["LoginRequest"].Required; strings.Join(required, ",") != "email,password" {
+		t.Errorf("LoginRequest required = %v, want [email password]", required)
+	}
+	if password := doc.Components.Schemas["RegisterRequest"].Properties["password"]; password["minLength"] != float64(6) {
+		t.Errorf("RegisterRequest password = %v, want minLength 6", password)
+	}
+	if required := doc.Components.Schemas["BreakdownRequest"].Required; strings.Join(required, ",") != "name" {
+		t.Errorf("BreakdownRequest required = %v, want [name]", required)
+	}
+	breakdown := doc.Components.Schemas["Breakdown"].Properties
+	if breakdown["created_at"]["format"] != "date-time" || breakdown["comment_count"]["type"] != "integer" {
+		t.Errorf("Breakdown properties = %v", breakdown)
+	}
+}
+
+func TestServesDocsPage(t *testing.T) {
+	router := newTestRouter(t)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/docs", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), "openapi.json") {
+		t.Errorf("GET /docs = %d, want a page loading openapi.json", recorder.Code)
+	}
+	if policy := recorder.Header().Get("Content-Security-Policy"); !strings.Contains(policy, "script-src https://unpkg.com/swagger-ui-dist@5.17.14/ 'sha256-") {
+		t.Errorf("GET /docs Content-Security-Policy = %q, want scripts limited to the pinned viewer and the inline script", policy)
+	}
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	cfg := config.Default()
+	cfg.OIDC["test"] = config.OIDCProvider{Issuer: "https://idp.example.com", ClientID: "flow", RedirectURL: "https://api.example.com/auth/oidc/test/callback"}
+	router := newTestRouterWithConfig(t, cfg)
+
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{"no cookie", ""},
+		{"another login's state", "victim-state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/auth/oidc/test/callback?code=attacker-code&state=attacker-state", nil)
+			if tt.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: "oidc_state", Value: tt.cookie})
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "not started in this browser") {
+				t.Errorf("callback = %d %s, want 400 rejecting the state", recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestForwardedForDoesNotResetRateLimits(t *testing.T) {
+	login := func(router *gin.Engine, forwardedFor string) int {
+		request := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader("{}"))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	cfg := config.Default()
+	cfg.RateLimit.AuthIPLimit = 1
+	router := newTestRouterWithConfig(t, cfg)
+	if code := login(router, "198.51.100.1"); code == http.StatusTooManyRequests {
+		t.Fatalf("first login = %d", code)
+	}
+	if code := login(router, "198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Errorf("login with a spoofed X-Forwarded-For = %d, want 429", code)
+	}
+
+	// Behind a trusted proxy, the forwarded address is the client's
+	cfg = config.Default()
+	cfg.RateLimit.AuthIPLimit = 1
+	cfg.Server.TrustedProxies = []string{"192.0.2.0/24"} // httptest requests come from 192.0.2.1
+	router = newTestRouterWithConfig(t, cfg)
+	for _, client := range []string{"198.51.100.1", "198.51.100.2"} {
+		if code := login(router, client); code == http.StatusTooManyRequests {
+			t.Errorf("first login from %s through a trusted proxy = %d", client, code)
+		}
+	}
+}
+
+// loginAttempts reads the login attempts counted for method and result from the metrics endpoint
+func loginAttempts(t *testing.T, method, result string) float64 {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	series := `flow_auth_login_attempts_total{method="` + method + `",result="` + result + `"} `
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if strings.HasPrefix(line, series) {
+			value, err := strconv.ParseFloat(strings.TrimPrefix(line, series), 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return value
+		}
+	}
+	return 0
+}
+
+func TestCountsRateLimitedLogins(t *testing.T) {
+	cfg := config.Default()
+	cfg.RateLimit.AuthIPLimit = 1
+	router := newTestRouterWithConfig(t, cfg)
+	before := loginAttempts(t, "password", metrics.LoginRateLimited)
+
+	for i := 0; i < 3; i++ {
+		request := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader("{}"))
+		request.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	if counted := loginAttempts(t, "password", metrics.LoginRateLimited) - before; counted != 2 {
+		t.Errorf("counted %v rate limited logins, want 2", counted)
+	}
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/db/models"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestTransferFlow(t *testing.T) {
+	router, database := newDatabaseRouter(t, config.Default())
+	owner, recipient := registerUser(t, router, "ada"), registerUser(t, router, "grace")
+	ctx := context.Background()
+
+	serve := func(login *httptest.ResponseRecorder, method, path, body string, want int) *httptest.ResponseRecorder {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, authorizedRequest(t, login, method, path, body))
+		if recorder.Code != want {
+			t.Fatalf("%s %s = %d %s, want %d", method, path, recorder.Code, recorder.Body.String(), want)
+		}
+		return recorder
+	}
+	var breakdown, transfer struct {
+		ID primitive.ObjectID `json:"id"`
+	}
+	decode(t, serve(owner, http.MethodPost, "/breakdowns", `{"name":"Plan"}`, http.StatusCreated), &breakdown)
+	breakdownPath := "/breakdowns/" + breakdown.ID.Hex()
+	offer := func() string {
+		decode(t, serve(owner, http.MethodPost, breakdownPath+"/transfer", `{"email":"grace@example.com"}`, http.StatusCreated), &transfer)
+		return "/transfers/" + transfer.ID.Hex()
+	}
+	recorded := func(collection string, filter bson.M) bool {
+		t.Helper()
+		count, err := database.Collection(collection).CountDocuments(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count == 1
+	}
+
+	// Declining leaves the breakdown with its owner and can't be repeated
+	declined := offer()
+	serve(owner, http.MethodPost, breakdownPath+"/transfer", `{"email":"grace@example.com"}`, http.StatusConflict)
+	serve(owner, http.MethodPost, declined+"/decline", "", http.StatusForbidden)
+	serve(recipient, http.MethodPost, declined+"/decline", "", http.StatusOK)
+	serve(recipient, http.MethodPost, declined+"/accept", "", http.StatusConflict)
+	serve(owner, http.MethodGet, breakdownPath, "", http.StatusOK)
+	if !recorded("activities", bson.M{"type": models.ActivityTransferDeclined, "breakdown_id": breakdown.ID, "actor_id": userID(t, recipient)}) {
+		t.Error("declining wasn't recorded in the activity log")
+	}
+	if !recorded("audit_events", bson.M{"action": models.AuditTransferDeclined, "target_id": breakdown.ID.Hex()}) {
+		t.Error("declining wasn't audited")
+	}
+
+	// Accepting hands the breakdown to the recipient's personal workspace
+	accepted := offer()
+	serve(owner, http.MethodPost, accepted+"/accept", "", http.StatusForbidden)
+	serve(recipient, http.MethodPost, accepted+"/accept", "", http.StatusOK)
+	serve(recipient, http.MethodPost, accepted+"/decline", "", http.StatusConflict)
+	serve(recipient, http.MethodGet, breakdownPath, "", http.StatusOK)
+	serve(owner, http.MethodGet, breakdownPath, "", http.StatusNotFound)
+	if !recorded("activities", bson.M{"type": models.ActivityBreakdownTransferred, "breakdown_id": breakdown.ID, "actor_id": userID(t, recipient)}) {
+		t.Error("accepting wasn't recorded in the activity log")
+	}
+	if !recorded("audit_events", bson.M{"action": models.AuditTransferAccepted, "target_id": breakdown.ID.Hex()}) {
+		t.Error("accepting wasn't audited")
+	}
+}
@@ -0,0 +1,89 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"server/config"
+	"server/db/models"
+	"server/db/repository"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// registerUser creates an account through the API and returns the response, which carries its token
+func registerUser(t *testing.T, router *gin.Engine, username string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/auth/register",
+		strings.NewReader(`{"email":"`+username+`@example.com","username":"`+username+`","password":"correct horse"}`))
+	request.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("registering %s = %d %s", username, recorder.Code, recorder.Body.String())
+	}
+	return recorder
+}
+
+// decode unmarshals a JSON response into v
+func decode(t *testing.T, recorder *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(recorder.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %s: %v", recorder.Body.String(), err)
+	}
+}
+
+func TestMemberEmailsAreOnlyShownToMembers(t *testing.T) {
+	router, database := newDatabaseRouter(t, config.Default())
+	owner, guest := registerUser(t, router, "ada"), registerUser(t, router, "grace")
+
+	created := httptest.NewRecorder()
+	router.ServeHTTP(created, authorizedRequest(t, owner, http.MethodPost, "/workspaces", `{"name":"Team"}`))
+	var workspace struct {
+		ID primitive.ObjectID `json:"id"`
+	}
+	decode(t, created, &workspace)
+	if err := repository.NewWorkspaceRepository(database).AddMember(context.Background(), workspace.ID, userID(t, guest), models.WorkspaceGuest); err != nil {
+		t.Fatal(err)
+	}
+
+	tokenCreated := httptest.NewRecorder()
+	router.ServeHTTP(tokenCreated, authorizedRequest(t, owner, http.MethodPost, "/tokens", `{"name":"ci","scopes":["breakdowns:read"]}`))
+	if tokenCreated.Code != http.StatusCreated {
+		t.Fatalf("creating an access token = %d %s", tokenCreated.Code, tokenCreated.Body.String())
+	}
+
+	membersPath := "/workspaces/" + workspace.ID.Hex() + "/members"
+	tests := []struct {
+		name       string
+		login      *httptest.ResponseRecorder
+		wantEmails bool
+	}{
+		{"owner", owner, true},
+		{"guest", guest, false},
+		{"owner's access token", tokenCreated, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, authorizedRequest(t, tt.login, http.MethodGet, membersPath, ""))
+			if recorder.Code != http.StatusOK {
+				t.Fatalf("GET %s = %d %s", membersPath, recorder.Code, recorder.Body.String())
+			}
+			var members []map[string]interface{}
+			decode(t, recorder, &members)
+			if len(members) != 2 {
+				t.Fatalf("members = %v, want the owner and the guest", members)
+			}
+			for _, member := range members {
+				if _, ok := member["email"]; ok != tt.wantEmails {
+					t.Errorf("member %v has email %v, want %v", member["username"], ok, tt.wantEmails)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"server/db/models"
+	"server/db/repository"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	errSeedUsage      = errors.New("usage: seed [-users n] [-breakdowns n] [-password password] [-force]")
+	errSeedProduction = errors.New("seed only runs with ENVIRONMENT=development; pass -force to seed this database anyway")
+)
+
+var (
+	seedFirstNames = []string{"Alex", "Sam", "Jordan", "Priya", "Mateo", "Aisha", "Noah", "Yuki", "Elena", "Kwame", "Chloe", "Omar", "Ingrid", "Luca", "Mei", "Tariq"}
+	seedLastNames  = []string{"Garcia", "Okafor", "Nguyen", "Schmidt", "Patel", "Rossi", "Kowalski", "Tanaka", "Silva", "Haddad", "Johansson", "Murphy"}
+	seedTimezones  = []string{"Europe/London", "America/New_York", "America/Los_Angeles", "Europe/Berlin", "Asia/Tokyo", "Australia/Sydney"}
+
+	// seedBreakdowns are goals with the steps they break down into
+	seedBreakdowns = []struct {
+		name  string
+		steps []string
+	}{
+		{"Plan a weekend trip", []string{"Pick a destination", "Book accommodation", "Check train times", "Pack a bag"}},
+		{"Launch the newsletter", []string{"Choose a platform", "Write the first issue", "Design a header", "Invite subscribers"}},
+		{"Clean out the garage", []string{"Sort items into keep, donate and bin", "Book a donation pickup", "Put up shelves"}},
+		{"Learn to bake sourdough", []string{"Make a starter", "Feed it daily for a week", "Bake a first loaf", "Adjust hydration"}},
+		{"Prepare quarterly report", []string{"Export the sales figures", "Draft the summary", "Make the charts", "Send for review"}},
+		{"Run a 10k", []string{"Buy running shoes", "Follow a six-week plan", "Register for a race", "Taper the last week"}},
+		{"Move to a new flat", []string{"Give notice to the landlord", "Hire a van", "Update the address with the bank", "Set up broadband"}},
+		{"Redesign the portfolio site", []string{"Collect recent work", "Sketch the layout", "Build the pages", "Ask a friend for feedback"}},
+		{"Organise a team offsite", []string{"Agree on dates", "Find a venue", "Plan the agenda", "Arrange catering"}},
+		{"Read more books", []string{"Make a reading list", "Join the library", "Read for 20 minutes a day"}},
+	}
+)
+
+// runSeed fills the database with fake users and breakdowns for development. It refuses to run
+// outside development unless forced, since the accounts it creates share one known password.
+func runSeed(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	users := flags.Int("users", 10, "number of users to create")
+	breakdowns := flags.Int("breakdowns", 5, "number of breakdowns per user")
+	password := flags.String("password", "", "password for every seeded user (generated if empty)")
+	force := flags.Bool("force", false, "seed even when ENVIRONMENT isn't development")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 0 || *users < 0 || *breakdowns < 0 {
+		return errSeedUsage
+	}
+	if a.cfg.Server.Environment != "development" && !*force {
+		return errSeedProduction
+	}
+	if *password == "" {
+		var err error
+		if *password, err = generatePassword(); err != nil {
+			return err
+		}
+	}
+
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for created := 0; created < *users; {
+		first := seedFirstNames[random.Intn(len(seedFirstNames))]
+		last := seedLastNames[random.Intn(len(seedLastNames))]
+		username := fmt.Sprintf("%s.%s%d", strings.ToLower(first), strings.ToLower(last), random.Intn(1000))
+		user := &models.User{
+			Username:    username,
+			Email:       username + "@example.com",
+			DisplayName: first + " " + last,
+			Timezone:    seedTimezones[random.Intn(len(seedTimezones))],
+			Password:    *password, // Will be hashed in repository
+		}
+		err := a.userRepo.CreateUser(ctx, user)
+		if errors.Is(err, repository.ErrEmailTaken) || errors.Is(err, repository.ErrUsernameTaken) {
+			// Pick another name
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		created++
+
+		workspace, err := a.workspaceRepo.EnsurePersonalWorkspace(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < *breakdowns; i++ {
+			if err := a.breakdownRepo.Create(ctx, seedBreakdown(random, user.ID, workspace.ID)); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("%s\t%s\n", user.Email, *password)
+	}
+	return nil
+}
+
+// seedBreakdown makes a breakdown created some time in the last 90 days, completed about a third of the time
+func seedBreakdown(random *rand.Rand, userID, workspaceID primitive.ObjectID) *models.Breakdown {
+	template := seedBreakdowns[random.Intn(len(seedBreakdowns))]
+	var description strings.Builder
+	for _, step := range template.steps {
+		fmt.Fprintf(&description, "- %s\n", step)
+	}
+
+	createdAt := time.Now().Add(-time.Duration(random.Int63n(int64(90 * 24 * time.Hour))))
+	breakdown := &models.Breakdown{
+		ID:          primitive.NewObjectIDFromTimestamp(createdAt),
+		WorkspaceID: workspaceID,
+		UserID:      userID,
+		Name:        template.name,
+		Description: description.String(),
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+	}
+	if random.Intn(3) == 0 {
+		completedAt := createdAt.Add(time.Duration(random.Int63n(int64(time.Since(createdAt)))))
+		breakdown.Completed = true
+		breakdown.CompletedAt = &completedAt
+		breakdown.UpdatedAt = completedAt
+	}
+	return breakdown
+}
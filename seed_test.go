@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"server/config"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSeedRefusesOutsideDevelopment(t *testing.T) {
+	// The checks come before the database is used, so the app has no connection
+	a := &app{cfg: config.Default()}
+	if err := runSeed(context.Background(), a, nil); !errors.Is(err, errSeedProduction) {
+		t.Errorf("seed in production = %v, want errSeedProduction", err)
+	}
+	for _, args := range [][]string{{"-users", "-1"}, {"extra"}, {"-unknown"}} {
+		if err := runSeed(context.Background(), a, args); !errors.Is(err, errSeedUsage) {
+			t.Errorf("seed %q = %v, want errSeedUsage", args, err)
+		}
+	}
+}
+
+func TestSeedCreatesUsersAndBreakdowns(t *testing.T) {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+	cfg := config.Default()
+	cfg.Mongo.URI = uri
+	cfg.Mongo.Database = "flow_seed_test_" + primitive.NewObjectID().Hex()
+	ctx := context.Background()
+	a, err := newApp(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		a.database.Drop(ctx)
+		a.close(ctx)
+	})
+
+	if err := runSeed(ctx, a, []string{"-users", "3", "-breakdowns", "2", "-force"}); err != nil {
+		t.Fatal(err)
+	}
+	users, err := a.userRepo.Count(ctx, bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	breakdowns, err := a.breakdownRepo.Count(ctx, bson.M{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users != 3 || breakdowns != 6 {
+		t.Errorf("seeded %d users and %d breakdowns, want 3 and 6", users, breakdowns)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"server/config"
+	"server/db/migrations"
+	"server/db/repository"
+	"server/health"
+	"server/mail"
+	"server/metrics"
+	"server/router"
+	"server/storage"
+	"server/tracing"
+	"server/utils"
+	"server/worker"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// runServe runs the API server until it fails or receives SIGINT or SIGTERM, then shuts down gracefully.
+// Each resource is released by a deferred call as soon as it is acquired, so failing part way through
+// startup cleans up what was already set up.
+func runServe(cfg *config.Config, args []string) error {
+	if len(args) > 0 {
+		return errors.New("usage: serve")
+	}
+
+	// Every shutdown step shares one deadline, started by whichever step runs first
+	deadline := &shutdownDeadline{timeout: cfg.Server.ShutdownTimeout.Duration}
+	defer deadline.release()
+
+	// Tracing is set up first so the database connection is instrumented
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer deadline.run(func(ctx context.Context) {
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	})
+
+	// Initialize MongoDB connection and repositories
+	a, err := newApp(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	defer deadline.run(func(ctx context.Context) {
+		if err := a.close(ctx); err != nil {
+			slog.Error("Failed to disconnect from MongoDB", "error", err)
+		}
+	})
+
+	// Bring the schema up to date
+	if cfg.Mongo.MigrateOnStartup {
+		if _, err := migrations.New(a.database).Up(context.Background()); err != nil {
+			return fmt.Errorf("applying migrations: %w", err)
+		}
+	}
+
+	// Background work runs until shutdown begins, then is waited for before Mongo is closed
+	background, stopBackground := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	defer deadline.run(func(ctx context.Context) {
+		stopWorkers(ctx, stopBackground, &workers)
+	})
+	runInBackground := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(background)
+		}()
+	}
+
+	// Set up token signing; asymmetric keys are rotated in the background
+	var keyManager *utils.KeyManager
+	if cfg.Auth.JWTAlgorithm != "HS256" {
+		keyEncryptionKey, err := cfg.Auth.DecodeKeyEncryptionKey()
+		if err != nil {
+			return err
+		}
+		if keyEncryptionKey == nil {
+			slog.Warn("Signing keys are stored unencrypted; set JWT_KEY_ENCRYPTION_KEY to encrypt them")
+		}
+		keyManager, err = utils.NewKeyManager(repository.NewSigningKeyRepository(a.database), cfg.Auth.JWTAlgorithm, cfg.Auth.KeyRotation.Duration, cfg.Auth.KeyOverlap.Duration, keyEncryptionKey)
+		if err != nil {
+			return fmt.Errorf("setting up token signing: %w", err)
+		}
+		if err := keyManager.Refresh(context.Background()); err != nil {
+			return fmt.Errorf("loading signing keys: %w", err)
+		}
+		runInBackground(keyManager.Run)
+	}
+	utils.ConfigureTokens(keyManager, cfg.Auth.JWTSecret, cfg.Auth.Issuer, cfg.Auth.Audience)
+
+	// Attachments are kept on the local filesystem unless an S3-compatible store is configured
+	blobStore, err := storage.New(cfg.Storage)
+	if err != nil {
+		return fmt.Errorf("setting up blob storage: %w", err)
+	}
+
+	// Mail is logged instead of sent unless SMTP is configured
+	mailer := mail.New(cfg.Mail)
+
+	// Start background workers
+	accountDeletionWorker := worker.NewAccountDeletionWorker(a.userRepo, a.breakdownRepo, a.accessTokenRepo, a.transferRepo, a.sessionRepo, a.workspaceRepo, a.invitationRepo, a.commentRepo, a.notificationRepo)
+	runInBackground(accountDeletionWorker.Run)
+	attachmentCleanupWorker := worker.NewAttachmentCleanupWorker(a.attachmentRepo, blobStore)
+	runInBackground(attachmentCleanupWorker.Run)
+
+	// Readiness depends on the database; mail and background workers only degrade the service
+	healthRegistry := health.NewRegistry()
+	healthRegistry.Register(health.Check{
+		Name:     "mongo",
+		Check:    func(ctx context.Context) error { return a.client.Ping(ctx, readpref.Primary()) },
+		Critical: true,
+	})
+	healthRegistry.Register(health.Check{Name: "mail", Check: mailer.Ping, Timeout: 5 * time.Second})
+	healthRegistry.Register(health.Check{Name: "account_deletion_worker", Check: accountDeletionWorker.Heartbeat.Check(2 * accountDeletionWorker.Interval)})
+	healthRegistry.Register(health.Check{Name: "attachment_cleanup_worker", Check: attachmentCleanupWorker.Heartbeat.Check(2 * attachmentCleanupWorker.Interval)})
+
+	// Build the API router
+	handler := router.New(router.Dependencies{
+		Config:     cfg,
+		Database:   a.database,
+		Mailer:     mailer,
+		BlobStore:  blobStore,
+		KeyManager: keyManager,
+		Health:     healthRegistry,
+	})
+
+	// Start the server and wait for it to fail or for a shutdown signal
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Server.ReadTimeout.Duration,
+		ReadTimeout:       cfg.Server.ReadTimeout.Duration,
+		WriteTimeout:      cfg.Server.WriteTimeout.Duration,
+		IdleTimeout:       cfg.Server.IdleTimeout.Duration,
+	}
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	serverErr := make(chan error, 2)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+	slog.Info("Listening", "addr", server.Addr)
+
+	// Metrics can be served on their own listener so they aren't exposed alongside the API
+	var metricsServer *http.Server
+	if cfg.Metrics.Addr != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metrics.Handler())
+		metricsServer = &http.Server{
+			Addr:              cfg.Metrics.Addr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: cfg.Server.ReadTimeout.Duration,
+		}
+		go func() {
+			serverErr <- metricsServer.ListenAndServe()
+		}()
+		slog.Info("Serving metrics", "addr", metricsServer.Addr)
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-serverErr:
+	case <-signals.Done():
+		slog.Info("Shutting down")
+	}
+	// A second signal terminates immediately
+	stopSignals()
+
+	// Fail readiness first and keep serving for a while so load balancers stop routing here
+	healthRegistry.SetShuttingDown()
+	time.Sleep(cfg.Server.ShutdownDelay.Duration)
+
+	// Stop accepting connections and drain in-flight requests; the deferred calls then stop background
+	// workers, close the database connection and flush traces
+	deadline.run(func(ctx context.Context) {
+		if metricsServer != nil {
+			metricsServer.Shutdown(ctx)
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Failed to drain in-flight requests", "error", err)
+			server.Close()
+		}
+	})
+	return serveErr
+}
+
+// shutdownDeadline bounds the whole of shutdown by the configured timeout, so each step only gets
+// what the steps before it left
+type shutdownDeadline struct {
+	timeout time.Duration
+	once    sync.Once
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// run runs a shutdown step, starting the deadline if this is the first
+func (d *shutdownDeadline) run(step func(ctx context.Context)) {
+	d.once.Do(func() {
+		d.ctx, d.cancel = context.WithTimeout(context.Background(), d.timeout)
+	})
+	step(d.ctx)
+}
+
+// release frees the deadline once every step has run
+func (d *shutdownDeadline) release() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+}
+
+// stopWorkers cancels background work and waits for it to finish, until ctx is done
+func stopWorkers(ctx context.Context, stopBackground context.CancelFunc, workers *sync.WaitGroup) {
+	stopBackground()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("Timed out waiting for background workers to stop")
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestShutdownStepsShareOneDeadline(t *testing.T) {
+	deadline := &shutdownDeadline{timeout: 50 * time.Millisecond}
+	defer deadline.release()
+
+	// The first step uses up the timeout; later steps get nothing more
+	deadline.run(func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	start := time.Now()
+	deadline.run(func(ctx context.Context) {
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+	})
+	if waited := time.Since(start); waited > 25*time.Millisecond {
+		t.Errorf("second step waited %s after the shared deadline passed", waited)
+	}
+}
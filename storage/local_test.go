@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorePutGetDelete(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "attachments/1/2", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	body, err := store.Get(ctx, "attachments/1/2")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "hello" {
+		t.Errorf("Get = %q, want hello", data)
+	}
+
+	if err := store.Delete(ctx, "attachments/1/2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "attachments/1/2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "attachments/1/2"); err != nil {
+		t.Errorf("deleting a missing blob = %v, want nil", err)
+	}
+}
+
+func TestLocalStoreRejectsShortWrites(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(context.Background(), "blob", strings.NewReader("hello"), 10, ""); err == nil {
+		t.Fatal("Put with a short body succeeded, want an error")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("store contains %d entries after a failed Put, want none", len(entries))
+	}
+}
+
+func TestLocalStoreKeysStayInsideDirectory(t *testing.T) {
+	parent := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(parent, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	outside := filepath.Join(parent, "outside")
+
+	for _, key := range []string{
+		"",
+		"..",
+		"../outside",
+		"attachments/../../outside",
+		outside,
+		"/etc/passwd",
+	} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Put(%q) succeeded, want an invalid key error", key)
+		}
+		if _, err := store.Get(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) = %v, want an invalid key error", key, err)
+		}
+		if err := store.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) succeeded, want an invalid key error", key)
+		}
+	}
+	if _, err := os.Stat(outside); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("a file was written outside the store: %v", err)
+	}
+
+	// Dot segments that stay inside the store are cleaned rather than rejected
+	if err := store.Put(ctx, "attachments/./1/../2", strings.NewReader("x"), 1, ""); err != nil {
+		t.Errorf("Put with inner dot segments: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "blobs", "attachments", "2")); err != nil {
+		t.Errorf("cleaned key was not stored at attachments/2: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKeyID     = "AKIDEXAMPLE"
+	testSecretAccessKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	testRegion          = "eu-west-1"
+	testBucket          = "flow-attachments"
+)
+
+// fakeS3 is a stand-in for an S3-compatible server. It checks each request's Signature Version 4
+// signature independently of S3Store.sign and keeps objects in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	fake := &fakeS3{objects: map[string]fakeObject{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if reason := verifySignature(r); reason != "" {
+		http.Error(w, "SignatureDoesNotMatch: "+reason, http.StatusForbidden)
+		return
+	}
+	prefix := "/" + testBucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil || int64(len(data)) != r.ContentLength {
+			http.Error(w, "IncompleteBody", http.StatusBadRequest)
+			return
+		}
+		f.objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Write(object.data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "MethodNotAllowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verifySignature recomputes the request's signature as S3 does, returning why it doesn't match
+func verifySignature(r *http.Request) string {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "AWS4-HMAC-SHA256 ") {
+		return "not signed with AWS4-HMAC-SHA256"
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(authorization, "AWS4-HMAC-SHA256 "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[name] = value
+	}
+
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[0] != testAccessKeyID || credential[2] != testRegion || credential[3] != "s3" || credential[4] != "aws4_request" {
+		return "bad credential scope " + fields["Credential"]
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil || signedAt.Format("20060102") != credential[1] || time.Since(signedAt).Abs() > 15*time.Minute {
+		return "bad date " + amzDate
+	}
+
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return "signed headers are not sorted"
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, required := range []string{"host", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+fields["SignedHeaders"]+";", ";"+required+";") {
+			return required + " is not signed"
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		r.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, strings.Join(credential[1:], "/"), hex.EncodeToString(hash[:])}, "\n")
+
+	key := []byte("AWS4" + testSecretAccessKey)
+	for _, part := range append(credential[1:], stringToSign) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(part))
+		key = mac.Sum(nil)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(key)), []byte(fields["Signature"])) {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+func newTestS3Store(t *testing.T, endpoint, secret string) *S3Store {
+	t.Helper()
+	store, err := NewS3Store(S3Config{
+		Endpoint:        endpoint + "/",
+		Region:          testRegion,
+		Bucket:          testBucket,
+		AccessKeyID:     testAccessKeyID,
+		SecretAccessKey: secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	fake, server := newFakeS3(t)
+	store := newTestS3Store(t, server.URL, testSecretAccessKey)
+	ctx := context.Background()
+
+	// Keys with characters that need encoding exercise the canonical path
+	for _, key := range []string{"attachments/1/2", "attachments/with space/ünïcode+plus~tilde.txt"} {
+		contents := "contents of " + key
+		if err := store.Put(ctx, key, strings.NewReader(contents), int64(len(contents)), "text/plain"); err != nil {
+			t.Fatalf("Put(%q): %v", key, err)
+		}
+		if object := fake.objects[key]; string(object.data) != contents || object.contentType != "text/plain" {
+			t.Errorf("stored %q = %+v", key, object)
+		}
+
+		body, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", key, err)
+		}
+		data, _ := io.ReadAll(body)
+		body.Close()
+		if string(data) != contents {
+			t.Errorf("Get(%q) = %q, want %q", key, data, contents)
+		}
+
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("Delete(%q): %v", key, err)
+		}
+		if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q) after Delete = %v, want ErrNotFound", key, err)
+		}
+	}
+}
+
+func TestS3StoreMissingObjects(t *testing.T) {
+	_, server := newFakeS3(t)
+	store := newTestS3Store(t, server.URL, testSecretAccessKey)
+
+	if _, err := store.Get(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(context.Background(), "missing"); err != nil {
+		t.Errorf("Delete = %v, want deleting a missing blob to succeed", err)
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	_, server := newFakeS3(t)
+	store := newTestS3Store(t, server.URL, "not-the-secret")
+
+	err := store.Put(context.Background(), "key", strings.NewReader("data"), 4, "text/plain")
+	if err == nil || !strings.Contains(err.Error(), "403") || !strings.Contains(err.Error(), "signature mismatch") {
+		t.Errorf("Put with the wrong secret = %v, want a 403 signature error", err)
+	}
+}
+
+func TestS3StoreRequiresConfiguration(t *testing.T) {
+	if _, err := NewS3Store(S3Config{Endpoint: "http://localhost:9000", Bucket: testBucket}); err == nil {
+		t.Error("NewS3Store without credentials succeeded, want an error")
+	}
+	store := newTestS3Store(t, "http://localhost:9000", testSecretAccessKey)
+	if store.config.Region != testRegion || strings.HasSuffix(store.config.Endpoint, "/") {
+		t.Errorf("config = %+v", store.config)
+	}
+}
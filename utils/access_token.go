@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// AccessTokenPrefix identifies personal access tokens so they can be told apart from JWTs
+const AccessTokenPrefix = "flow_pat_"
+
+// GenerateAccessToken creates a new random personal access token
+func GenerateAccessToken() (string, error) {
+	raw, err := GenerateRandomToken()
+	if err != nil {
+		return "", err
+	}
+	return AccessTokenPrefix + raw, nil
+}
+
+// GenerateRandomToken creates a random hex token, e.g. for links sent by email
+func GenerateRandomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// IsAccessToken reports whether a bearer token is a personal access token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// HashAccessToken returns the stored form of a personal access token
+func HashAccessToken(token string) string {
+	return HashToken(token)
+}
+
+// HashToken returns the stored form of a random token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
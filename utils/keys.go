@@ -0,0 +1,417 @@
+package utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"server/db/models"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported asymmetric signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// keyRefreshInterval is how often keys are reloaded so keys created by other instances are picked up
+const keyRefreshInterval = time.Minute
+
+// keyReloadInterval limits how often a token with an unknown kid triggers a reload from the store
+const keyReloadInterval = 10 * time.Second
+
+// keyEncryption names how private keys are encrypted at rest with the key encryption key
+const keyEncryption = "aes-256-gcm"
+
+// ErrKeyExists is returned by KeyStore.SaveKey when another key already covers the same slot
+var ErrKeyExists = errors.New("a signing key already exists for this slot")
+
+// KeyStore persists signing keys so every instance signs and validates with the same set
+type KeyStore interface {
+	LoadKeys(ctx context.Context) ([]models.SigningKey, error)
+	// SaveKey stores a new key, returning ErrKeyExists if a key with the same algorithm and slot exists
+	SaveKey(ctx context.Context, key *models.SigningKey) error
+	DeleteExpiredKeys(ctx context.Context, now time.Time) error
+}
+
+// signingKey is a decoded models.SigningKey
+type signingKey struct {
+	models.SigningKey
+	private crypto.Signer
+}
+
+// KeyManager signs tokens with a rotating set of asymmetric keys.
+// Time is divided into slots of one rotation interval, counted from the Unix epoch, and each
+// slot has exactly one key, so instances rotating at the same time agree on it. A key signs new
+// tokens during its slot; the next key is created (and published in the JWKS) one overlap window
+// before it takes over, and tokens signed with a retired key are accepted for one overlap window
+// after it stops signing.
+type KeyManager struct {
+	store     KeyStore
+	algorithm string
+	rotation  time.Duration
+	overlap   time.Duration
+	aead      cipher.AEAD // Encrypts private keys at rest; nil stores them unencrypted
+
+	mu   sync.RWMutex
+	keys []signingKey // Sorted by ActiveFrom
+
+	reloadMu   sync.Mutex
+	reloadedAt time.Time
+}
+
+// NewKeyManager returns a key manager. Private keys are encrypted with keyEncryptionKey, which must be
+// 32 bytes, or stored unencrypted when it is empty.
+func NewKeyManager(store KeyStore, algorithm string, rotation, overlap time.Duration, keyEncryptionKey []byte) (*KeyManager, error) {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if overlap <= 0 || rotation <= overlap {
+		return nil, errors.New("key rotation interval must be longer than the overlap window")
+	}
+
+	m := &KeyManager{
+		store:     store,
+		algorithm: algorithm,
+		rotation:  rotation,
+		overlap:   overlap,
+	}
+	if len(keyEncryptionKey) > 0 {
+		if len(keyEncryptionKey) != 32 {
+			return nil, errors.New("key encryption key must be 32 bytes")
+		}
+		block, err := aes.NewCipher(keyEncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		if m.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Run refreshes and rotates keys until ctx is cancelled
+func (m *KeyManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(keyRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Refresh(ctx); err != nil {
+				slog.Error("Failed to refresh signing keys", "error", err)
+			}
+		}
+	}
+}
+
+// Refresh reloads keys from the store, creating the current or next key when rotation is due
+func (m *KeyManager) Refresh(ctx context.Context) error {
+	now := time.Now()
+	if err := m.store.DeleteExpiredKeys(ctx, now); err != nil {
+		return err
+	}
+
+	keys, err := m.load(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	// Make sure the current slot has a key, and that the next one does before the overlap window starts
+	slots := []int64{m.slot(now)}
+	if m.slotStart(slots[0]+1).Sub(now) <= m.overlap {
+		slots = append(slots, slots[0]+1)
+	}
+	lostRace := false
+	for _, slot := range slots {
+		if m.hasSlot(keys, slot) {
+			continue
+		}
+		key, err := m.createKey(ctx, slot)
+		if errors.Is(err, ErrKeyExists) {
+			// Another instance created it first; use theirs
+			lostRace = true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	if lostRace {
+		if keys, err = m.load(ctx, now); err != nil {
+			return err
+		}
+	}
+
+	m.setKeys(keys)
+	return nil
+}
+
+// load reads and decodes the unexpired keys in the store
+func (m *KeyManager) load(ctx context.Context, now time.Time) ([]signingKey, error) {
+	stored, err := m.store.LoadKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]signingKey, 0, len(stored))
+	for _, key := range stored {
+		if key.ExpiresAt.Before(now) {
+			continue
+		}
+		decoded, err := m.decodeSigningKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("signing key %s: %w", key.ID, err)
+		}
+		keys = append(keys, decoded)
+	}
+	return keys, nil
+}
+
+func (m *KeyManager) setKeys(keys []signingKey) {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ActiveFrom.Before(keys[j].ActiveFrom) })
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+}
+
+// reload picks up keys created by other instances, at most once per keyReloadInterval. It reports
+// whether the keys were reloaded.
+func (m *KeyManager) reload(ctx context.Context) bool {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	if time.Since(m.reloadedAt) < keyReloadInterval {
+		return false
+	}
+	m.reloadedAt = time.Now()
+
+	keys, err := m.load(ctx, time.Now())
+	if err != nil {
+		slog.Error("Failed to reload signing keys", "error", err)
+		return false
+	}
+	m.setKeys(keys)
+	return true
+}
+
+// slot returns the rotation slot containing t
+func (m *KeyManager) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(m.rotation)
+}
+
+// slotStart returns when a slot's key starts signing
+func (m *KeyManager) slotStart(slot int64) time.Time {
+	return time.Unix(0, slot*int64(m.rotation))
+}
+
+// hasSlot reports whether keys include one for the slot with the configured algorithm
+func (m *KeyManager) hasSlot(keys []signingKey, slot int64) bool {
+	for _, key := range keys {
+		if key.Slot == slot && key.Algorithm == m.algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// createKey generates and stores the key for a slot, returning ErrKeyExists if another instance
+// stored one first
+func (m *KeyManager) createKey(ctx context.Context, slot int64) (signingKey, error) {
+	var private crypto.Signer
+	var err error
+	switch m.algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return signingKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return signingKey{}, err
+	}
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return signingKey{}, err
+	}
+
+	activeFrom := m.slotStart(slot)
+	key := models.SigningKey{
+		ID:          hex.EncodeToString(kid),
+		Algorithm:   m.algorithm,
+		Slot:        slot,
+		PrivateKey:  der,
+		ActiveFrom:  activeFrom,
+		ActiveUntil: activeFrom.Add(m.rotation),
+		ExpiresAt:   activeFrom.Add(m.rotation + m.overlap),
+		CreatedAt:   time.Now(),
+	}
+	if m.aead != nil {
+		nonce := make([]byte, m.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return signingKey{}, err
+		}
+		key.PrivateKey = m.aead.Seal(nonce, nonce, der, []byte(key.ID))
+		key.Encryption = keyEncryption
+	}
+	if err := m.store.SaveKey(ctx, &key); err != nil {
+		return signingKey{}, err
+	}
+	return signingKey{SigningKey: key, private: private}, nil
+}
+
+// current returns the key that signs new tokens
+func (m *KeyManager) current() (*signingKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].Algorithm == m.algorithm && !m.keys[i].ActiveFrom.After(now) && m.keys[i].ActiveUntil.After(now) {
+			return &m.keys[i], nil
+		}
+	}
+	return nil, errors.New("no active signing key")
+}
+
+// publicKey returns the verification key for kid if it has not expired
+func (m *KeyManager) publicKey(kid string) (crypto.PublicKey, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	for _, key := range m.keys {
+		if key.ID == kid && key.ExpiresAt.After(now) {
+			return key.private.Public(), key.Algorithm, nil
+		}
+	}
+	return nil, "", fmt.Errorf("unknown signing key %q", kid)
+}
+
+// sign signs claims with the current key, setting the kid header
+func (m *KeyManager) sign(claims jwt.Claims) (string, error) {
+	key, err := m.current()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// keyFunc resolves the verification key from a token's kid header. An unknown kid may belong to a
+// key another instance has just created, so the keys are reloaded before the token is rejected.
+func (m *KeyManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	public, algorithm, err := m.publicKey(kid)
+	if err != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if !m.reload(ctx) {
+			return nil, err
+		}
+		if public, algorithm, err = m.publicKey(kid); err != nil {
+			return nil, err
+		}
+	}
+	if token.Method.Alg() != algorithm {
+		return nil, errors.New("unexpected signing method")
+	}
+	return public, nil
+}
+
+// JSONWebKey is a public key in a JWKS document
+type JSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS returns the public keys that may currently be used to verify tokens, including the next key
+func (m *KeyManager) JWKS() []JSONWebKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]JSONWebKey, 0, len(m.keys))
+	for _, key := range m.keys {
+		if !key.ExpiresAt.After(now) {
+			continue
+		}
+		jwk := JSONWebKey{Kid: key.ID, Alg: key.Algorithm, Use: "sig"}
+		switch public := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
+
+// decodeSigningKey decrypts and parses the stored private key
+func (m *KeyManager) decodeSigningKey(key models.SigningKey) (signingKey, error) {
+	der := key.PrivateKey
+	switch key.Encryption {
+	case "":
+	case keyEncryption:
+		if m.aead == nil {
+			return signingKey{}, errors.New("private key is encrypted but no key encryption key is configured")
+		}
+		if len(der) < m.aead.NonceSize() {
+			return signingKey{}, errors.New("encrypted private key is truncated")
+		}
+		var err error
+		der, err = m.aead.Open(nil, der[:m.aead.NonceSize()], der[m.aead.NonceSize():], []byte(key.ID))
+		if err != nil {
+			return signingKey{}, errors.New("private key could not be decrypted with the configured key encryption key")
+		}
+	default:
+		return signingKey{}, fmt.Errorf("unsupported private key encryption %q", key.Encryption)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return signingKey{}, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return signingKey{}, errors.New("unsupported private key type")
+	}
+	return signingKey{SigningKey: key, private: private}, nil
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"server/db/models"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// memoryKeyStore is a KeyStore that enforces one key per algorithm and slot, like the unique index
+type memoryKeyStore struct {
+	mu   sync.Mutex
+	keys []models.SigningKey
+}
+
+func (s *memoryKeyStore) LoadKeys(ctx context.Context) ([]models.SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.SigningKey(nil), s.keys...), nil
+}
+
+func (s *memoryKeyStore) SaveKey(ctx context.Context, key *models.SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, existing := range s.keys {
+		if existing.Algorithm == key.Algorithm && existing.Slot == key.Slot {
+			return ErrKeyExists
+		}
+	}
+	s.keys = append(s.keys, *key)
+	return nil
+}
+
+func (s *memoryKeyStore) DeleteExpiredKeys(ctx context.Context, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.keys[:0]
+	for _, key := range s.keys {
+		if !key.ExpiresAt.Before(now) {
+			kept = append(kept, key)
+		}
+	}
+	s.keys = kept
+	return nil
+}
+
+func newTestKeyManager(t *testing.T, store KeyStore, keyEncryptionKey []byte) *KeyManager {
+	t.Helper()
+	m, err := NewKeyManager(store, AlgorithmEdDSA, 30*24*time.Hour, 24*time.Hour, keyEncryptionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func testClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestInstancesStartingTogetherShareOneKey(t *testing.T) {
+	store := &memoryKeyStore{}
+	managers := make([]*KeyManager, 5)
+	var wg sync.WaitGroup
+	for i := range managers {
+		managers[i] = newTestKeyManager(t, store, nil)
+		wg.Add(1)
+		go func(m *KeyManager) {
+			defer wg.Done()
+			if err := m.Refresh(context.Background()); err != nil {
+				t.Errorf("Refresh: %v", err)
+			}
+		}(managers[i])
+	}
+	wg.Wait()
+
+	slot := managers[0].slot(time.Now())
+	count := 0
+	for _, key := range store.keys {
+		if key.Slot == slot {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("stored %d keys for the current slot, want 1", count)
+	}
+
+	token, err := managers[0].sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range managers {
+		if _, err := jwt.Parse(token, m.keyFunc); err != nil {
+			t.Errorf("instance %d rejected the token: %v", i, err)
+		}
+	}
+}
+
+func TestUnknownKidReloadsKeys(t *testing.T) {
+	store := &memoryKeyStore{}
+	stale := newTestKeyManager(t, store, nil) // Started before any key existed
+
+	fresh := newTestKeyManager(t, store, nil)
+	if err := fresh.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	token, err := fresh.sign(testClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := jwt.Parse(token, stale.keyFunc); err != nil {
+		t.Errorf("stale instance rejected a token signed with a stored key: %v", err)
+	}
+
+	// Further unknown kids don't reload again within the interval
+	reloadedAt := stale.reloadedAt
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, testClaims())
+	unknown.Header["kid"] = "unknown"
+	if _, err := stale.keyFunc(unknown); err == nil {
+		t.Error("keyFunc accepted an unknown kid")
+	}
+	if stale.reloadedAt != reloadedAt {
+		t.Error("keys were reloaded again within the reload interval")
+	}
+}
+
+func TestPrivateKeysAreEncryptedAtRest(t *testing.T) {
+	store := &memoryKeyStore{}
+	keyEncryptionKey := bytes.Repeat([]byte{7}, 32)
+	m := newTestKeyManager(t, store, keyEncryptionKey)
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := store.keys[0]
+	if stored.Encryption != keyEncryption {
+		t.Errorf("Encryption = %q, want %q", stored.Encryption, keyEncryption)
+	}
+	if _, err := x509.ParsePKCS8PrivateKey(stored.PrivateKey); err == nil {
+		t.Error("stored private key parses as plain PKCS #8")
+	}
+
+	// Another instance with the same key encryption key can use it
+	if err := newTestKeyManager(t, store, keyEncryptionKey).Refresh(context.Background()); err != nil {
+		t.Errorf("Refresh with the same key encryption key: %v", err)
+	}
+	// Without it, or with another one, loading fails rather than signing with an unusable key
+	if err := newTestKeyManager(t, store, nil).Refresh(context.Background()); err == nil {
+		t.Error("Refresh without the key encryption key succeeded")
+	}
+	if err := newTestKeyManager(t, store, bytes.Repeat([]byte{8}, 32)).Refresh(context.Background()); err == nil {
+		t.Error("Refresh with another key encryption key succeeded")
+	}
+}
+
+func TestRefreshCreatesNextKeyBeforeOverlap(t *testing.T) {
+	store := &memoryKeyStore{}
+	m := newTestKeyManager(t, store, nil)
+	// A rotation this short puts now inside the overlap window of the current slot
+	m.rotation, m.overlap = 2*time.Hour, time.Hour+59*time.Minute+59*time.Second
+	if err := m.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	want := map[int64]bool{m.slot(now): true}
+	if m.slotStart(m.slot(now)+1).Sub(now) <= m.overlap {
+		want[m.slot(now)+1] = true
+	}
+	if len(store.keys) != len(want) {
+		t.Fatalf("stored %d keys, want %d", len(store.keys), len(want))
+	}
+	for _, key := range store.keys {
+		if !want[key.Slot] || !key.ActiveFrom.Equal(m.slotStart(key.Slot)) {
+			t.Errorf("key for slot %d active from %v", key.Slot, key.ActiveFrom)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key from the RFC 4226 and RFC 6238 test vectors, "12345678901234567890", in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeMatchesRFC4226(t *testing.T) {
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		got, err := TOTPCode(rfcSecret, int64(counter))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != code {
+			t.Errorf("HOTP counter %d = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists 8 digit codes; 6 digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPCounter(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.code {
+			t.Errorf("TOTP at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	counter := TOTPCounter(now)
+	previous, _ := TOTPCode(rfcSecret, counter-1)
+	tooOld, _ := TOTPCode(rfcSecret, counter-2)
+
+	if matched, ok := ValidateTOTP(rfcSecret, " 050471 ", now, 0); !ok || matched != counter {
+		t.Errorf("current code = %d, %v; want step %d", matched, ok, counter)
+	}
+	if _, ok := ValidateTOTP(rfcSecret, previous, now, 0); !ok {
+		t.Error("code from the previous step was rejected despite the allowed skew")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, tooOld, now, 0); ok {
+		t.Error("code from two steps ago was accepted")
+	}
+	if _, ok := ValidateTOTP(rfcSecret, "050471", now, counter); ok {
+		t.Error("code for an already used step was accepted")
+	}
+}
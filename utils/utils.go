@@ -2,41 +2,94 @@ package utils
 
 import (
 	"errors"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// ChallengePurpose marks a token issued between the password and second factor steps of login
+const ChallengePurpose = "2fa_challenge"
+
+// ChallengeTokenTTL is how long a user has to complete the second login step
+const ChallengeTokenTTL = 5 * time.Minute
+
+// TokenTTL is how long an access token is valid
+const TokenTTL = 15 * time.Minute
+
+// Default iss and aud claims for tokens issued by this server
+const (
+	DefaultTokenIssuer   = "flow"
+	DefaultTokenAudience = "flow-api"
+)
+
+// errNoTokenSecret is returned when HS256 tokens are used before a secret is configured
+var errNoTokenSecret = errors.New("token signing secret is not configured")
+
+var (
+	tokenKeys     *KeyManager
+	tokenSecret   []byte
+	tokenIssuer   = DefaultTokenIssuer
+	tokenAudience = DefaultTokenAudience
+)
+
+// ConfigureTokens sets the issuer and audience of tokens and the key set used to sign them.
+// Without a key manager tokens are signed with HS256 using secret.
+func ConfigureTokens(keys *KeyManager, secret, issuer, audience string) {
+	tokenKeys = keys
+	tokenSecret = []byte(secret)
+	tokenIssuer = issuer
+	tokenAudience = audience
+}
+
 // CustomClaims holds the claims data for JWT
 type CustomClaims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid,omitempty"`     // Session the token belongs to
+	Purpose   string `json:"purpose,omitempty"` // Empty for access tokens
+	Method    string `json:"method,omitempty"`  // First factor a challenge token was issued after
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a new JWT token for the given user ID
-func GenerateToken(userID string) (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return "", errors.New("JWT_SECRET environment variable is not set")
+// GenerateToken creates a new JWT token for the given user ID and session
+func GenerateToken(userID, sessionID string) (string, error) {
+	return generateToken(CustomClaims{UserID: userID, SessionID: sessionID}, TokenTTL)
+}
+
+// GenerateChallengeToken creates a short-lived token that can only be exchanged for an access token
+// by completing two-factor authentication, remembering how the user passed the first factor. The
+// challenge ID is the token's jti, which the server records so the token can only be exchanged once.
+func GenerateChallengeToken(userID, method, challengeID string) (string, error) {
+	claims := CustomClaims{UserID: userID, Purpose: ChallengePurpose, Method: method}
+	claims.ID = challengeID
+	return generateToken(claims, ChallengeTokenTTL)
+}
+
+func generateToken(claims CustomClaims, ttl time.Duration) (string, error) {
+	// Add the registered claims and expiration time
+	expirationTime := time.Now().Add(ttl)
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        claims.ID,
+		Issuer:    tokenIssuer,
+		Audience:  jwt.ClaimStrings{tokenAudience},
+		ExpiresAt: jwt.NewNumericDate(expirationTime),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+	}
+
+	// Sign with the rotating key set when one is configured
+	if tokenKeys != nil {
+		return tokenKeys.sign(claims)
 	}
 
-	// Create claims with user ID and expiration time
-	expirationTime := time.Now().Add(15 * time.Minute) // Token expires in 24 hours
-	claims := CustomClaims{
-		UserID: userID,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+	if len(tokenSecret) == 0 {
+		return "", errNoTokenSecret
 	}
 
 	// Create the token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// Sign the token with the secret key
-	tokenString, err := token.SignedString([]byte(jwtSecret))
+	tokenString, err := token.SignedString(tokenSecret)
 	if err != nil {
 		return "", err
 	}
@@ -44,22 +97,57 @@ func GenerateToken(userID string) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateToken validates a JWT token and returns the claims
+// ValidateToken validates an access token and returns the claims
 func ValidateToken(tokenString string) (*CustomClaims, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return nil, errors.New("JWT_SECRET environment variable is not set")
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
 	}
+	if claims.Purpose != "" {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
 
-	// Parse and validate the token
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
+// ValidateChallengeToken validates a two-factor challenge token and returns the claims
+func ValidateChallengeToken(tokenString string) (*CustomClaims, error) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != ChallengePurpose {
+		return nil, errors.New("invalid challenge token")
+	}
+	return claims, nil
+}
+
+// parseToken validates a JWT token of any purpose and returns the claims
+func parseToken(tokenString string) (*CustomClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithIssuer(tokenIssuer),
+		jwt.WithAudience(tokenAudience),
+		jwt.WithExpirationRequired(),
+	}
+
+	var keyFunc jwt.Keyfunc
+	if tokenKeys != nil {
+		keyFunc = tokenKeys.keyFunc
+		options = append(options, jwt.WithValidMethods([]string{AlgorithmRS256, AlgorithmEdDSA}))
+	} else {
+		if len(tokenSecret) == 0 {
+			return nil, errNoTokenSecret
+		}
+		keyFunc = func(token *jwt.Token) (interface{}, error) {
+			// Validate the signing method
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+			return tokenSecret, nil
 		}
-		return []byte(jwtSecret), nil
-	})
+	}
 
+	// Parse and validate the token
+	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, keyFunc, options...)
 	if err != nil {
 		return nil, err
 	}
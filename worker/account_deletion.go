@@ -0,0 +1,201 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"server/db/models"
+	"server/db/repository"
+	"server/health"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccountDeletionWorker permanently deletes accounts whose deletion grace period has passed,
+// along with the data they own
+type AccountDeletionWorker struct {
+	UserRepo         *repository.UserRepository
+	BreakdownRepo    *repository.BreakdownRepository
+	AccessTokenRepo  *repository.AccessTokenRepository
+	TransferRepo     *repository.TransferRepository
+	SessionRepo      *repository.SessionRepository
+	WorkspaceRepo    *repository.WorkspaceRepository
+	InvitationRepo   *repository.InvitationRepository
+	CommentRepo      *repository.CommentRepository
+	NotificationRepo *repository.NotificationRepository
+	Interval         time.Duration
+	Heartbeat        health.Heartbeat // Beats every iteration so a stuck loop can be detected
+}
+
+func NewAccountDeletionWorker(userRepo *repository.UserRepository, breakdownRepo *repository.BreakdownRepository, accessTokenRepo *repository.AccessTokenRepository, transferRepo *repository.TransferRepository, sessionRepo *repository.SessionRepository, workspaceRepo *repository.WorkspaceRepository, invitationRepo *repository.InvitationRepository, commentRepo *repository.CommentRepository, notificationRepo *repository.NotificationRepository) *AccountDeletionWorker {
+	return &AccountDeletionWorker{
+		UserRepo:         userRepo,
+		BreakdownRepo:    breakdownRepo,
+		AccessTokenRepo:  accessTokenRepo,
+		TransferRepo:     transferRepo,
+		SessionRepo:      sessionRepo,
+		WorkspaceRepo:    workspaceRepo,
+		InvitationRepo:   invitationRepo,
+		CommentRepo:      commentRepo,
+		NotificationRepo: notificationRepo,
+		Interval:         time.Hour,
+	}
+}
+
+// Run deletes due accounts every interval until ctx is cancelled
+func (w *AccountDeletionWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.Heartbeat.Beat()
+		if err := w.DeleteDueAccounts(ctx); err != nil {
+			slog.Error("Failed to delete scheduled accounts", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeleteDueAccounts deletes every account whose scheduled deletion time has passed
+func (w *AccountDeletionWorker) DeleteDueAccounts(ctx context.Context) error {
+	var users []models.User
+	err := w.UserRepo.Find(ctx, bson.M{"deletion_scheduled_at": bson.M{"$lte": time.Now()}}, &users)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if err := w.deleteAccount(ctx, user); err != nil {
+			return err
+		}
+		slog.Info("Deleted account", "user_id", user.ID.Hex())
+	}
+	return nil
+}
+
+// deleteAccount removes the user's data before the user, so a failure part way is retried on the next run.
+// Breakdowns the user wrote in shared workspaces stay with the team.
+func (w *AccountDeletionWorker) deleteAccount(ctx context.Context, user models.User) error {
+	// Workspaces nobody can take over go with the account; empty them before RemoveUser deletes them,
+	// so their contents are still found if a later step fails
+	orphaned, err := w.WorkspaceRepo.FindOrphanedWorkspaces(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if err := w.deleteWorkspaceContents(ctx, orphaned); err != nil {
+		return err
+	}
+
+	if err := w.CommentRepo.Delete(ctx, bson.M{"author_id": user.ID}); err != nil {
+		return err
+	}
+	if err := w.NotificationRepo.Delete(ctx, bson.M{"$or": bson.A{
+		bson.M{"user_id": user.ID},
+		bson.M{"actor_id": user.ID},
+	}}); err != nil {
+		return err
+	}
+	if err := w.AccessTokenRepo.Delete(ctx, bson.M{"user_id": user.ID}); err != nil {
+		return err
+	}
+	if err := w.TransferRepo.Delete(ctx, bson.M{"$or": bson.A{
+		bson.M{"from_user_id": user.ID},
+		bson.M{"to_user_id": user.ID},
+	}}); err != nil {
+		return err
+	}
+	if err := w.SessionRepo.Delete(ctx, bson.M{"user_id": user.ID}); err != nil {
+		return err
+	}
+
+	// Shared workspaces the user owns pass to another member. Workspaces whose other members left
+	// since FindOrphanedWorkspaces are deleted too, so they are emptied again.
+	deleted, err := w.WorkspaceRepo.RemoveUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if err := w.deleteWorkspaceContents(ctx, deleted); err != nil {
+		return err
+	}
+
+	if err := w.reassignBreakdowns(ctx, user.ID); err != nil {
+		return err
+	}
+
+	return w.UserRepo.Delete(ctx, bson.M{"_id": user.ID})
+}
+
+// deleteWorkspaceContents removes the breakdowns, their discussions and the invitations of the given workspaces
+func (w *AccountDeletionWorker) deleteWorkspaceContents(ctx context.Context, workspaceIDs []primitive.ObjectID) error {
+	if len(workspaceIDs) == 0 {
+		return nil
+	}
+
+	filter := bson.M{"workspace_id": bson.M{"$in": workspaceIDs}}
+	breakdownIDs, err := w.BreakdownRepo.FindIDs(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if err := w.CommentRepo.DeleteForBreakdowns(ctx, breakdownIDs); err != nil {
+		return err
+	}
+	if err := w.BreakdownRepo.Delete(ctx, filter); err != nil {
+		return err
+	}
+	return w.InvitationRepo.Delete(ctx, filter)
+}
+
+// reassignBreakdowns passes the breakdowns the user wrote in surviving workspaces to each workspace's
+// owner. Any left over belong to workspaces that no longer exist and are deleted.
+func (w *AccountDeletionWorker) reassignBreakdowns(ctx context.Context, userID primitive.ObjectID) error {
+	var authored []models.Breakdown
+	if err := w.BreakdownRepo.Find(ctx, bson.M{"user_id": userID}, &authored); err != nil {
+		return err
+	}
+	if len(authored) == 0 {
+		return nil
+	}
+
+	workspaceIDs := make([]primitive.ObjectID, 0, len(authored))
+	for _, breakdown := range authored {
+		workspaceIDs = append(workspaceIDs, breakdown.WorkspaceID)
+	}
+	var workspaces []models.Workspace
+	if err := w.WorkspaceRepo.Find(ctx, bson.M{"_id": bson.M{"$in": workspaceIDs}}, &workspaces); err != nil {
+		return err
+	}
+
+	for _, workspace := range workspaces {
+		err := w.BreakdownRepo.Update(ctx,
+			bson.M{"user_id": userID, "workspace_id": workspace.ID},
+			bson.M{"$set": bson.M{"user_id": workspace.OwnerID, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	stranded := bson.M{"user_id": userID, "workspace_id": bson.M{"$nin": workspaceIDsOf(workspaces)}}
+	breakdownIDs, err := w.BreakdownRepo.FindIDs(ctx, stranded)
+	if err != nil {
+		return err
+	}
+	if err := w.CommentRepo.DeleteForBreakdowns(ctx, breakdownIDs); err != nil {
+		return err
+	}
+	return w.BreakdownRepo.Delete(ctx, stranded)
+}
+
+func workspaceIDsOf(workspaces []models.Workspace) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(workspaces))
+	for i, workspace := range workspaces {
+		ids[i] = workspace.ID
+	}
+	return ids
+}
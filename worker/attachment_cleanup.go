@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"server/db/repository"
+	"server/health"
+	"server/storage"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// orphanBatchSize bounds how many orphaned attachments are handled per query
+const orphanBatchSize = 100
+
+// AttachmentCleanupWorker deletes the blobs and records of attachments whose breakdown has been deleted
+type AttachmentCleanupWorker struct {
+	AttachmentRepo *repository.AttachmentRepository
+	Store          storage.BlobStore
+	Interval       time.Duration
+	Heartbeat      health.Heartbeat // Beats every iteration so a stuck loop can be detected
+}
+
+func NewAttachmentCleanupWorker(attachmentRepo *repository.AttachmentRepository, store storage.BlobStore) *AttachmentCleanupWorker {
+	return &AttachmentCleanupWorker{
+		AttachmentRepo: attachmentRepo,
+		Store:          store,
+		Interval:       time.Hour,
+	}
+}
+
+// Run removes orphaned attachments every interval until ctx is cancelled
+func (w *AttachmentCleanupWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.Heartbeat.Beat()
+		if err := w.DeleteOrphans(ctx); err != nil {
+			slog.Error("Failed to delete orphaned attachments", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeleteOrphans removes every attachment whose breakdown no longer exists
+func (w *AttachmentCleanupWorker) DeleteOrphans(ctx context.Context) error {
+	for {
+		orphans, err := w.AttachmentRepo.FindOrphaned(ctx, orphanBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, attachment := range orphans {
+			// The blob goes first so a failure part way leaves the record to retry from
+			if err := w.Store.Delete(ctx, attachment.StorageKey); err != nil {
+				return err
+			}
+			if err := w.AttachmentRepo.Delete(ctx, bson.M{"_id": attachment.ID}); err != nil {
+				return err
+			}
+		}
+		if len(orphans) > 0 {
+			slog.Info("Deleted orphaned attachments", "count", len(orphans))
+		}
+
+		if len(orphans) < orphanBatchSize {
+			return nil
+		}
+	}
+}